@@ -0,0 +1,196 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package changelog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"pault.ag/go/debian/version"
+)
+
+// The layout of the date in the trailer line of a changelog entry, as
+// written by `date -R`.
+const whenLayout = "Mon, 02 Jan 2006 15:04:05 -0700"
+
+// Some hand-written changelogs leave the day unpadded; accept those on
+// the way in, but never write them out.
+const whenLayoutUnpadded = "Mon, 2 Jan 2006 15:04:05 -0700"
+
+// A ChangelogEntry is a single entry of a debian/changelog file, such as:
+//
+//	hello (2.10-3) unstable; urgency=medium
+//
+//	  * Fix the frobnicator.
+//
+//	 -- Santiago Vila <sanvila@debian.org>  Sat, 20 Jan 2024 12:00:00 +0100
+//
+// Changelog holds the text of the changes, without the two spaces of
+// indentation every line has in the file.
+type ChangelogEntry struct {
+	Source    string
+	Version   version.Version
+	Target    string
+	Arguments map[string]string
+	Changelog string
+	ChangedBy string
+	When      time.Time
+}
+
+type ChangelogEntries []ChangelogEntry
+
+// Check to see if this entry is for a binary-only upload (a binNMU), which
+// is marked with a binary-only=yes argument in the header. The Version of
+// such an entry usually has a +bN suffix, see version.Version.BinNMU.
+func (entry ChangelogEntry) IsBinaryOnly() bool {
+	return entry.Arguments["binary-only"] == "yes"
+}
+
+func trim(line string) string {
+	return strings.TrimRight(line, "\r\n\t ")
+}
+
+func parseHeader(entry *ChangelogEntry, line string) error {
+	open := strings.Index(line, " (")
+	closing := strings.Index(line, ") ")
+	semicolon := strings.Index(line, ";")
+	if open < 0 || closing < open || semicolon < closing {
+		return fmt.Errorf("Malformed changelog header: '%s'", line)
+	}
+
+	entry.Source = line[:open]
+	v, err := version.Parse(line[open+2 : closing])
+	if err != nil {
+		return err
+	}
+	entry.Version = v
+	entry.Target = strings.TrimSpace(line[closing+2 : semicolon])
+
+	entry.Arguments = map[string]string{}
+	for _, argument := range strings.Split(line[semicolon+1:], ",") {
+		argument = strings.TrimSpace(argument)
+		if argument == "" {
+			continue
+		}
+		els := strings.SplitN(argument, "=", 2)
+		if len(els) != 2 {
+			return fmt.Errorf("Malformed changelog header argument: '%s'", argument)
+		}
+		entry.Arguments[els[0]] = els[1]
+	}
+	return nil
+}
+
+func parseTrailer(entry *ChangelogEntry, line string) error {
+	els := strings.SplitN(strings.TrimPrefix(line, " -- "), "  ", 2)
+	if len(els) != 2 {
+		return fmt.Errorf("Malformed changelog trailer: '%s'", line)
+	}
+	when, err := time.Parse(whenLayout, strings.TrimSpace(els[1]))
+	if err != nil {
+		var unpaddedErr error
+		when, unpaddedErr = time.Parse(whenLayoutUnpadded, strings.TrimSpace(els[1]))
+		if unpaddedErr != nil {
+			return err
+		}
+	}
+	entry.ChangedBy = els[0]
+	entry.When = when
+	return nil
+}
+
+// Parse a single changelog entry out of the reader, returning io.EOF once
+// there are no more entries left.
+func ParseOne(reader *bufio.Reader) (*ChangelogEntry, error) {
+	entry := ChangelogEntry{}
+
+	header := ""
+	for header == "" {
+		line, err := reader.ReadString('\n')
+		if err != nil && (err != io.EOF || trim(line) == "") {
+			return nil, err
+		}
+		header = trim(line)
+	}
+	if err := parseHeader(&entry, header); err != nil {
+		return nil, err
+	}
+
+	lines := []string{}
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF && trim(line) == "" {
+			return nil, fmt.Errorf("Changelog entry for %s %s has no trailer", entry.Source, entry.Version)
+		} else if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		line = trim(line)
+		if strings.HasPrefix(line, " -- ") {
+			if err := parseTrailer(&entry, line); err != nil {
+				return nil, err
+			}
+			break
+		}
+		lines = append(lines, strings.TrimPrefix(line, "  "))
+	}
+
+	/* Drop the blank lines around the changes themselves */
+	for len(lines) != 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) != 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	entry.Changelog = strings.Join(lines, "\n")
+	return &entry, nil
+}
+
+// Parse every changelog entry out of the reader.
+func Parse(reader io.Reader) (ChangelogEntries, error) {
+	stream := bufio.NewReader(reader)
+	ret := ChangelogEntries{}
+	for {
+		entry, err := ParseOne(stream)
+		if err == io.EOF {
+			return ret, nil
+		} else if err != nil {
+			return nil, err
+		}
+		ret = append(ret, *entry)
+	}
+}
+
+// Parse every changelog entry out of the file at the given path.
+func ParseFile(path string) (ChangelogEntries, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// vim: foldmethod=marker
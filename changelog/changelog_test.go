@@ -0,0 +1,209 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package changelog_test
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"pault.ag/go/debian/changelog"
+	"pault.ag/go/debian/version"
+)
+
+/*
+ *
+ */
+
+func isok(t *testing.T, err error) {
+	if err != nil {
+		log.Printf("Error! Error is not nil! - %s\n", err)
+		t.FailNow()
+	}
+}
+
+func notok(t *testing.T, err error) {
+	if err == nil {
+		log.Printf("Error! Error is nil!\n")
+		t.FailNow()
+	}
+}
+
+func assert(t *testing.T, expr bool) {
+	if !expr {
+		log.Printf("Assertion failed!")
+		t.FailNow()
+	}
+}
+
+/*
+ *
+ */
+
+// Test Changelog {{{
+const testChangelog = `hello (2.10-3) unstable; urgency=medium, binary-only=yes
+
+  * Fix the frobnicator.
+    - Really, this time.
+
+  [ Paul Tagliamonte ]
+  * Add a second changelog block.
+
+ -- Santiago Vila <sanvila@debian.org>  Sat, 20 Jan 2024 12:00:00 +0100
+
+hello (2.10-2) unstable; urgency=low
+
+  * Initial release.
+
+ -- Santiago Vila <sanvila@debian.org>  Mon, 01 Jan 2024 09:30:00 +0000
+`
+
+// }}}
+
+func TestChangelogParse(t *testing.T) {
+	entries, err := changelog.Parse(strings.NewReader(testChangelog))
+	isok(t, err)
+	assert(t, len(entries) == 2)
+
+	entry := entries[0]
+	assert(t, entry.Source == "hello")
+	assert(t, entry.Version.String() == "2.10-3")
+	assert(t, entry.Target == "unstable")
+	assert(t, entry.Arguments["urgency"] == "medium")
+	assert(t, entry.Arguments["binary-only"] == "yes")
+	assert(t, entry.ChangedBy == "Santiago Vila <sanvila@debian.org>")
+	assert(t, entry.When.Day() == 20)
+	assert(t, strings.HasPrefix(entry.Changelog, "* Fix the frobnicator.\n  - Really"))
+	assert(t, strings.HasSuffix(entry.Changelog, "* Add a second changelog block."))
+
+	assert(t, entries[1].Changelog == "* Initial release.")
+	assert(t, entries[1].When.Day() == 1)
+}
+
+func TestChangelogBinaryOnly(t *testing.T) {
+	entries, err := changelog.Parse(strings.NewReader(`hello (2.10-3+b1) sid; urgency=low, binary-only=yes
+
+  * Binary-only non-maintainer upload for amd64; no source changes.
+  * Rebuild against libfoo2.
+
+ -- amd64 Build Daemon <buildd_amd64-x86-ubc-01@buildd.debian.org>  Sun, 21 Jan 2024 08:00:00 +0000
+` + testChangelog))
+	isok(t, err)
+	assert(t, len(entries) == 3)
+
+	assert(t, entries[0].IsBinaryOnly())
+	assert(t, entries[0].Version.IsBinNMU())
+	assert(t, entries[0].Version.BinNMU() == 1)
+	assert(t, version.Compare(entries[0].Version, entries[1].Version) > 0)
+
+	assert(t, !entries[2].IsBinaryOnly())
+	assert(t, !entries[2].Version.IsBinNMU())
+}
+
+func TestChangelogParseErrors(t *testing.T) {
+	_, err := changelog.Parse(strings.NewReader("hello 2.10-3 unstable\n"))
+	notok(t, err)
+
+	_, err = changelog.Parse(strings.NewReader("hello (2.10-3) unstable; urgency=low\n\n  * Foo.\n"))
+	notok(t, err)
+
+	_, err = changelog.Parse(strings.NewReader(
+		"hello (2.10-3) unstable; urgency=low\n\n  * Foo.\n\n -- Foo <foo@example.com> yesterday\n",
+	))
+	notok(t, err)
+}
+
+func TestChangelogWrite(t *testing.T) {
+	entries, err := changelog.Parse(strings.NewReader(testChangelog))
+	isok(t, err)
+
+	buf := bytes.Buffer{}
+	isok(t, changelog.Write(&buf, entries))
+	assert(t, buf.String() == testChangelog)
+
+	/* And once more, to make sure it's stable */
+	again, err := changelog.Parse(&buf)
+	isok(t, err)
+	assert(t, len(again) == len(entries))
+	for i := range entries {
+		assert(t, again[i].Version.String() == entries[i].Version.String())
+		assert(t, again[i].Changelog == entries[i].Changelog)
+		assert(t, again[i].When.Equal(entries[i].When))
+	}
+}
+
+func TestChangelogWriteNoArguments(t *testing.T) {
+	input := "hello (2.10-1) unstable;\n\n  * Foo.\n\n -- Foo <foo@example.com>  Tue, 02 Jan 2024 09:30:00 +0000\n"
+	entries, err := changelog.Parse(strings.NewReader(input))
+	isok(t, err)
+	assert(t, len(entries) == 1)
+	assert(t, len(entries[0].Arguments) == 0)
+
+	buf := bytes.Buffer{}
+	isok(t, changelog.Write(&buf, entries))
+	assert(t, buf.String() == input)
+}
+
+func TestChangelogUnpaddedDay(t *testing.T) {
+	entries, err := changelog.Parse(strings.NewReader(
+		"hello (2.10-1) unstable; urgency=low\n\n  * Foo.\n\n -- Foo <foo@example.com>  Tue, 2 Jan 2024 09:30:00 +0000\n",
+	))
+	isok(t, err)
+	assert(t, len(entries) == 1)
+	assert(t, entries[0].When.Day() == 2)
+
+	buf := bytes.Buffer{}
+	isok(t, changelog.Write(&buf, entries))
+	assert(t, strings.HasSuffix(buf.String(), "  Tue, 02 Jan 2024 09:30:00 +0000\n"))
+}
+
+func TestChangelogPrepend(t *testing.T) {
+	entries, err := changelog.Parse(strings.NewReader(testChangelog))
+	isok(t, err)
+
+	entry := entries[0]
+	entry.Version, err = version.Parse("2.10-4")
+	isok(t, err)
+	entry.Changelog = "* Another upload."
+
+	prepended, err := changelog.Prepend(entries, entry)
+	isok(t, err)
+	assert(t, len(prepended) == 3)
+	assert(t, prepended[0].Version.String() == "2.10-4")
+	assert(t, prepended[1].Version.String() == "2.10-3")
+	assert(t, len(entries) == 2)
+
+	/* Not going backwards, or staying put */
+	for _, v := range []string{"2.10-3", "2.10-2", "2.10-3~bpo1"} {
+		entry.Version, err = version.Parse(v)
+		isok(t, err)
+		_, err = changelog.Prepend(entries, entry)
+		notok(t, err)
+	}
+
+	/* Anything goes for the first entry */
+	first, err := changelog.Prepend(nil, entry)
+	isok(t, err)
+	assert(t, len(first) == 1)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,6 @@
+/*
+
+Parse and write debian/changelog files
+
+*/
+package changelog
@@ -0,0 +1,109 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package changelog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"pault.ag/go/debian/version"
+)
+
+func (entry ChangelogEntry) header() string {
+	arguments := []string{}
+	if urgency, ok := entry.Arguments["urgency"]; ok {
+		arguments = append(arguments, "urgency="+urgency)
+	}
+	keys := []string{}
+	for key := range entry.Arguments {
+		if key != "urgency" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		arguments = append(arguments, key+"="+entry.Arguments[key])
+	}
+
+	header := fmt.Sprintf("%s (%s) %s;", entry.Source, entry.Version, entry.Target)
+	if len(arguments) == 0 {
+		/* dpkg-parsechangelog needs the ; even with nothing after it */
+		return header
+	}
+	return header + " " + strings.Join(arguments, ", ")
+}
+
+// Write out a single entry in the debian/changelog format: the header, a
+// blank line, the changes indented by two spaces, another blank line, and
+// the trailer, which has exactly two spaces between the email and date.
+func (entry ChangelogEntry) WriteTo(out io.Writer) (int64, error) {
+	lines := []string{entry.header(), ""}
+	for _, line := range strings.Split(entry.Changelog, "\n") {
+		if line == "" {
+			lines = append(lines, "")
+		} else {
+			lines = append(lines, "  "+line)
+		}
+	}
+	lines = append(lines, "", fmt.Sprintf(" -- %s  %s", entry.ChangedBy, entry.When.Format(whenLayout)))
+
+	n, err := io.WriteString(out, strings.Join(lines, "\n")+"\n")
+	return int64(n), err
+}
+
+// Write the entries out to the io.Writer as a debian/changelog file, with a
+// blank line between each entry.
+func Write(w io.Writer, entries []ChangelogEntry) error {
+	writer := bufio.NewWriter(w)
+	for i, entry := range entries {
+		if i != 0 {
+			if _, err := writer.WriteString("\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := entry.WriteTo(writer); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// Return a new list of entries with entry on top of the existing ones, as
+// for a new upload. The version of the new entry has to be strictly
+// greater than the version of the current top entry, or an error is
+// returned, and the existing entries are left alone either way.
+func Prepend(existing []ChangelogEntry, entry ChangelogEntry) ([]ChangelogEntry, error) {
+	if len(existing) != 0 {
+		top := existing[0]
+		if version.Compare(entry.Version, top.Version) <= 0 {
+			return nil, fmt.Errorf(
+				"New changelog entry %s is not greater than the current %s",
+				entry.Version, top.Version,
+			)
+		}
+	}
+	return append([]ChangelogEntry{entry}, existing...), nil
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,128 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"pault.ag/go/debian/dependency"
+	"pault.ag/go/debian/version"
+)
+
+// The BuildInfo struct is the encapsulation of a Debian .buildinfo file, as
+// written by dpkg-genbuildinfo(1). It records the environment a package was
+// built in, so that the build can be reproduced (or verified) later on.
+type BuildInfo struct {
+	Paragraph
+
+	Filename string `control:"-"`
+
+	Format        string
+	Source        string
+	Binaries      []string          `control:"Binary,omitempty" delim:" "`
+	Architectures []dependency.Arch `control:"Architecture"`
+	Version       version.Version
+
+	ChecksumsMd5    []MD5DebianFileHash    `control:"Checksums-Md5,omitempty" delim:"\n" strip:"\n\r\t "`
+	ChecksumsSha1   []SHA1DebianFileHash   `control:"Checksums-Sha1,omitempty" delim:"\n" strip:"\n\r\t "`
+	ChecksumsSha256 []SHA256DebianFileHash `control:"Checksums-Sha256" delim:"\n" strip:"\n\r\t "`
+
+	BuildOrigin       string          `control:"Build-Origin,omitempty"`
+	BuildArchitecture dependency.Arch `control:"Build-Architecture"`
+	BuildDate         string          `control:"Build-Date,omitempty"`
+	BuildPath         string          `control:"Build-Path,omitempty"`
+
+	InstalledBuildDepends dependency.Dependency `control:"Installed-Build-Depends" fold:"true"`
+	Environment           []string              `control:",omitempty" delim:"\n" strip:"\n\r\t "`
+}
+
+// Given a path on the filesystem, Parse the file off the disk and return
+// a pointer to a brand new BuildInfo struct, unless error is set to a value
+// other than nil.
+func ParseBuildInfoFile(path string) (ret *BuildInfo, err error) {
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseBuildInfo(bufio.NewReader(f), path)
+}
+
+// Given a bufio.Reader, consume the Reader, and return a BuildInfo object
+// for use.
+func ParseBuildInfo(reader *bufio.Reader, path string) (*BuildInfo, error) {
+	ret := BuildInfo{Filename: path}
+	if err := Unmarshal(&ret, reader); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// Check that every package in Installed-Build-Depends is pinned to exactly
+// one version, which is what the field promises: a flat list of
+// "pkg (= version)" entries, with no alternatives or ranges.
+func (b *BuildInfo) ValidateInstalledBuildDepends() error {
+	for _, relation := range b.InstalledBuildDepends.Relations {
+		if len(relation.Possibilities) != 1 {
+			return fmt.Errorf("Installed-Build-Depends may not contain alternatives: '%s'", relation)
+		}
+		possi := relation.Possibilities[0]
+		if possi.Version == nil || possi.Version.Operator != "=" {
+			return fmt.Errorf("Installed-Build-Depends entry '%s' is not pinned with '='", possi)
+		}
+	}
+	return nil
+}
+
+// Return the packages in Installed-Build-Depends as a map from package
+// name (including the ":arch" qualifier, if any) to the exact version that
+// was installed, for diffing against another build environment.
+func (b *BuildInfo) InstalledBuildDependsVersions() (map[string]version.Version, error) {
+	if err := b.ValidateInstalledBuildDepends(); err != nil {
+		return nil, err
+	}
+
+	ret := map[string]version.Version{}
+	for _, relation := range b.InstalledBuildDepends.Relations {
+		possi := relation.Possibilities[0]
+		name := possi.Name
+		if possi.Arch != nil {
+			name += ":" + possi.Arch.String()
+		}
+		v, err := version.Parse(possi.Version.Number)
+		if err != nil {
+			return nil, err
+		}
+		ret[name] = v
+	}
+	return ret, nil
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,140 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"pault.ag/go/debian/control"
+)
+
+/*
+ *
+ */
+
+// Test BuildInfo {{{
+const testBuildInfo = `Format: 1.0
+Source: fbautostart
+Binary: fbautostart
+Architecture: amd64
+Version: 2.718281828-1
+Checksums-Md5:
+ 5702c6b3a4d4c3d8bee7ce9de53bfa6d 15122 fbautostart_2.718281828-1_amd64.deb
+Checksums-Sha256:
+ dd7e8b6a3a1bd9fc8b6ee64f395309f4d4d1a2130efbe409b9bd97d1c3dbfd3e 15122 fbautostart_2.718281828-1_amd64.deb
+Build-Origin: Debian
+Build-Architecture: amd64
+Build-Date: Thu, 01 Oct 2015 20:25:02 -0400
+Installed-Build-Depends:
+ autotools-dev (= 20150820.1),
+ debhelper (= 9.20150811),
+ libc6:amd64 (= 2.19-22),
+ libc6-dev (= 2.19-22)
+Environment:
+ DEB_BUILD_OPTIONS="parallel=4"
+ LANG="C.UTF-8"
+`
+
+// }}}
+
+func TestBuildInfoParse(t *testing.T) {
+	buildinfo, err := control.ParseBuildInfo(bufio.NewReader(strings.NewReader(testBuildInfo)), "")
+	isok(t, err)
+
+	assert(t, buildinfo.Source == "fbautostart")
+	assert(t, buildinfo.BuildArchitecture.CPU == "amd64")
+	assert(t, len(buildinfo.ChecksumsMd5) == 1)
+	assert(t, buildinfo.ChecksumsMd5[0].Algorithm == "md5")
+	assert(t, buildinfo.ChecksumsMd5[0].Size == 15122)
+	assert(t, len(buildinfo.ChecksumsSha256) == 1)
+	assert(t, len(buildinfo.Environment) == 2)
+	assert(t, buildinfo.Environment[1] == `LANG="C.UTF-8"`)
+
+	isok(t, buildinfo.ValidateInstalledBuildDepends())
+	versions, err := buildinfo.InstalledBuildDependsVersions()
+	isok(t, err)
+	assert(t, len(versions) == 4)
+	assert(t, versions["debhelper"].Version == "9.20150811")
+	assert(t, versions["libc6:amd64"].Revision == "22")
+	_, ok := versions["libc6"]
+	assert(t, !ok)
+}
+
+func TestBuildInfoInstalledBuildDependsExact(t *testing.T) {
+	for _, depends := range []string{
+		"debhelper (>= 9)",
+		"debhelper",
+		"debhelper (= 9) | cdbs (= 0.4)",
+	} {
+		buildinfo, err := control.ParseBuildInfo(bufio.NewReader(strings.NewReader(
+			"Source: fbautostart\nInstalled-Build-Depends: "+depends+"\n",
+		)), "")
+		isok(t, err)
+		notok(t, buildinfo.ValidateInstalledBuildDepends())
+		_, err = buildinfo.InstalledBuildDependsVersions()
+		notok(t, err)
+	}
+}
+
+func TestBuildInfoRoundTrip(t *testing.T) {
+	buildinfo, err := control.ParseBuildInfo(bufio.NewReader(strings.NewReader(testBuildInfo)), "")
+	isok(t, err)
+
+	out := bytes.Buffer{}
+	isok(t, control.Marshal(&out, buildinfo))
+	if out.String() != testBuildInfo {
+		t.Fatalf("BuildInfo output differs from the input:\n%s", out.String())
+	}
+
+	again, err := control.ParseBuildInfo(bufio.NewReader(&out), "")
+	isok(t, err)
+	assert(t, again.InstalledBuildDepends.String() == buildinfo.InstalledBuildDepends.String())
+	assert(t, len(again.InstalledBuildDepends.Relations) == 4)
+	assert(t, again.ChecksumsSha256[0].Hash == buildinfo.ChecksumsSha256[0].Hash)
+
+	versions, err := again.InstalledBuildDependsVersions()
+	isok(t, err)
+	assert(t, versions["libc6-dev"].String() == "2.19-22")
+
+	/* Neither the path it was read from, nor unset optional fields,
+	 * make it into the file */
+	buildinfo, err = control.ParseBuildInfo(bufio.NewReader(strings.NewReader(`Format: 1.0
+Source: fbautostart
+Architecture: source
+Version: 2.718281828-1
+Checksums-Sha256:
+ dd7e8b6a3a1bd9fc8b6ee64f395309f4d4d1a2130efbe409b9bd97d1c3dbfd3e 1692 fbautostart_2.718281828-1.dsc
+Build-Architecture: amd64
+Installed-Build-Depends:
+ debhelper (= 9.20150811)
+`)), "/srv/builds/fbautostart_2.718281828-1_source.buildinfo")
+	isok(t, err)
+	out.Reset()
+	isok(t, control.Marshal(&out, buildinfo))
+	assert(t, !strings.Contains(out.String(), "Filename"))
+	assert(t, !strings.Contains(out.String(), ": \n"))
+	assert(t, !strings.Contains(out.String(), "Build-Date"))
+}
+
+// vim: foldmethod=marker
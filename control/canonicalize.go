@@ -0,0 +1,285 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Options for Canonicalize. The zero value sorts nothing, and only wraps
+// relation fields once they'd go past 79 columns.
+type CanonicalizeOptions struct {
+	// Sort the entries of relation fields (such as Depends), dropping
+	// duplicates. Entries starting with anything other than a lowercase
+	// letter or digit (such as ${misc:Depends}) go last.
+	SortDependencies bool
+
+	// Sort the binary package Paragraphs by their Package name. The
+	// first Paragraph (the source package) always stays first.
+	SortBinaryPackages bool
+
+	// Put every entry of a relation field on its own line, even if the
+	// field would fit on one.
+	WrapAlways bool
+
+	// When wrapping, start the entries on the line after the key and
+	// indent them by a single space, rather than lining them up with the
+	// first entry.
+	ShortIndent bool
+
+	// When wrapping, end the last entry with a comma too.
+	TrailingComma bool
+
+	// How long a line may be before its field gets wrapped. If 0, 79.
+	MaxLineLength int
+}
+
+// The fields Canonicalize treats as comma separated relation lists,
+// matching wrap-and-sort(1).
+var canonicalizeListFields = map[string]bool{
+	"Breaks":                true,
+	"Build-Conflicts":       true,
+	"Build-Conflicts-Arch":  true,
+	"Build-Conflicts-Indep": true,
+	"Build-Depends":         true,
+	"Build-Depends-Arch":    true,
+	"Build-Depends-Indep":   true,
+	"Built-Using":           true,
+	"Conflicts":             true,
+	"Depends":               true,
+	"Enhances":              true,
+	"Pre-Depends":           true,
+	"Provides":              true,
+	"Recommends":            true,
+	"Replaces":              true,
+	"Suggests":              true,
+}
+
+// A field as it was written in the input, so that anything Canonicalize
+// doesn't rewrite comes back out byte-for-byte.
+type rawField struct {
+	Key   string
+	Lines []string
+}
+
+type rawParagraph []rawField
+
+func (para rawParagraph) value(key string) string {
+	for _, field := range para {
+		if field.Key == key {
+			return strings.TrimSpace(strings.SplitN(field.Lines[0], ":", 2)[1])
+		}
+	}
+	return ""
+}
+
+// Read the Paragraphs out of in, keeping every line as it was. Comments
+// stick to the field after them; ones after the last field of a Paragraph
+// are added to it as a field with no Key, and ones after the last
+// Paragraph of the file (past a blank line) are returned on their own.
+func readRawParagraphs(in io.Reader) ([]rawParagraph, []string, error) {
+	ret := []rawParagraph{}
+	current := rawParagraph{}
+	comments := []string{}
+
+	/* Not a bufio.Scanner, since that gives up on lines over 64 KiB, and
+	 * a long Description or Build-Depends line is no reason to fail */
+	reader := bufio.NewReader(in)
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF && line == "" {
+			break
+		} else if err != nil && err != io.EOF {
+			return nil, nil, err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			if len(current) != 0 {
+				ret = append(ret, closeRawParagraph(current, comments))
+				current = rawParagraph{}
+				comments = []string{}
+			}
+		case strings.HasPrefix(line, "#"):
+			/* Comments stick to the field that follows them */
+			comments = append(comments, line)
+		case line[0] == ' ' || line[0] == '\t':
+			if len(current) == 0 {
+				return nil, nil, fmt.Errorf("Line %q continues a field that isn't there", line)
+			}
+			field := &current[len(current)-1]
+			field.Lines = append(field.Lines, append(comments, line)...)
+			comments = []string{}
+		default:
+			if !strings.Contains(line, ":") {
+				return nil, nil, fmt.Errorf("Line %q is not 'key: val'", line)
+			}
+			key := strings.TrimSpace(strings.SplitN(line, ":", 2)[0])
+			current = append(current, rawField{Key: key, Lines: append(comments, line)})
+			comments = []string{}
+		}
+	}
+	if len(current) != 0 {
+		ret = append(ret, closeRawParagraph(current, comments))
+		comments = []string{}
+	}
+	return ret, comments, nil
+}
+
+// Add any comments after the last field of a Paragraph onto the end of it,
+// as a field with no Key, so that they stay where they were.
+func closeRawParagraph(para rawParagraph, comments []string) rawParagraph {
+	if len(comments) == 0 {
+		return para
+	}
+	return append(para, rawField{Lines: comments})
+}
+
+func sortRelationEntries(entries []string) []string {
+	seen := map[string]bool{}
+	packages, special := []string{}, []string{}
+	for _, entry := range entries {
+		if seen[entry] {
+			continue
+		}
+		seen[entry] = true
+		if c := entry[0]; (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			packages = append(packages, entry)
+		} else {
+			special = append(special, entry)
+		}
+	}
+	sort.Strings(packages)
+	sort.Strings(special)
+	return append(packages, special...)
+}
+
+func canonicalizeListField(field rawField, opts CanonicalizeOptions) rawField {
+	comments := []string{}
+	value := ""
+	for _, line := range field.Lines {
+		if strings.HasPrefix(line, "#") {
+			comments = append(comments, line)
+		} else if value == "" {
+			/* The key line */
+			value = " " + strings.SplitN(line, ":", 2)[1]
+		} else {
+			value += " " + line
+		}
+	}
+
+	entries := []string{}
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.Join(strings.Fields(entry), " "); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	if opts.SortDependencies {
+		entries = sortRelationEntries(entries)
+	}
+
+	maxLength := opts.MaxLineLength
+	if maxLength == 0 {
+		maxLength = 79
+	}
+
+	joined := strings.Join(entries, ", ")
+	if len(entries) == 0 || !opts.WrapAlways && len(field.Key)+2+len(joined) <= maxLength {
+		return rawField{Key: field.Key, Lines: append(comments, strings.TrimRight(field.Key+": "+joined, " "))}
+	}
+
+	if opts.TrailingComma {
+		entries[len(entries)-1] += ","
+	}
+	lines := comments
+	if opts.ShortIndent {
+		lines = append(lines, field.Key+":")
+		for i, entry := range entries {
+			if i != len(entries)-1 {
+				entry += ","
+			}
+			lines = append(lines, " "+entry)
+		}
+	} else {
+		indent := strings.Repeat(" ", len(field.Key)+2)
+		for i, entry := range entries {
+			if i != len(entries)-1 {
+				entry += ","
+			}
+			if i == 0 {
+				lines = append(lines, field.Key+": "+entry)
+			} else {
+				lines = append(lines, indent+entry)
+			}
+		}
+	}
+	return rawField{Key: field.Key, Lines: lines}
+}
+
+// Read a debian/control file from in, and write it back out to out with
+// its relation fields (Depends, Build-Depends and friends) tidied up the
+// way wrap-and-sort(1) does: one space after each comma, optionally sorted,
+// and wrapped one entry per line when too long. Everything else, including
+// the order of the fields, comments, and the exact text of other fields
+// (such as the Description) is kept as-is, with exactly one blank line
+// between Paragraphs.
+func Canonicalize(in io.Reader, out io.Writer, opts CanonicalizeOptions) error {
+	paras, trailing, err := readRawParagraphs(in)
+	if err != nil {
+		return err
+	}
+
+	if opts.SortBinaryPackages && len(paras) > 1 {
+		binaries := paras[1:]
+		sort.SliceStable(binaries, func(i, j int) bool {
+			return binaries[i].value("Package") < binaries[j].value("Package")
+		})
+	}
+
+	writer := bufio.NewWriter(out)
+	for i, para := range paras {
+		if i != 0 {
+			writer.WriteString("\n")
+		}
+		for _, field := range para {
+			if canonicalizeListFields[field.Key] {
+				field = canonicalizeListField(field, opts)
+			}
+			for _, line := range field.Lines {
+				writer.WriteString(line + "\n")
+			}
+		}
+	}
+	if len(trailing) != 0 && len(paras) != 0 {
+		writer.WriteString("\n")
+	}
+	for _, line := range trailing {
+		writer.WriteString(line + "\n")
+	}
+	return writer.Flush()
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,222 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"pault.ag/go/debian/control"
+)
+
+/*
+ *
+ */
+
+// Test debian/control files {{{
+
+const canonicalizeInput = `Source: fbautostart
+Section: misc
+Priority: optional
+Maintainer: Paul Tagliamonte <paultag@ubuntu.com>
+Build-Depends: debhelper (>= 9), libx11-dev,
+  autotools-dev,  debhelper (>= 9)
+Standards-Version: 3.9.3
+Homepage: https://launchpad.net/fbautostart
+
+
+Package: fbautostart-doc
+Architecture: all
+Depends: ${misc:Depends}
+Description: XDG compliant autostarting app for Fluxbox (documentation)
+ The fbautostart app was designed to have little to no overhead.
+
+Package: fbautostart
+Architecture: any
+# Keep libc6 around
+Depends: ${shlibs:Depends}, ${misc:Depends}, libc6 (>= 2.4) | libc6.1, fbautostart-doc (= ${source:Version})
+Suggests:
+Description: XDG compliant autostarting app for Fluxbox
+ The fbautostart app was designed to have little to no overhead, while
+ still maintaining the needed functionality of launching applications
+ according to the XDG spec.
+ .
+   * With some verbatim text
+`
+
+/* The same file, in the style of wrap-and-sort -abst */
+const canonicalizeGolden = `Source: fbautostart
+Section: misc
+Priority: optional
+Maintainer: Paul Tagliamonte <paultag@ubuntu.com>
+Build-Depends:
+ autotools-dev,
+ debhelper (>= 9),
+ libx11-dev,
+Standards-Version: 3.9.3
+Homepage: https://launchpad.net/fbautostart
+
+Package: fbautostart
+Architecture: any
+# Keep libc6 around
+Depends:
+ fbautostart-doc (= ${source:Version}),
+ libc6 (>= 2.4) | libc6.1,
+ ${misc:Depends},
+ ${shlibs:Depends},
+Suggests:
+Description: XDG compliant autostarting app for Fluxbox
+ The fbautostart app was designed to have little to no overhead, while
+ still maintaining the needed functionality of launching applications
+ according to the XDG spec.
+ .
+   * With some verbatim text
+
+Package: fbautostart-doc
+Architecture: all
+Depends:
+ ${misc:Depends},
+Description: XDG compliant autostarting app for Fluxbox (documentation)
+ The fbautostart app was designed to have little to no overhead.
+`
+
+// }}}
+
+// Test debian/control files ending in comments {{{
+
+const canonicalizeCommentsInput = `Source: foo
+Build-Depends: debhelper (>= 9), libx11-dev
+
+Package: foo-b
+Depends: zlib1g, libc6
+
+Package: foo-a
+Depends: libfoo1, libc6
+# Stays with foo-a
+
+# Down at the end of the file
+`
+
+const canonicalizeCommentsGolden = `Source: foo
+Build-Depends:
+ debhelper (>= 9),
+ libx11-dev,
+
+Package: foo-a
+Depends:
+ libc6,
+ libfoo1,
+# Stays with foo-a
+
+Package: foo-b
+Depends:
+ libc6,
+ zlib1g,
+
+# Down at the end of the file
+`
+
+// }}}
+
+func TestCanonicalizeTrailingComments(t *testing.T) {
+	opts := control.CanonicalizeOptions{
+		SortDependencies:   true,
+		SortBinaryPackages: true,
+		WrapAlways:         true,
+		ShortIndent:        true,
+		TrailingComma:      true,
+	}
+	out := bytes.Buffer{}
+	isok(t, control.Canonicalize(strings.NewReader(canonicalizeCommentsInput), &out, opts))
+	if out.String() != canonicalizeCommentsGolden {
+		t.Fatalf("Canonicalize output differs from golden:\n%s", out.String())
+	}
+
+	/* Right at the end, with no blank line */
+	out.Reset()
+	isok(t, control.Canonicalize(strings.NewReader("Source: foo\nDepends: a\n# trailing comment\n"), &out, control.CanonicalizeOptions{}))
+	assert(t, out.String() == "Source: foo\nDepends: a\n# trailing comment\n")
+
+	/* And with nothing else there at all */
+	out.Reset()
+	isok(t, control.Canonicalize(strings.NewReader("# just a comment\n"), &out, control.CanonicalizeOptions{}))
+	assert(t, out.String() == "# just a comment\n")
+}
+
+func TestCanonicalizeGolden(t *testing.T) {
+	out := bytes.Buffer{}
+	isok(t, control.Canonicalize(strings.NewReader(canonicalizeInput), &out, control.CanonicalizeOptions{
+		SortDependencies:   true,
+		SortBinaryPackages: true,
+		WrapAlways:         true,
+		ShortIndent:        true,
+		TrailingComma:      true,
+	}))
+	if out.String() != canonicalizeGolden {
+		t.Fatalf("Canonicalize output differs from golden:\n%s", out.String())
+	}
+
+	/* Doing it again doesn't change a thing */
+	again := bytes.Buffer{}
+	isok(t, control.Canonicalize(bytes.NewReader(out.Bytes()), &again, control.CanonicalizeOptions{
+		SortDependencies:   true,
+		SortBinaryPackages: true,
+		WrapAlways:         true,
+		ShortIndent:        true,
+		TrailingComma:      true,
+	}))
+	assert(t, again.String() == canonicalizeGolden)
+}
+
+func TestCanonicalizeDefaults(t *testing.T) {
+	out := bytes.Buffer{}
+	isok(t, control.Canonicalize(strings.NewReader(canonicalizeInput), &out, control.CanonicalizeOptions{}))
+	assert(t, strings.Contains(out.String(), `
+Build-Depends: debhelper (>= 9), libx11-dev, autotools-dev, debhelper (>= 9)
+Standards-Version`))
+	assert(t, strings.Contains(out.String(), `
+Depends: ${shlibs:Depends},
+         ${misc:Depends},
+         libc6 (>= 2.4) | libc6.1,
+         fbautostart-doc (= ${source:Version})
+Suggests:
+`))
+	/* Binary packages stay where they were */
+	assert(t, strings.Index(out.String(), "Package: fbautostart-doc") < strings.Index(out.String(), "Package: fbautostart\n"))
+}
+
+func TestCanonicalizeLongLine(t *testing.T) {
+	long := strings.Repeat("x", 128*1024)
+	out := bytes.Buffer{}
+	isok(t, control.Canonicalize(
+		strings.NewReader("Source: foo\nX-Long: "+long+"\n"),
+		&out, control.CanonicalizeOptions{},
+	))
+	assert(t, strings.Contains(out.String(), "X-Long: "+long+"\n"))
+}
+
+func TestCanonicalizeInvalid(t *testing.T) {
+	notok(t, control.Canonicalize(strings.NewReader(" continued\n"), &bytes.Buffer{}, control.CanonicalizeOptions{}))
+	notok(t, control.Canonicalize(strings.NewReader("Source: foo\nnot a field\n"), &bytes.Buffer{}, control.CanonicalizeOptions{}))
+}
+
+// vim: foldmethod=marker
@@ -45,8 +45,8 @@ type FileListChangesFileHash struct {
 func (c *FileListChangesFileHash) UnmarshalControl(data string) error {
 	var err error
 	c.Algorithm = "md5"
-	vals := strings.Split(data, " ")
-	if len(data) < 5 {
+	vals := strings.Fields(data)
+	if len(vals) < 5 {
 		return fmt.Errorf("Error: Unknown File List Hash line: '%s'", data)
 	}
 
@@ -62,6 +62,10 @@ func (c *FileListChangesFileHash) UnmarshalControl(data string) error {
 	return nil
 }
 
+func (c FileListChangesFileHash) MarshalControl() (string, error) {
+	return fmt.Sprintf("%s %d %s %s %s", c.Hash, c.Size, c.Component, c.Priority, c.Filename), nil
+}
+
 // }}}
 
 // The Changes struct is the default encapsulation of the Debian .changes
@@ -214,6 +218,136 @@ func (changes *Changes) Move(dest string) error {
 	return nil
 }
 
+// A list of every problem found when checking the files listed in a
+// .changes, such as by Changes.VerifyFiles.
+type FileErrors []error
+
+func (errs FileErrors) Error() string {
+	messages := []string{}
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Check the files listed in Files and Checksums-Sha256 against the files
+// of the same name in dir, comparing both their size and hash. This is
+// the sanity check to do before an upload. If anything is off, a
+// FileErrors listing every mismatch is returned.
+func (c Changes) VerifyFiles(dir string) error {
+	hashes := []DebianFileHash{}
+	for _, file := range c.Files {
+		hashes = append(hashes, file.DebianFileHash)
+	}
+	for _, file := range c.ChecksumsSha256 {
+		hashes = append(hashes, file.DebianFileHash)
+	}
+
+	errs := FileErrors{}
+	for _, hash := range hashes {
+		hash.Filename = filepath.Join(dir, filepath.Base(hash.Filename))
+		if ok, err := hash.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", hash.Filename, err))
+		} else if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s mismatch", hash.Filename, hash.Algorithm))
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// Every hash of every file listed in the .changes, across Files and the
+// Checksums fields.
+func (c Changes) fileHashes() []DebianFileHash {
+	hashes := []DebianFileHash{}
+	for _, file := range c.Files {
+		hashes = append(hashes, file.DebianFileHash)
+	}
+	for _, file := range c.ChecksumsSha1 {
+		hashes = append(hashes, file.DebianFileHash)
+	}
+	for _, file := range c.ChecksumsSha256 {
+		hashes = append(hashes, file.DebianFileHash)
+	}
+	return hashes
+}
+
+// Check that this .changes is for the given .dsc: the Source and Version
+// have to agree, the .dsc has to be listed in Files, and the .dsc file
+// itself (at dsc.Filename) has to match every hash the .changes has for it.
+// Any other file both of them list (such as the .orig.tar.gz) must have
+// the same size and hashes in both. If anything is off, a FileErrors
+// naming every discrepancy is returned.
+func (c Changes) VerifyDSC(dsc DSC) error {
+	errs := FileErrors{}
+
+	/* binNMUs give the source version too, as in "hello (2.10-3)" */
+	if source := strings.Fields(c.Source); len(source) == 0 || source[0] != dsc.Source {
+		errs = append(errs, fmt.Errorf(
+			"Source mismatch: .changes is for '%s', .dsc is for '%s'", c.Source, dsc.Source,
+		))
+	}
+	if version.Compare(c.Version, dsc.Version) != 0 {
+		errs = append(errs, fmt.Errorf(
+			"Version mismatch: .changes is for %s, .dsc is for %s", c.Version, dsc.Version,
+		))
+	}
+
+	name := filepath.Base(dsc.Filename)
+	listed := false
+	for _, file := range c.Files {
+		if file.Filename == name {
+			listed = true
+		}
+	}
+	if dsc.Filename == "" {
+		errs = append(errs, fmt.Errorf("The .dsc has no Filename, so it can't be checked against the .changes"))
+	} else if !listed {
+		errs = append(errs, fmt.Errorf("%s isn't listed in the Files of the .changes", name))
+	}
+
+	dscHashes := map[string]DebianFileHash{}
+	for _, file := range dsc.Files {
+		dscHashes[file.Algorithm+" "+file.Filename] = file.DebianFileHash
+	}
+	for _, file := range dsc.ChecksumsSha1 {
+		dscHashes[file.Algorithm+" "+file.Filename] = file.DebianFileHash
+	}
+	for _, file := range dsc.ChecksumsSha256 {
+		dscHashes[file.Algorithm+" "+file.Filename] = file.DebianFileHash
+	}
+
+	for _, hash := range c.fileHashes() {
+		if dsc.Filename != "" && hash.Filename == name {
+			hash.Filename = dsc.Filename
+			if ok, err := hash.Validate(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", hash.Filename, err))
+			} else if !ok {
+				errs = append(errs, fmt.Errorf("%s: %s mismatch", hash.Filename, hash.Algorithm))
+			}
+			continue
+		}
+
+		other, ok := dscHashes[hash.Algorithm+" "+hash.Filename]
+		if !ok {
+			continue
+		}
+		if other.Size != hash.Size || other.Hash != hash.Hash {
+			errs = append(errs, fmt.Errorf(
+				"%s: %s and size in the .changes don't match the .dsc", hash.Filename, hash.Algorithm,
+			))
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
 // Remove the .changes file and any associated files. This function will
 // always remove the .changes last, in the event there are filesystem i/o errors
 // on removing associated files.
@@ -22,6 +22,13 @@ package control_test
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -135,4 +142,143 @@ Files:
 	assert(t, len(changes.Files) == 2)
 }
 
+func TestChangesFileHashRoundTrip(t *testing.T) {
+	// Test Changes File Lists {{{
+	input := `Source: dput-ng
+Version: 1.9
+Checksums-Sha256:
+ 2489ed1a2e052ccc4c321719a2394ac4b6958209f05b1531305d2a52173aa5c1 1131 dput-ng_1.9.dsc
+ 5ef401d9b67b009443f249aa79b952839c69a2b5437fbe957832599b655e1df0 82504 dput-ng_1.9.tar.xz
+Files:
+ a74c9e3e9fe05d480d24cd43b225ee0c 1131 devel extra dput-ng_1.9.dsc
+ 67e67e85a267c0c8110001b1a6cfc293  82504 devel extra dput-ng_1.9.tar.xz
+`
+	// }}}
+	changes, err := control.ParseChanges(bufio.NewReader(strings.NewReader(input)), "")
+	isok(t, err)
+
+	assert(t, len(changes.Files) == 2)
+	file := changes.Files[1]
+	assert(t, file.Algorithm == "md5")
+	assert(t, file.Hash == "67e67e85a267c0c8110001b1a6cfc293")
+	assert(t, file.Size == 82504)
+	assert(t, file.Component == "devel")
+	assert(t, file.Priority == "extra")
+	assert(t, file.Filename == "dput-ng_1.9.tar.xz")
+
+	assert(t, len(changes.ChecksumsSha256) == 2)
+	assert(t, changes.ChecksumsSha256[0].Algorithm == "sha256")
+	assert(t, changes.ChecksumsSha256[0].Size == 1131)
+	assert(t, changes.ChecksumsSha256[0].Filename == "dput-ng_1.9.dsc")
+
+	out := bytes.Buffer{}
+	isok(t, control.Marshal(&out, changes))
+	assert(t, strings.Contains(out.String(), "Files:\n a74c9e3e9fe05d480d24cd43b225ee0c 1131 devel extra dput-ng_1.9.dsc\n 67e67e85a267c0c8110001b1a6cfc293 82504 devel extra dput-ng_1.9.tar.xz\n"))
+	assert(t, strings.Contains(out.String(), "Checksums-Sha256:\n 2489ed1a2e052ccc4c321719a2394ac4b6958209f05b1531305d2a52173aa5c1 1131 dput-ng_1.9.dsc\n"))
+
+	_, err = control.ParseChanges(bufio.NewReader(strings.NewReader("Files:\n a74c9e3e9fe05d480d24cd43b225ee0c 1131 dput-ng_1.9.dsc\n")), "")
+	notok(t, err)
+}
+
+func TestChangesVerifyFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-debian-changes")
+	isok(t, err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"fbautostart_2.718281828-1.dsc":           "Source: fbautostart\n",
+		"fbautostart_2.718281828-1.debian.tar.xz": "not really an xz",
+	}
+	md5s, sha256s := "", ""
+	for _, name := range []string{"fbautostart_2.718281828-1.dsc", "fbautostart_2.718281828-1.debian.tar.xz"} {
+		body := files[name]
+		isok(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(body), 0644))
+		md5s += fmt.Sprintf(" %x %d misc optional %s\n", md5.Sum([]byte(body)), len(body), name)
+		sha256s += fmt.Sprintf(" %x %d %s\n", sha256.Sum256([]byte(body)), len(body), name)
+	}
+
+	changes, err := control.ParseChanges(bufio.NewReader(strings.NewReader(
+		"Source: fbautostart\nChecksums-Sha256:\n"+sha256s+"Files:\n"+md5s,
+	)), "")
+	isok(t, err)
+	isok(t, changes.VerifyFiles(dir))
+
+	/* Same size, different contents */
+	isok(t, ioutil.WriteFile(filepath.Join(dir, "fbautostart_2.718281828-1.debian.tar.xz"), []byte("not really an xy"), 0644))
+	err = changes.VerifyFiles(dir)
+	notok(t, err)
+	errs, ok := err.(control.FileErrors)
+	assert(t, ok)
+	assert(t, len(errs) == 2)
+
+	/* And a missing file is reported along with it */
+	isok(t, os.Remove(filepath.Join(dir, "fbautostart_2.718281828-1.dsc")))
+	errs = changes.VerifyFiles(dir).(control.FileErrors)
+	assert(t, len(errs) == 4)
+}
+
+func TestChangesVerifyDSC(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-debian-changes")
+	isok(t, err)
+	defer os.RemoveAll(dir)
+
+	orig := "not really a tarball"
+	origMD5 := fmt.Sprintf("%x %d", md5.Sum([]byte(orig)), len(orig))
+	origSHA256 := fmt.Sprintf("%x %d", sha256.Sum256([]byte(orig)), len(orig))
+
+	dscBody := "Source: fbautostart\nVersion: 2.718281828-1\n" +
+		"Checksums-Sha256:\n " + origSHA256 + " fbautostart_2.718281828.orig.tar.gz\n" +
+		"Files:\n " + origMD5 + " fbautostart_2.718281828.orig.tar.gz\n"
+	dscPath := filepath.Join(dir, "fbautostart_2.718281828-1.dsc")
+	isok(t, ioutil.WriteFile(dscPath, []byte(dscBody), 0644))
+	dsc, err := control.ParseDscFile(dscPath)
+	isok(t, err)
+
+	parse := func(source, version, dscMD5 string) *control.Changes {
+		changes, err := control.ParseChanges(bufio.NewReader(strings.NewReader(
+			"Source: "+source+"\nVersion: "+version+"\n"+
+				"Checksums-Sha256:\n"+
+				fmt.Sprintf(" %x %d fbautostart_2.718281828-1.dsc\n", sha256.Sum256([]byte(dscBody)), len(dscBody))+
+				" "+origSHA256+" fbautostart_2.718281828.orig.tar.gz\n"+
+				"Files:\n"+
+				" "+dscMD5+" misc optional fbautostart_2.718281828-1.dsc\n"+
+				" "+origMD5+" misc optional fbautostart_2.718281828.orig.tar.gz\n",
+		)), "")
+		isok(t, err)
+		return changes
+	}
+	goodMD5 := fmt.Sprintf("%x %d", md5.Sum([]byte(dscBody)), len(dscBody))
+
+	isok(t, parse("fbautostart", "2.718281828-1", goodMD5).VerifyDSC(*dsc))
+
+	/* Wrong source and version */
+	err = parse("fbautostop", "2.718281828-2", goodMD5).VerifyDSC(*dsc)
+	notok(t, err)
+	errs := err.(control.FileErrors)
+	assert(t, len(errs) == 2)
+	assert(t, strings.Contains(errs[0].Error(), "fbautostop"))
+	assert(t, strings.Contains(errs[1].Error(), "2.718281828-2"))
+
+	/* The .dsc hash is off */
+	badMD5 := fmt.Sprintf("%x %d", md5.Sum([]byte("nope")), len(dscBody))
+	errs = parse("fbautostart", "2.718281828-1", badMD5).VerifyDSC(*dsc).(control.FileErrors)
+	assert(t, len(errs) == 1)
+	assert(t, strings.Contains(errs[0].Error(), "md5 mismatch"))
+
+	/* And the .dsc disagrees with the .changes about the .orig.tar.gz */
+	other := *dsc
+	other.ChecksumsSha256 = append([]control.SHA256DebianFileHash{}, dsc.ChecksumsSha256...)
+	other.ChecksumsSha256[0].Hash = strings.Repeat("0", 64)
+	errs = parse("fbautostart", "2.718281828-1", goodMD5).VerifyDSC(other).(control.FileErrors)
+	assert(t, len(errs) == 1)
+	assert(t, strings.Contains(errs[0].Error(), "orig.tar.gz: sha256"))
+
+	/* Not listed at all */
+	other = *dsc
+	other.Filename = filepath.Join(dir, "fbautostart_2.718281828-2.dsc")
+	errs = parse("fbautostart", "2.718281828-1", goodMD5).VerifyDSC(other).(control.FileErrors)
+	assert(t, len(errs) == 1)
+	assert(t, strings.Contains(errs[0].Error(), "isn't listed"))
+}
+
 // vim: foldmethod=marker
@@ -0,0 +1,88 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pault.ag/go/debian/internal/decompress"
+)
+
+// A Decompressor turns a compressed stream, such as a Packages.xz index,
+// into a reader over the uncompressed data. It's the same type as
+// deb.Decompressor.
+type Decompressor = decompress.Decompressor
+
+// DecompressorFunc adapts a plain function into a Decompressor. It's the
+// same type as deb.DecompressorFunc.
+type DecompressorFunc = decompress.Func
+
+// Register a Decompressor for files ending in the given suffix (such as
+// ".zst"), replacing any existing one for it. gzip, bzip2 and xz are
+// supported out of the box. This is the same registry the deb package
+// uses for .deb members, so a codec registered here (or with
+// deb.RegisterDecompressor) works for both.
+func RegisterDecompressor(suffix string, decompressor Decompressor) {
+	decompress.Register(suffix, decompressor)
+}
+
+// Wrap reader in whatever decompresses a file called name, going by its
+// extension, such as Packages.gz or Sources.xz, so that it can be handed
+// to Unmarshal or NewDecoder. Names without a compression suffix (such as
+// Packages, or InRelease) get reader back as-is. Compression suffixes
+// without a decompressor (see RegisterDecompressor) are an error.
+func DecompressReader(name string, reader io.Reader) (io.Reader, error) {
+	suffix := filepath.Ext(name)
+	if decompressor, ok := decompress.Lookup(suffix); ok {
+		return decompressor.Decompress(reader)
+	}
+
+	switch strings.ToLower(suffix) {
+	case ".lzma", ".zst", ".lz4", ".lz":
+		return nil, fmt.Errorf(
+			"pault.ag/go/debian/control: no decompressor registered for %s files (%s)",
+			suffix, name,
+		)
+	}
+	return reader, nil
+}
+
+// Unmarshal the file at path into into, the same as Unmarshal, after
+// decompressing it as per DecompressReader.
+func DecodeFromFile(path string, into interface{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader, err := DecompressReader(path, f)
+	if err != nil {
+		return err
+	}
+	return Unmarshal(into, reader)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,122 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pault.ag/go/debian/control"
+)
+
+/*
+ *
+ */
+
+// Test Packages Index {{{
+const compressPackages = `Package: foo
+Version: 1.0-1
+Architecture: amd64
+
+Package: bar
+Version: 2.0-1
+Architecture: all
+`
+
+// }}}
+
+// Test Packages Index, as xz -9 {{{
+const compressPackagesXz = `/Td6WFoAAATm1rRGBMBIXyEBHAAAAAAAAAAAAKauZJ/gAF4AQF0AKBhIZtvaMIX+
+FuOK81AWYt8aqvgzKbHwTeRk+RmgLNj8KADI7NnttGiQSNLehAdAVtVix5f75iYD
+99G20whsIADdw8tXL+xJ+QABZF/tV8PaH7bzfQEAAAAABFla`
+
+// }}}
+
+func TestDecodeFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-debian-compress")
+	isok(t, err)
+	defer os.RemoveAll(dir)
+
+	compressed := bytes.Buffer{}
+	writer := gzip.NewWriter(&compressed)
+	_, err = writer.Write([]byte(compressPackages))
+	isok(t, err)
+	isok(t, writer.Close())
+
+	isok(t, ioutil.WriteFile(filepath.Join(dir, "Packages.gz"), compressed.Bytes(), 0644))
+	isok(t, ioutil.WriteFile(filepath.Join(dir, "Packages"), []byte(compressPackages), 0644))
+	xzData, err := base64.StdEncoding.DecodeString(strings.Replace(compressPackagesXz, "\n", "", -1))
+	isok(t, err)
+	isok(t, ioutil.WriteFile(filepath.Join(dir, "Packages.xz"), xzData, 0644))
+	isok(t, ioutil.WriteFile(filepath.Join(dir, "Packages.zst"), []byte("not really a zstd"), 0644))
+
+	for _, name := range []string{"Packages.gz", "Packages.xz", "Packages"} {
+		packages := []control.BinaryIndex{}
+		isok(t, control.DecodeFromFile(filepath.Join(dir, name), &packages))
+		assert(t, len(packages) == 2)
+		assert(t, packages[0].Package == "foo")
+		assert(t, packages[1].Package == "bar")
+	}
+
+	/* No zstd out of the box */
+	packages := []control.BinaryIndex{}
+	notok(t, control.DecodeFromFile(filepath.Join(dir, "Packages.zst"), &packages))
+}
+
+func TestRegisterDecompressor(t *testing.T) {
+	/* Not a real format, just something to plug in */
+	control.RegisterDecompressor(".rev", control.DecompressorFunc(func(in io.Reader) (io.Reader, error) {
+		data, err := ioutil.ReadAll(in)
+		if err != nil {
+			return nil, err
+		}
+		for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+			data[i], data[j] = data[j], data[i]
+		}
+		return bytes.NewReader(data), nil
+	}))
+
+	reversed := []byte(compressPackages)
+	for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+		reversed[i], reversed[j] = reversed[j], reversed[i]
+	}
+
+	reader, err := control.DecompressReader("Packages.rev", bytes.NewReader(reversed))
+	isok(t, err)
+	packages := []control.BinaryIndex{}
+	isok(t, control.Unmarshal(&packages, reader))
+	assert(t, len(packages) == 2)
+
+	/* Plain readers go through untouched */
+	plain := strings.NewReader(compressPackages)
+	reader, err = control.DecompressReader("InRelease", plain)
+	isok(t, err)
+	assert(t, reader == plain)
+}
+
+// vim: foldmethod=marker
@@ -53,7 +53,7 @@ type SourceParagraph struct {
 	Paragraph
 
 	Maintainer  string
-	Uploaders   []string `delim:","`
+	Uploaders   []string `delim:"," strip:"\n\r\t " fold:"true"`
 	Source      string
 	Priority    string
 	Section     string
@@ -77,18 +77,20 @@ func (s *SourceParagraph) Maintainers() []string {
 // after it's built on a given Arch.
 type BinaryParagraph struct {
 	Paragraph
-	Architectures []dependency.Arch `control:"Architecture"`
-	Package       string
-	Priority      string
-	Section       string
-	Essential     bool
-	Description   string
+	Architectures  []dependency.Arch `control:"Architecture"`
+	Package        string
+	Priority       string
+	Section        string
+	Essential      bool `default:"no"`
+	BuildEssential bool `control:"Build-Essential" default:"no"`
+	Description    string
 
 	Depends    dependency.Dependency
 	Recommends dependency.Dependency
 	Suggests   dependency.Dependency
 	Enhances   dependency.Dependency
 	PreDepends dependency.Dependency `control:"Pre-Depends"`
+	Provides   dependency.Dependency
 
 	Breaks    dependency.Dependency
 	Conflicts dependency.Dependency
@@ -135,7 +137,8 @@ func ParseControlFile(path string) (ret *Control, err error) {
 }
 
 // Given a bufio.Reader, consume the Reader, and return a Control object
-// for use.
+// for use. Unlike everywhere else, lines starting with a # are comments
+// in debian/control, and are dropped.
 func ParseControl(reader *bufio.Reader, path string) (*Control, error) {
 	ret := Control{
 		Filename: path,
@@ -143,10 +146,12 @@ func ParseControl(reader *bufio.Reader, path string) (*Control, error) {
 		Source:   SourceParagraph{},
 	}
 
-	if err := Unmarshal(&ret.Source, reader); err != nil {
+	decoder := NewDecoder(reader)
+	decoder.parser.comments = true
+	if err := decoder.Decode(&ret.Source); err != nil {
 		return nil, err
 	}
-	if err := Unmarshal(&ret.Binaries, reader); err != nil {
+	if err := decodeSlice(decoder, &ret.Binaries); err != nil {
 		return nil, err
 	}
 
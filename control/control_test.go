@@ -123,9 +123,92 @@ Description: XDG compliant autostarting app for Fluxbox
 	assert(t, c != nil)
 	assert(t, len(c.Binaries) == 2)
 	assert(t, len(c.Source.Maintainers()) == 3)
+	assert(t, c.Source.Uploaders[1] == "Foo Bar <fnord@baz.fnord>")
 
 	arches := c.Binaries[1].Architectures
 	assert(t, len(arches) == 3)
 }
 
+func TestCommentedControlParse(t *testing.T) {
+	// Test Control {{{
+	reader := bufio.NewReader(strings.NewReader(`# This is debian/control for fbautostart
+Source: fbautostart
+Maintainer: Paul Tagliamonte <paultag@ubuntu.com>
+Build-Depends: debhelper (>= 9),
+# Only needed for the test suite
+ python3,
+#  libfoo-dev (>= 1.0),
+ pkg-config
+Standards-Version: 3.9.3
+
+# The one and only binary
+Package: fbautostart
+Architecture: any
+Depends: ${shlibs:Depends}, ${misc:Depends}
+`))
+	// }}}
+	c, err := control.ParseControl(reader, "")
+	isok(t, err)
+	assert(t, len(c.Binaries) == 1)
+	assert(t, c.Source.Source == "fbautostart")
+	assert(t, c.Source.Values["Standards-Version"] == "3.9.3")
+
+	depends := c.Source.BuildDepends
+	assert(t, len(depends.Relations) == 3)
+	assert(t, depends.Relations[0].Possibilities[0].Name == "debhelper")
+	assert(t, depends.Relations[1].Possibilities[0].Name == "python3")
+	assert(t, depends.Relations[2].Possibilities[0].Name == "pkg-config")
+
+	assert(t, c.Binaries[0].Package == "fbautostart")
+
+	/* Anywhere else, a # line is just a malformed field */
+	para := control.SourceParagraph{}
+	err = control.Unmarshal(&para, strings.NewReader(`Source: fbautostart
+Build-Depends: debhelper (>= 9),
+# Only needed for the test suite
+ python3
+`))
+	notok(t, err)
+	assert(t, strings.Contains(err.Error(), "no colon found"))
+}
+
+func TestEssentialControlParse(t *testing.T) {
+	// Test Control {{{
+	reader := bufio.NewReader(strings.NewReader(`Source: base-files
+Maintainer: Santiago Vila <sanvila@debian.org>
+
+Package: base-files
+Architecture: any
+Essential: yes
+
+Package: base-files-doc
+Architecture: all
+Essential: no
+Build-Essential: Yes
+
+Package: base-files-dev
+Architecture: all
+`))
+	// }}}
+	c, err := control.ParseControl(reader, "")
+	isok(t, err)
+	assert(t, len(c.Binaries) == 3)
+
+	assert(t, c.Binaries[0].Essential)
+	assert(t, !c.Binaries[0].BuildEssential)
+
+	assert(t, !c.Binaries[1].Essential)
+	assert(t, c.Binaries[1].BuildEssential)
+
+	assert(t, !c.Binaries[2].Essential)
+	assert(t, !c.Binaries[2].BuildEssential)
+
+	_, err = control.ParseControl(bufio.NewReader(strings.NewReader(`Source: base-files
+
+Package: base-files
+Essential: maybe
+`)), "")
+	notok(t, err)
+}
+
 // vim: foldmethod=marker
@@ -24,11 +24,39 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 )
 
+var (
+	normalizersLock sync.RWMutex
+	normalizers     = map[string][]func(string) string{}
+)
+
+// Register a function to clean up the value of the given control key (such
+// as "Architecture") before it's decoded into a struct field, for instance
+// to lowercase or trim it. This applies to every decode in the process.
+// If more than one normalizer is registered for a key, they're run in the
+// order they were registered, each getting the output of the last. The raw
+// values in the struct's Paragraph (if any) are left as they were read.
+func RegisterNormalizer(key string, fn func(string) string) {
+	normalizersLock.Lock()
+	defer normalizersLock.Unlock()
+	normalizers[key] = append(normalizers[key], fn)
+}
+
+func normalize(key, value string) string {
+	normalizersLock.RLock()
+	defer normalizersLock.RUnlock()
+	for _, fn := range normalizers[key] {
+		value = fn(value)
+	}
+	return value
+}
+
 func decodeCustomValues(incoming reflect.Value, incomingField reflect.StructField, data string) error {
 	/* Incoming is a slice */
 	underlyingType := incoming.Type().Elem()
@@ -47,7 +75,19 @@ func decodeCustomValues(incoming reflect.Value, incomingField reflect.StructFiel
 		data = strings.Trim(data, strip)
 	}
 
-	for _, el := range strings.Split(data, delim) {
+	if data == "" {
+		/* An empty field is an empty list, not a list of one empty value */
+		return nil
+	}
+
+	var elements []string
+	if delim == "auto" {
+		elements = ParseList(data)
+	} else {
+		elements = splitList(data, delim)
+	}
+
+	for _, el := range elements {
 		if strip != "" {
 			el = strings.Trim(el, strip)
 		}
@@ -62,6 +102,138 @@ func decodeCustomValues(incoming reflect.Value, incomingField reflect.StructFiel
 	return nil
 }
 
+// Split a delimited field into its elements. Comma separated lists (such as
+// Uploaders) may have commas inside of a double-quoted element, as in
+// `"Doe, Jane" <jdoe@example.com>`, or inside of parens, as in a comment
+// like `(lead, go)`, so those don't split the element. Inside of double
+// quotes, a backslash escapes the character after it, such as a \".
+func splitList(data, delim string) []string {
+	if delim != "," {
+		return strings.Split(data, delim)
+	}
+
+	ret := []string{}
+	quoted := false
+	escaped := false
+	parens := 0
+	start := 0
+	for i, r := range data {
+		if escaped {
+			escaped = false
+			continue
+		}
+		switch r {
+		case '\\':
+			escaped = quoted
+		case '"':
+			quoted = !quoted
+		case '(':
+			if !quoted {
+				parens++
+			}
+		case ')':
+			if !quoted && parens > 0 {
+				parens--
+			}
+		case ',':
+			if !quoted && parens == 0 {
+				ret = append(ret, data[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(ret, data[start:])
+}
+
+// Split a list field whose producers don't agree on a separator, which is
+// what the `delim:"auto"` struct tag uses. If the value contains a comma
+// (outside of double quotes, as with splitList), it's split on commas;
+// otherwise it's split on runs of whitespace (spaces, tabs and newlines).
+// Each element has its surrounding whitespace trimmed, and empty elements
+// (such as from a trailing comma) are dropped.
+func ParseList(value string) []string {
+	elements := splitList(value, ",")
+	if len(elements) == 1 {
+		return strings.Fields(value)
+	}
+
+	ret := []string{}
+	for _, el := range elements {
+		if el = strings.TrimSpace(el); el != "" {
+			ret = append(ret, el)
+		}
+	}
+	return ret
+}
+
+// Parse a size such as "1024", "1K" or "1.5M" into a count of bytes. The
+// K, M and G suffixes (in either case) are powers of 1024, as with du(1),
+// and only sizes with a suffix may have a fraction. Signs, exponents and
+// underscores aren't allowed. This is only used for fields tagged with
+// `unit:"bytes"`; the fields defined by policy (such as Size) are plain
+// integers, and are kept strict.
+func parseByteSize(data string) (int64, error) {
+	number, multiplier := data, int64(1)
+	if data != "" {
+		switch data[len(data)-1] {
+		case 'K', 'k':
+			multiplier = 1 << 10
+		case 'M', 'm':
+			multiplier = 1 << 20
+		case 'G', 'g':
+			multiplier = 1 << 30
+		}
+	}
+	if multiplier != 1 {
+		number = data[:len(data)-1]
+	}
+
+	/* ParseInt and ParseFloat are a lot more lenient than we'd like,
+	 * taking signs, underscores, exponents, NaN and Inf, so check the
+	 * digits by hand first */
+	parts := strings.SplitN(number, ".", 2)
+	if !isDigits(parts[0]) || (len(parts) == 2 && (multiplier == 1 || !isDigits(parts[1]))) {
+		return 0, fmt.Errorf("'%s' is not a size", data)
+	}
+	if multiplier == 1 {
+		return strconv.ParseInt(number, 10, 64)
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, err
+	}
+	size := value * float64(multiplier)
+	if size >= math.MaxInt64 {
+		return 0, fmt.Errorf("Size '%s' is too big", data)
+	}
+	return int64(size), nil
+}
+
+func isDigits(data string) bool {
+	if data == "" {
+		return false
+	}
+	for _, c := range data {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse a yes/no field, such as Essential. Spelling doesn't matter, and
+// true/false and 1/0 are fine too. An empty value is false.
+func parseBool(data string) (bool, error) {
+	switch strings.ToLower(data) {
+	case "yes", "true", "1":
+		return true, nil
+	case "no", "false", "0", "":
+		return false, nil
+	}
+	return false, fmt.Errorf("'%s' is not yes or no", data)
+}
+
 func decodeCustomValue(incoming reflect.Value, incomingField reflect.StructField, data string) error {
 	/* Right, so, we've got a type we don't know what to do with. We should
 	 * grab the method, or throw a shitfit. */
@@ -78,29 +250,162 @@ func decodeCustomValue(incoming reflect.Value, incomingField reflect.StructField
 }
 
 func decodeValue(incoming reflect.Value, incomingField reflect.StructField, data string) error {
+	if codec, ok := lookupType(incoming.Type()); ok && codec.decode != nil {
+		value, err := codec.decode(data)
+		if err != nil {
+			return err
+		}
+		decoded := reflect.ValueOf(value)
+		if !decoded.IsValid() || !decoded.Type().AssignableTo(incoming.Type()) {
+			return fmt.Errorf(
+				"Decoder registered for %s returned a %T",
+				incoming.Type(), value,
+			)
+		}
+		incoming.Set(decoded)
+		return nil
+	}
+
+	if incoming.Type().Kind() != reflect.Struct && incoming.CanAddr() {
+		/* Named non-struct types (such as Tags) can unpack themselves too */
+		if unmarshal, ok := incoming.Addr().Interface().(Unmarshalable); ok {
+			return unmarshal.UnmarshalControl(data)
+		}
+	}
+
 	switch incoming.Type().Kind() {
 	case reflect.String:
+		data, _ = canonicalEnum(incoming.Type(), data)
 		incoming.SetString(data)
 		return nil
-	case reflect.Int:
+	case reflect.Bool:
+		value, err := parseBool(data)
+		if err != nil {
+			return err
+		}
+		incoming.SetBool(value)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		if data == "" {
 			incoming.SetInt(0)
 			return nil
 		}
-		value, err := strconv.Atoi(data)
+		if incomingField.Tag.Get("unit") == "bytes" {
+			value, err := parseByteSize(data)
+			if err != nil {
+				return err
+			}
+			if incoming.OverflowInt(value) {
+				return fmt.Errorf("Size '%s' is too big for a %s", data, incoming.Type())
+			}
+			incoming.SetInt(value)
+			return nil
+		}
+		value, err := strconv.ParseInt(data, 10, incoming.Type().Bits())
 		if err != nil {
 			return err
 		}
-		incoming.SetInt(int64(value))
+		incoming.SetInt(value)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if data == "" {
+			incoming.SetUint(0)
+			return nil
+		}
+		if incomingField.Tag.Get("unit") == "bytes" {
+			value, err := parseByteSize(data)
+			if err != nil {
+				return err
+			}
+			if value < 0 || incoming.OverflowUint(uint64(value)) {
+				return fmt.Errorf("Size '%s' doesn't fit in a %s", data, incoming.Type())
+			}
+			incoming.SetUint(uint64(value))
+			return nil
+		}
+		value, err := strconv.ParseUint(data, 10, incoming.Type().Bits())
+		if err != nil {
+			return err
+		}
+		incoming.SetUint(value)
 		return nil
 	case reflect.Slice:
 		return decodeCustomValues(incoming, incomingField, data)
 	case reflect.Struct:
 		return decodeCustomValue(incoming, incomingField, data)
+	case reflect.Interface:
+		return decodeInterface(incoming, incomingField, data)
+	case reflect.Ptr:
+		/* Optional values (such as *version.Version) only get allocated
+		 * if the key is actually there */
+		target := reflect.New(incoming.Type().Elem())
+		if err := decodeValue(target.Elem(), incomingField, data); err != nil {
+			return err
+		}
+		incoming.Set(target)
+		return nil
 	}
 	return fmt.Errorf("Unknown type of field: %s", incoming.Type())
 }
 
+// Return the control key a struct field maps to, which is the name of the
+// field, unless overridden with the `control:""` tag. Anything after a
+// comma in the tag is an option (such as omitempty), not part of the key.
+func controlKey(fieldType reflect.StructField) string {
+	key, _ := parseControlTag(fieldType)
+	if key != "" {
+		return key
+	}
+	return fieldType.Name
+}
+
+// Split the `control:""` tag into the key and its options, so that
+// `control:"Homepage,omitempty"` gives "Homepage" and ["omitempty"]. The
+// key may be empty, as in `control:",omitempty"`, to keep the field name.
+func parseControlTag(fieldType reflect.StructField) (string, []string) {
+	els := strings.Split(fieldType.Tag.Get("control"), ",")
+	return els[0], els[1:]
+}
+
+// Check to see if the `control:""` tag of the field has the given option.
+func hasControlOption(fieldType reflect.StructField, option string) bool {
+	_, options := parseControlTag(fieldType)
+	for _, it := range options {
+		if it == option {
+			return true
+		}
+	}
+	return false
+}
+
+// Check to see if a struct member is unpacked from its own key (because it's
+// Unmarshalable, or a registered type), rather than being walked into.
+func unpacksItself(field reflect.Value) bool {
+	if _, ok := lookupType(field.Type()); ok {
+		return true
+	}
+	if !field.CanAddr() || !field.CanInterface() {
+		return false
+	}
+	_, ok := field.Addr().Interface().(Unmarshalable)
+	return ok
+}
+
+// Look up the value of key in the Paragraph. Field names aren't case
+// sensitive, so if there's no exact match, any key that only differs in
+// case will do.
+func lookupValue(data Paragraph, key string) (string, bool) {
+	if val, ok := data.Values[key]; ok {
+		return val, true
+	}
+	for _, other := range data.Order {
+		if strings.EqualFold(other, key) {
+			return data.Values[other], true
+		}
+	}
+	return "", false
+}
+
 func decodePointer(incoming reflect.Value, data Paragraph) error {
 	if incoming.Type().Kind() == reflect.Ptr {
 		/* If we have a pointer, let's follow it */
@@ -111,26 +416,30 @@ func decodePointer(incoming reflect.Value, data Paragraph) error {
 		field := incoming.Field(i)
 		fieldType := incoming.Type().Field(i)
 
-		if field.Type().Kind() == reflect.Struct {
+		if field.Type().Kind() == reflect.Struct && !unpacksItself(field) {
 			err := decodePointer(field, data)
 			if err != nil {
 				return err
 			}
 		}
 
-		paragraphKey := fieldType.Name
-		if it := fieldType.Tag.Get("control"); it != "" {
-			paragraphKey = it
-		}
+		paragraphKey := controlKey(fieldType)
 
-		if paragraphKey == "-" {
+		if paragraphKey == "-" || isExtra(fieldType) {
 			continue
 		}
 
 		required := fieldType.Tag.Get("required") == "true"
 
-		if val, ok := data.Values[paragraphKey]; ok {
-			err := decodeValue(field, fieldType, val)
+		val, ok := lookupValue(data, paragraphKey)
+		if required && strings.TrimSpace(val) == "" {
+			/* A key with nothing but whitespace after it is as good as
+			 * missing, as far as required fields go */
+			ok = false
+		}
+
+		if ok {
+			err := decodeValue(field, fieldType, normalize(paragraphKey, val))
 			if err != nil {
 				return fmt.Errorf(
 					"pault.ag/go/debian/control: failed to set %s: %s",
@@ -144,6 +453,15 @@ func decodePointer(incoming reflect.Value, data Paragraph) error {
 				fieldType.Name,
 			)
 
+		} else if def := fieldType.Tag.Get("default"); def != "" {
+			err := decodeValue(field, fieldType, def)
+			if err != nil {
+				return fmt.Errorf(
+					"pault.ag/go/debian/control: failed to set default of %s: %s",
+					fieldType.Name,
+					err,
+				)
+			}
 		}
 	}
 
@@ -155,23 +473,44 @@ func decodePointer(incoming reflect.Value, data Paragraph) error {
 // struct as needed. If a list of structs is given, unpack all RFC822
 // Paragraphs into the structs.
 //
+// Members tagged `required:"true"` have to be in the Paragraph, or an
+// error is returned. A key whose value is empty (or only whitespace,
+// which is trimmed off) counts as missing. Members tagged with a
+// `default:""` are unpacked from that value when the key isn't there.
+//
+// Members of type bool are unpacked from yes/no fields (such as
+// Essential); true/false and 1/0 work too, and an empty value is false.
+//
 // This code will attempt to unpack it into the struct based on the
-// literal name of the key, compared byte-for-byte. If this is not
-// OK, the struct tag `control:""` can be used to define the key to use
-// in the RFC822 stream.
+// literal name of the key, which, as in Debian policy, isn't case
+// sensitive (so "installed-size" still ends up in Installed-Size). If the
+// name isn't right, the struct tag `control:""` can be used to define the
+// key to use in the RFC822 stream.
 //
 // If you're unpacking into a list of strings, you have the option of defining
 // a string to split tokens on (`delim:", "`), and things to strip off each
-// element (`strip:"\n\r\t "`).
+// element (`strip:"\n\r\t "`). If the separator isn't known ahead of time,
+// `delim:"auto"` will split on commas or whitespace, as ParseList does.
+// Each element is unpacked just like a member of that type would be, so a
+// list of Unmarshalable types (or pointers to them) gets UnmarshalControl
+// called once per element, which is how the Files field works.
+//
+// Relation fields (such as Depends) may be unpacked into a [][]string, which
+// holds the names of each set of alternatives, dropping everything else.
 //
 // If you're unpacking into a struct, the struct will be walked acording to
 // the rules above. If you wish to override how this writes to the nested
 // struct, objects that implement the Unmarshalable interface will be
-// Unmarshaled via that method call only.
+// Unmarshaled via that method call only. Fields of an interface type are
+// filled in with whatever concrete type was registered for that interface
+// and key with RegisterInterface. Enum types registered with RegisterEnum
+// have their values stored in the registered spelling.
 //
 // Structs that contain Paragraph as an Anonymous member will have that
 // member populated with the parsed RFC822 block, to allow access to the
-// .Values and .Order members.
+// .Values and .Order members. A map[string]string member tagged
+// `control:",extra"` is filled in with every key that no other member
+// maps to, such as X- fields, which Marshal writes back out.
 func Unmarshal(incoming interface{}, data io.Reader) error {
 	/* Dispatch if incoming is a slice or not */
 	val := reflect.ValueOf(incoming)
@@ -194,6 +533,30 @@ func Unmarshal(incoming interface{}, data io.Reader) error {
 	}
 }
 
+// Decode up to n Paragraphs out of the io.Reader into the slice of structs
+// that into points to, which is handy for taking a peek at the start of a
+// huge index. If there are fewer than n Paragraphs, all of them are read,
+// and that's not an error. Nothing past the nth Paragraph is decoded.
+func ReadN(reader io.Reader, n int, into interface{}) error {
+	val := reflect.ValueOf(into)
+	if val.Type().Kind() != reflect.Ptr || val.Elem().Type().Kind() != reflect.Slice {
+		return fmt.Errorf("Ouchie! Please give me a pointer to a slice!")
+	}
+
+	slice := val.Elem()
+	decoder := NewDecoder(reader)
+	for i := 0; i < n; i++ {
+		target := reflect.New(slice.Type().Elem())
+		if err := decoder.Decode(target.Interface()); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		slice.Set(reflect.Append(slice, target.Elem()))
+	}
+	return nil
+}
+
 // The Unmarshalable interface defines the interface that Unmarshal will use
 // to do custom unpacks into Structs.
 //
@@ -204,14 +567,21 @@ type Unmarshalable interface {
 }
 
 func unmarshalSlice(incoming interface{}, data io.Reader) error {
-	/* Good holy hot damn this code is ugly */
+	/* One Decoder for the lot, so that nothing buffered up while reading
+	 * one Paragraph is lost when reading the next */
+	return decodeSlice(NewDecoder(data), incoming)
+}
+
+// Decode every Paragraph left in the Decoder's stream onto the end of the
+// slice of structs that incoming points to.
+func decodeSlice(decoder *Decoder, incoming interface{}) error {
 	for {
 		val := reflect.ValueOf(incoming)
 		flavor := val.Elem().Type().Elem()
 
 		targetValue := reflect.New(flavor)
 		target := targetValue.Interface()
-		err := unmarshalStruct(target, data)
+		err := decoder.Decode(target)
 
 		if err == io.EOF {
 			break
@@ -236,14 +606,10 @@ func isParagraph(incoming reflect.Value) (int, bool) {
 	return -1, false
 }
 
-func unmarshalStruct(incoming interface{}, data io.Reader) error {
-	reader := bufio.NewReader(data)
-	para, err := ParseParagraph(reader)
-	if err != nil {
-		return err
-	}
-	if para == nil {
-		return io.EOF
+func decodeParagraph(incoming interface{}, para Paragraph) error {
+	if target, ok := incoming.(*Paragraph); ok {
+		*target = para
+		return nil
 	}
 
 	val := reflect.ValueOf(incoming).Elem()
@@ -251,10 +617,162 @@ func unmarshalStruct(incoming interface{}, data io.Reader) error {
 	 * the object */
 	if index, is := isParagraph(val); is {
 		/* If we're a Paragraph, let's go ahead and set the index. */
-		val.Field(index).Set(reflect.ValueOf(*para))
+		val.Field(index).Set(reflect.ValueOf(para))
 	}
 
-	return decodePointer(reflect.ValueOf(incoming), *para)
+	if err := decodePointer(reflect.ValueOf(incoming), para); err != nil {
+		return err
+	}
+	return decodeExtra(val, para)
+}
+
+func unmarshalStruct(incoming interface{}, data io.Reader) error {
+	return NewDecoder(data).Decode(incoming)
+}
+
+// A Decoder reads RFC822-alike Debian control-file Paragraphs off an
+// io.Reader one at a time, which is handy for large files such as
+// Packages or Sources.
+type Decoder struct {
+	parser paragraphParser
+	strict bool
+
+	/* Offset of the Paragraph last returned by Decode */
+	offset int64
+	/* How many Paragraphs Decode has returned */
+	count int
+
+	/* The next Paragraph, if More has already read it */
+	peeked bool
+	next   readParagraph
+}
+
+type readParagraph struct {
+	para   *Paragraph
+	offset int64
+	line   int
+	err    error
+}
+
+// Create a new Decoder, which will read from the given io.Reader.
+func NewDecoder(reader io.Reader) *Decoder {
+	return &Decoder{parser: paragraphParser{reader: bufio.NewReader(reader)}}
+}
+
+// Decode the next Paragraph in the stream into the given pointer to a
+// struct, using the same rules as Unmarshal. A *Paragraph may be passed in
+// as well, to get at the raw Paragraph. Once there are no more
+// Paragraphs left, io.EOF is returned.
+//
+// Errors from parsing name the line of the stream they're on, and errors
+// from decoding (such as a missing required field) come back as a
+// DecodeError, naming the Paragraph by its 0-based index in the stream,
+// and the line it starts on.
+func (d *Decoder) Decode(incoming interface{}) error {
+	val := reflect.ValueOf(incoming)
+	if val.Type().Kind() != reflect.Ptr || val.Elem().Type().Kind() != reflect.Struct {
+		return fmt.Errorf("Ouchie! Please give me a pointer to a struct!")
+	}
+
+	next := d.read()
+	if next.err != nil {
+		return next.err
+	}
+	if next.para == nil {
+		return io.EOF
+	}
+	d.offset = next.offset
+	index := d.count
+	d.count++
+
+	err := decodeParagraph(incoming, *next.para)
+	if err == nil && d.strict {
+		err = validateEnums(val)
+	}
+	if err != nil {
+		return DecodeError{Paragraph: index, Line: next.line, Err: err}
+	}
+	return nil
+}
+
+// Returned by Decode when a Paragraph was read alright, but couldn't be
+// decoded into the struct (such as when a required field is missing).
+// Paragraph is its 0-based index in the stream, and Line the line it
+// starts on; Err is what went wrong.
+type DecodeError struct {
+	Paragraph int
+	Line      int
+	Err       error
+}
+
+func (err DecodeError) Error() string {
+	return fmt.Sprintf("%s (in paragraph %d, on line %d)", err.Err, err.Paragraph, err.Line)
+}
+
+func (err DecodeError) Unwrap() error {
+	return err.Err
+}
+
+// Check to see if there's another Paragraph left in the stream, so that
+// Decode can be called in a `for decoder.More()` loop. If reading the next
+// Paragraph fails, More returns true, and the error comes out of Decode.
+func (d *Decoder) More() bool {
+	if !d.peeked {
+		d.next = d.read()
+		d.peeked = true
+	}
+	return d.next.para != nil || d.next.err != nil
+}
+
+func (d *Decoder) read() readParagraph {
+	if d.peeked {
+		d.peeked = false
+		return d.next
+	}
+	para, err := d.parser.parse()
+	return readParagraph{para, d.parser.start, d.parser.startLine, err}
+}
+
+// In strict mode, the Decoder returns an error for values that decoded
+// fine, but aren't allowed, which is currently values of enum types (see
+// RegisterEnum) that aren't one of the registered values, and anything
+// Validatable that doesn't check out, such as a SourceFormat (or the
+// Format of a DSC) that isn't a format dpkg knows about.
+func (d *Decoder) SetStrict(strict bool) {
+	d.strict = strict
+}
+
+// Limit how long any one line, or the (folded) value of any one field may
+// be, in bytes. Decode returns an error once the limit is hit, without
+// reading the rest of the line into memory first. This is worth setting
+// when reading untrusted input; the default of 0 means no limit at all.
+func (d *Decoder) SetMaxFieldLength(n int) {
+	d.parser.maxLength = n
+}
+
+// Return the number of blank lines that followed the last Paragraph in the
+// stream. This is only known once Decode has returned io.EOF, and can be
+// handed to Encoder.SetTrailingBlankLines to write a file back out exactly
+// the way it came in.
+func (d *Decoder) TrailingBlankLines() int {
+	return d.parser.trailing
+}
+
+// Return whether the last line in the stream ended in a newline. As with
+// TrailingBlankLines, this is only known once Decode has returned io.EOF,
+// and can be handed to Encoder.SetFinalNewline.
+func (d *Decoder) FinalNewline() bool {
+	return !d.parser.unterminated
+}
+
+// Return the byte offset into the stream at which the Paragraph most
+// recently returned by Decode started. This can be used to build an index
+// of where each Paragraph lives, and Seek straight to it later.
+//
+// For OpenPGP signed input, the whole signed block is treated as a single
+// Paragraph starting at the armor header.
+func (d *Decoder) Offset() int64 {
+	return d.offset
 }
 
 // vim: foldmethod=marker
@@ -1,9 +1,14 @@
 package control_test
 
 import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
 	"strings"
 	"testing"
 
+	"golang.org/x/crypto/openpgp/clearsign"
 	"pault.ag/go/debian/control"
 	"pault.ag/go/debian/dependency"
 	"pault.ag/go/debian/version"
@@ -37,9 +42,34 @@ Foo-Bar: baz
 
 Value: Bar
 
-Value Baz
+Value: Baz
 `)))
+	assert(t, len(foo) == 3)
 	assert(t, foo[0].Value == "foo")
+	assert(t, foo[1].Value == "Bar")
+	assert(t, foo[2].Value == "Baz")
+
+	/* A broken Paragraph anywhere in the stream is an error */
+	foo = []TestStruct{}
+	notok(t, control.Unmarshal(&foo, strings.NewReader(`Value: foo
+
+Value Baz
+`)))
+}
+
+func TestRequiredWhitespaceUnmarshal(t *testing.T) {
+	foo := TestStruct{}
+
+	/* Only whitespace is the same as not being there */
+	err := control.Unmarshal(&foo, strings.NewReader("Value:    \nValue-Two: baz\n"))
+	notok(t, err)
+	assert(t, strings.Contains(err.Error(), "required field Value missing"))
+
+	notok(t, control.Unmarshal(&foo, strings.NewReader("Value:\n \t\nValue-Two: baz\n")))
+
+	/* Which isn't a problem for fields that aren't required */
+	isok(t, control.Unmarshal(&foo, strings.NewReader("Value: foo\nValue-Two:   \n")))
+	assert(t, foo.ValueTwo == "")
 }
 
 func TestTagUnmarshal(t *testing.T) {
@@ -109,8 +139,526 @@ ValueThree: foo bar baz
 	assert(t, foo.ValueThree[0] == "foo")
 }
 
+type TestQuotedListStruct struct {
+	Uploaders []string `delim:"," strip:"\n\r\t "`
+	Binaries  []string `control:"Binary" delim:" "`
+}
+
+func TestQuotedListUnmarshal(t *testing.T) {
+	foo := TestQuotedListStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(`Uploaders: "Doe, Jane" <jdoe@example.com>, Bob <bob@example.com>,
+ "Roe, Richard, Jr." <rroe@example.com>
+Binary: "foo bar"
+`)))
+	assert(t, len(foo.Uploaders) == 3)
+	assert(t, foo.Uploaders[0] == `"Doe, Jane" <jdoe@example.com>`)
+	assert(t, foo.Uploaders[1] == "Bob <bob@example.com>")
+	assert(t, foo.Uploaders[2] == `"Roe, Richard, Jr." <rroe@example.com>`)
+
+	/* Only comma separated lists care about quotes */
+	assert(t, len(foo.Binaries) == 2)
+}
+
+type TestPointerStruct struct {
+	Version *version.Version
+	Source  *string
+}
+
+func TestPointerUnmarshal(t *testing.T) {
+	foo := TestPointerStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(`Version: 1:1.0-1
+Source: fnord
+`)))
+	assert(t, foo.Version != nil)
+	assert(t, foo.Version.Epoch == 1)
+	assert(t, foo.Version.Revision == "1")
+	assert(t, foo.Source != nil && *foo.Source == "fnord")
+
+	foo = TestPointerStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(`Value: foo
+`)))
+	assert(t, foo.Version == nil)
+	assert(t, foo.Source == nil)
+
+	foo = TestPointerStruct{}
+	notok(t, control.Unmarshal(&foo, strings.NewReader(`Version: 1:
+`)))
+}
+
+func TestParseList(t *testing.T) {
+	for _, tc := range []struct {
+		input    string
+		expected []string
+	}{
+		{"amd64 i386", []string{"amd64", "i386"}},
+		{" amd64\ti386\n arm64 ", []string{"amd64", "i386", "arm64"}},
+		{"amd64, i386,arm64", []string{"amd64", "i386", "arm64"}},
+		{"amd64 i386, arm64", []string{"amd64 i386", "arm64"}},
+		{"amd64,, i386,", []string{"amd64", "i386"}},
+		{"amd64", []string{"amd64"}},
+		{"", []string{}},
+	} {
+		got := control.ParseList(tc.input)
+		assert(t, len(got) == len(tc.expected))
+		for i := range got {
+			assert(t, got[i] == tc.expected[i])
+		}
+	}
+}
+
+type TestAutoListStruct struct {
+	Arches []string `delim:"auto"`
+}
+
+func TestAutoListUnmarshal(t *testing.T) {
+	for _, data := range []string{
+		"Arches: amd64 i386 arm64\n",
+		"Arches: amd64, i386, arm64\n",
+		"Arches:\n amd64,\n i386,\n arm64\n",
+	} {
+		foo := TestAutoListStruct{}
+		isok(t, control.Unmarshal(&foo, strings.NewReader(data)))
+		assert(t, len(foo.Arches) == 3)
+		assert(t, foo.Arches[1] == "i386")
+	}
+}
+
+type TestNormalizeStruct struct {
+	Arch     dependency.Arch `control:"X-Normalize-Arch"`
+	Homepage string          `control:"X-Normalize-Homepage"`
+}
+
+func TestNormalizerUnmarshal(t *testing.T) {
+	control.RegisterNormalizer("X-Normalize-Arch", strings.ToLower)
+	control.RegisterNormalizer("X-Normalize-Homepage", strings.TrimSpace)
+	control.RegisterNormalizer("X-Normalize-Homepage", func(value string) string {
+		return strings.TrimSuffix(value, "/")
+	})
+
+	foo := TestNormalizeStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(`X-Normalize-Arch: AMD64
+X-Normalize-Homepage:  https://example.com/ 
+`)))
+	assert(t, foo.Arch.CPU == "amd64")
+	assert(t, foo.Homepage == "https://example.com")
+}
+
+func TestDecoderMaxFieldLength(t *testing.T) {
+	data := "Package: foo\nDescription: short\n " + strings.Repeat("a", 100) + "\n\n" +
+		"Package: " + strings.Repeat("b", 10000) + "\n"
+
+	decoder := control.NewDecoder(strings.NewReader(data))
+	decoder.SetMaxFieldLength(200)
+	para := control.Paragraph{}
+	isok(t, decoder.Decode(&para))
+	assert(t, para.Values["Package"] == "foo")
+	notok(t, decoder.Decode(&para))
+
+	/* Lots of short lines still add up */
+	decoder = control.NewDecoder(strings.NewReader("Description: foo\n" + strings.Repeat(" bar\n", 100)))
+	decoder.SetMaxFieldLength(200)
+	notok(t, decoder.Decode(&para))
+
+	/* Signed input is held to the limit too */
+	decoder = control.NewDecoder(strings.NewReader("-----BEGIN PGP SIGNED MESSAGE-----\n" + strings.Repeat("c", 10000)))
+	decoder.SetMaxFieldLength(200)
+	notok(t, decoder.Decode(&para))
+
+	/* And there's no limit by default */
+	decoder = control.NewDecoder(strings.NewReader(data))
+	isok(t, decoder.Decode(&para))
+	isok(t, decoder.Decode(&para))
+	assert(t, len(para.Values["Package"]) == 10000)
+}
+
+type TestSizeStruct struct {
+	Size     int64
+	Download int64 `control:"X-Download-Size" unit:"bytes"`
+	Unpacked int   `control:"X-Unpacked-Size" unit:"bytes"`
+}
+
+func TestByteSizeUnmarshal(t *testing.T) {
+	for _, tc := range []struct {
+		value    string
+		expected int64
+	}{
+		{"1024", 1024},
+		{"1K", 1024},
+		{"1k", 1024},
+		{"1.5M", 1572864},
+		{"1.5m", 1572864},
+		{"2G", 2147483648},
+		{"2g", 2147483648},
+		{"8589934591G", 9223372035781033984},
+	} {
+		foo := TestSizeStruct{}
+		isok(t, control.Unmarshal(&foo, strings.NewReader(
+			"X-Download-Size: "+tc.value+"\nX-Unpacked-Size: "+tc.value+"\n",
+		)))
+		assert(t, foo.Download == tc.expected)
+		assert(t, int64(foo.Unpacked) == tc.expected)
+	}
+
+	foo := TestSizeStruct{}
+	for _, value := range []string{
+		"1.5", "M", "-1K", "1X",
+		/* Not numbers at all, as far as we're concerned */
+		"NaNK", "InfG", "+InfM", "1e30G", "1e3", "1_0K", "1_024",
+		/* No signs, with or without a suffix */
+		"-1024", "+1024", "+1K",
+		/* Halfway there */
+		"1.K", ".5K", "1.5.5K",
+		/* Past what an int64 holds */
+		"8589934592G", "9223372036854775808",
+	} {
+		notok(t, control.Unmarshal(&foo, strings.NewReader("X-Download-Size: "+value+"\n")))
+	}
+
+	/* Standard fields stay strict */
+	isok(t, control.Unmarshal(&foo, strings.NewReader("Size: 4096\n")))
+	assert(t, foo.Size == 4096)
+	notok(t, control.Unmarshal(&foo, strings.NewReader("Size: 4K\n")))
+}
+
+type TestEmptyListStruct struct {
+	Depends       []string                       `delim:"," strip:" "`
+	Architectures []dependency.Arch              `control:"Architecture"`
+	Files         []control.SHA256DebianFileHash `control:"Checksums-Sha256" delim:"\n" strip:"\n\r\t "`
+}
+
+func TestEmptyListUnmarshal(t *testing.T) {
+	foo := TestEmptyListStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(`Depends:
+Architecture:
+Checksums-Sha256:
+`)))
+	assert(t, len(foo.Depends) == 0)
+	assert(t, len(foo.Architectures) == 0)
+	assert(t, len(foo.Files) == 0)
+
+	/* Whitespace only is just as empty */
+	isok(t, control.Unmarshal(&foo, strings.NewReader("Depends: \t\n")))
+	assert(t, len(foo.Depends) == 0)
+}
+
 func TestRequiredUnmarshal(t *testing.T) {
 	foo := TestStruct{}
 	notok(t, control.Unmarshal(&foo, strings.NewReader(`Foo-Bar: baz
 `)))
 }
+
+func TestDecodeErrorMessages(t *testing.T) {
+	/* A malformed line names the line, however deep in the stream it is */
+	decoder := control.NewDecoder(strings.NewReader(
+		"Value: a\n\nValue: b\nValue-Two: c\n\nValue: d\nbroken\n"))
+	foo := TestStruct{}
+	isok(t, decoder.Decode(&foo))
+	isok(t, decoder.Decode(&foo))
+	err := decoder.Decode(&foo)
+	assert(t, err != nil && err.Error() ==
+		`pault.ag/go/debian/control: line 7: malformed field, no colon found: "broken"`)
+
+	/* A missing required field names the Paragraph, and where it starts */
+	decoder = control.NewDecoder(strings.NewReader(
+		"Value: a\n\nValue: b\n\n\nValue-Two: c\n"))
+	isok(t, decoder.Decode(&foo))
+	isok(t, decoder.Decode(&foo))
+	err = decoder.Decode(&foo)
+	assert(t, err != nil && err.Error() ==
+		"pault.ag/go/debian/control: required field Value missing (in paragraph 2, on line 6)")
+	decodeErr, ok := err.(control.DecodeError)
+	assert(t, ok)
+	assert(t, decodeErr.Paragraph == 2)
+	assert(t, decodeErr.Line == 6)
+	assert(t, decodeErr.Err.Error() == "pault.ag/go/debian/control: required field Value missing")
+
+	/* Going over the maximum field length */
+	decoder = control.NewDecoder(strings.NewReader(
+		"Value: a\n\nValue: " + strings.Repeat("b", 300) + "\n"))
+	decoder.SetMaxFieldLength(200)
+	isok(t, decoder.Decode(&foo))
+	err = decoder.Decode(&foo)
+	assert(t, err != nil && err.Error() ==
+		"pault.ag/go/debian/control: line 3: longer than 200 bytes")
+
+	/* A signed Paragraph starts on its armor header */
+	entity := newTestEntity(t, "archive")
+	out := bytes.Buffer{}
+	plaintext, err := clearsign.Encode(&out, entity.PrivateKey, nil)
+	isok(t, err)
+	io.WriteString(plaintext, "Value-Two: c\n")
+	isok(t, plaintext.Close())
+	err = control.NewDecoder(strings.NewReader(out.String())).Decode(&foo)
+	assert(t, err != nil && err.Error() ==
+		"pault.ag/go/debian/control: required field Value missing (in paragraph 0, on line 1)")
+
+	/* And Unmarshal of a single struct goes through the Decoder too */
+	err = control.Unmarshal(&foo, strings.NewReader("\n\nValue-Two: c\n"))
+	assert(t, err != nil && err.Error() ==
+		"pault.ag/go/debian/control: required field Value missing (in paragraph 0, on line 3)")
+	_, ok = err.(control.DecodeError)
+	assert(t, ok)
+}
+
+type TestOffsetStruct struct {
+	Package string
+}
+
+func TestDecoderOffset(t *testing.T) {
+	data := `Package: foo
+Description: foo
+ bar
+
+Package: bar
+
+
+Package: baz
+`
+	decoder := control.NewDecoder(strings.NewReader(data))
+	for _, name := range []string{"foo", "bar", "baz"} {
+		foo := TestOffsetStruct{}
+		isok(t, decoder.Decode(&foo))
+		assert(t, foo.Package == name)
+		assert(t, decoder.Offset() == int64(strings.Index(data, "Package: "+name)))
+
+		/* Seeking to the offset gets us that very Paragraph back */
+		seeked := TestOffsetStruct{}
+		isok(t, control.NewDecoder(strings.NewReader(data[decoder.Offset():])).Decode(&seeked))
+		assert(t, seeked.Package == name)
+	}
+
+	foo := TestOffsetStruct{}
+	assert(t, decoder.Decode(&foo) == io.EOF)
+}
+
+func TestDecoderMore(t *testing.T) {
+	data := `Package: foo
+
+Package: bar
+
+
+`
+	decoder := control.NewDecoder(strings.NewReader(data))
+	names := []string{}
+	for decoder.More() {
+		foo := TestOffsetStruct{}
+		isok(t, decoder.Decode(&foo))
+		names = append(names, foo.Package)
+		assert(t, decoder.Offset() == int64(strings.Index(data, "Package: "+foo.Package)))
+	}
+	assert(t, strings.Join(names, " ") == "foo bar")
+	assert(t, !decoder.More())
+	assert(t, decoder.TrailingBlankLines() == 2)
+
+	foo := TestOffsetStruct{}
+	assert(t, decoder.Decode(&foo) == io.EOF)
+
+	/* Errors are left for Decode to hand back */
+	decoder = control.NewDecoder(strings.NewReader("Package foo\n"))
+	assert(t, decoder.More())
+	notok(t, decoder.Decode(&foo))
+
+	assert(t, !control.NewDecoder(strings.NewReader("\n\n")).More())
+}
+
+func TestDecoderNoTrailingNewline(t *testing.T) {
+	decoder := control.NewDecoder(strings.NewReader("Package: bar\n\nPackage: foo\nVersion: 1"))
+	paragraphs := []control.Paragraph{}
+	for {
+		para := control.Paragraph{}
+		err := decoder.Decode(&para)
+		if err == io.EOF {
+			break
+		}
+		isok(t, err)
+		paragraphs = append(paragraphs, para)
+	}
+	assert(t, len(paragraphs) == 2)
+	assert(t, paragraphs[1].Values["Package"] == "foo")
+	assert(t, paragraphs[1].Values["Version"] == "1")
+	assert(t, len(paragraphs[1].Order) == 2)
+	assert(t, decoder.TrailingBlankLines() == 0)
+}
+
+type eagerDependsStruct struct {
+	Package string
+	Depends dependency.Dependency
+}
+
+type lazyDependsStruct struct {
+	Package string
+	Depends dependency.LazyDependency
+}
+
+func TestLazyDependsUnmarshal(t *testing.T) {
+	foo := lazyDependsStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader("Package: foo\nDepends: a (>> \n")))
+	assert(t, foo.Depends.String() == "a (>>")
+	_, err := foo.Depends.Parsed()
+	notok(t, err)
+
+	isok(t, control.Unmarshal(&foo, strings.NewReader("Package: foo\nDepends: a, b | c\n")))
+	dep, err := foo.Depends.Parsed()
+	isok(t, err)
+	assert(t, len(dep.Relations) == 2)
+}
+
+func largeIndex() string {
+	paragraphs := []string{}
+	for i := 0; i < 2000; i++ {
+		paragraphs = append(paragraphs, `Package: foo
+Depends: libc6 (>= 2.34), libgcc-s1 (>= 3.0), libstdc++6 (>= 12), debconf (>= 0.5) | debconf-2.0, adduser, lsb-base (>= 3.0-6) [!hurd-any]
+`)
+	}
+	return strings.Join(paragraphs, "\n")
+}
+
+func BenchmarkUnmarshalDepends(b *testing.B) {
+	index := largeIndex()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		packages := []eagerDependsStruct{}
+		if err := control.Unmarshal(&packages, bufio.NewReader(strings.NewReader(index))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalLazyDepends(b *testing.B) {
+	index := largeIndex()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		packages := []lazyDependsStruct{}
+		if err := control.Unmarshal(&packages, bufio.NewReader(strings.NewReader(index))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestReadN(t *testing.T) {
+	// Test Paragraphs {{{
+	input := `Package: foo
+Version: 1.0-1
+
+Package: bar
+Version: 2.0-1
+
+Package: baz
+Version: 3.0-1
+`
+	// }}}
+	packages := []control.BinaryIndex{}
+	isok(t, control.ReadN(strings.NewReader(input), 2, &packages))
+	assert(t, len(packages) == 2)
+	assert(t, packages[0].Package == "foo")
+	assert(t, packages[1].Package == "bar")
+
+	packages = []control.BinaryIndex{}
+	isok(t, control.ReadN(strings.NewReader(input), 10, &packages))
+	assert(t, len(packages) == 3)
+	assert(t, packages[2].Version.String() == "3.0-1")
+
+	packages = []control.BinaryIndex{}
+	isok(t, control.ReadN(strings.NewReader(input), 0, &packages))
+	assert(t, len(packages) == 0)
+
+	/* Anything past the first n isn't even looked at */
+	packages = []control.BinaryIndex{}
+	isok(t, control.ReadN(strings.NewReader(input+"\nNot a valid line\n"), 3, &packages))
+	assert(t, len(packages) == 3)
+
+	notok(t, control.ReadN(strings.NewReader(input), 1, packages))
+}
+
+type TestLine struct {
+	Key   string
+	Value string
+}
+
+func (l *TestLine) UnmarshalControl(data string) error {
+	els := strings.SplitN(data, "=", 2)
+	if len(els) != 2 {
+		return fmt.Errorf("Not a key=value line: '%s'", data)
+	}
+	l.Key, l.Value = els[0], els[1]
+	return nil
+}
+
+type TestWord string
+
+func (w *TestWord) UnmarshalControl(data string) error {
+	*w = TestWord(strings.ToUpper(data))
+	return nil
+}
+
+type TestCustomSliceStruct struct {
+	Lines    []TestLine  `control:"X-Lines" delim:"\n" strip:"\n\r\t "`
+	Pointers []*TestLine `control:"X-Pointers" delim:"," strip:" "`
+	Words    []TestWord  `control:"X-Words"`
+}
+
+func TestCustomSliceUnmarshal(t *testing.T) {
+	foo := TestCustomSliceStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(`X-Lines:
+ foo=bar
+ baz=fnord=yes
+X-Pointers: a=1, b=2
+X-Words: hello world
+`)))
+	assert(t, len(foo.Lines) == 2)
+	assert(t, foo.Lines[0].Key == "foo")
+	assert(t, foo.Lines[1].Value == "fnord=yes")
+
+	assert(t, len(foo.Pointers) == 2)
+	assert(t, foo.Pointers[1].Key == "b")
+	assert(t, foo.Pointers[1].Value == "2")
+
+	assert(t, len(foo.Words) == 2)
+	assert(t, foo.Words[1] == "WORLD")
+
+	/* Errors from any one element fail the lot */
+	notok(t, control.Unmarshal(&foo, strings.NewReader("X-Lines:\n foo=bar\n nope\n")))
+}
+
+func TestCaseInsensitiveUnmarshal(t *testing.T) {
+	// Test Binary Index {{{
+	input := `package: foo
+VERSION: 1.0-1
+installed-size: 1024
+Architecture: amd64
+`
+	// }}}
+	pkg := control.BinaryIndex{}
+	isok(t, control.Unmarshal(&pkg, strings.NewReader(input)))
+	assert(t, pkg.Package == "foo")
+	assert(t, pkg.Version.String() == "1.0-1")
+	assert(t, pkg.InstalledSize == "1024")
+	assert(t, pkg.Architecture.CPU == "amd64")
+
+	/* The Paragraph keeps the keys the way they were written */
+	assert(t, pkg.Values["installed-size"] == "1024")
+	_, ok := pkg.Values["Installed-Size"]
+	assert(t, !ok)
+
+	/* Required fields can be found that way too */
+	foo := TestStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader("value: foo\nvalue-two: bar\n")))
+	assert(t, foo.Value == "foo")
+	assert(t, foo.ValueTwo == "bar")
+
+	/* And they're not left over for the catch-all */
+	extra := TestExtraStruct{}
+	isok(t, control.Unmarshal(&extra, strings.NewReader("PACKAGE: foo\nx-vendor-thing: yes\n")))
+	assert(t, extra.Package == "foo")
+	assert(t, len(extra.Extra) == 1)
+	assert(t, extra.Extra["x-vendor-thing"] == "yes")
+
+	/* If a key is there more than once, the first one in the file wins */
+	for i := 0; i < 10; i++ {
+		foo = TestStruct{}
+		isok(t, control.Unmarshal(&foo, strings.NewReader(
+			"value: first\nVALUE: second\nValue-Two: bar\n",
+		)))
+		assert(t, foo.Value == "first")
+	}
+}
@@ -0,0 +1,80 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control
+
+import (
+	"strings"
+)
+
+// Build the value of a Description field out of a one line synopsis, and
+// a long description written as plain paragraphs split up by blank lines,
+// the way you'd write it in a README. The result can be set on a Paragraph
+// or a struct's Description field as-is, and comes out in the form Debian
+// policy (§5.6.13) asks for once it's written: every line of the long
+// description indented by a space, and blank lines written as " .".
+//
+// Lines of body that start with whitespace are verbatim, and have their
+// indentation kept (on top of the space every line gets), so that they're
+// displayed exactly as given rather than word wrapped. Leading tabs are
+// turned into 8 spaces each, since dpkg only goes by spaces. Lines that
+// start with a "." are made verbatim too, with one extra space, since " ."
+// would be read back as a blank line and " .foo" is reserved by policy.
+// Runs of blank lines are squashed into one, and leading and trailing blank
+// lines are dropped. Lines are never re-wrapped.
+func FormatDescription(synopsis string, body string) string {
+	ret := strings.Join(strings.Fields(synopsis), " ")
+
+	body = strings.Replace(body, "\r\n", "\n", -1)
+	blank := false
+	started := false
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			blank = started
+			continue
+		}
+		if blank {
+			ret += "\n."
+			blank = false
+		}
+		started = true
+
+		if line[0] == ' ' || line[0] == '\t' {
+			ret += "\n" + expandIndent(line)
+			continue
+		}
+		if line[0] == '.' {
+			ret += "\n " + line
+			continue
+		}
+		ret += "\n" + line
+	}
+	return ret
+}
+
+// Swap the tabs in the indentation of a verbatim line for spaces.
+func expandIndent(line string) string {
+	body := strings.TrimLeft(line, " \t")
+	indent := line[:len(line)-len(body)]
+	return strings.Replace(indent, "\t", "        ", -1) + body
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,104 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"pault.ag/go/debian/control"
+)
+
+/*
+ *
+ */
+
+func TestFormatDescription(t *testing.T) {
+	// Test Description {{{
+	body := `
+fbautostart is a small program which starts programs listed in
+.desktop files, as outlined in the freedesktop.org spec.
+
+
+It can be run like this:
+
+  $ fbautostart --mode=FLUXBOX
+	(for the verbatim folks)
+
+Some more text.
+
+`
+	// }}}
+	value := control.FormatDescription("  lightweight autostarter\nfor fluxbox ", body)
+	assert(t, value == `lightweight autostarter for fluxbox
+fbautostart is a small program which starts programs listed in
+ .desktop files, as outlined in the freedesktop.org spec.
+.
+It can be run like this:
+.
+  $ fbautostart --mode=FLUXBOX
+        (for the verbatim folks)
+.
+Some more text.`)
+
+	para := control.Paragraph{Values: map[string]string{}}
+	para.Set("Description", value)
+	out := bytes.Buffer{}
+	_, err := para.WriteTo(&out)
+	isok(t, err)
+	// Test Description Output {{{
+	assert(t, out.String() == `Description: lightweight autostarter for fluxbox
+ fbautostart is a small program which starts programs listed in
+  .desktop files, as outlined in the freedesktop.org spec.
+ .
+ It can be run like this:
+ .
+   $ fbautostart --mode=FLUXBOX
+         (for the verbatim folks)
+ .
+ Some more text.
+`)
+	// }}}
+
+	/* And back again */
+	pkg := control.BinaryIndex{}
+	isok(t, control.Unmarshal(&pkg, strings.NewReader("Package: fbautostart\n"+out.String())))
+	assert(t, pkg.Description == value)
+}
+
+func TestFormatDescriptionSynopsisOnly(t *testing.T) {
+	assert(t, control.FormatDescription("hello world", "") == "hello world")
+	assert(t, control.FormatDescription("hello world", "\n \n\t\n") == "hello world")
+}
+
+func TestFormatDescriptionDots(t *testing.T) {
+	value := control.FormatDescription("hello world", ".\n.foo\nbar")
+	assert(t, value == "hello world\n .\n .foo\nbar")
+
+	/* Which reads back as text, rather than a blank line */
+	pkg := control.BinaryIndex{}
+	isok(t, control.Unmarshal(&pkg, strings.NewReader("Package: hello\nDescription: "+
+		strings.Replace(value, "\n", "\n ", -1)+"\n")))
+	assert(t, pkg.Description == value)
+}
+
+// vim: foldmethod=marker
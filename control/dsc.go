@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"pault.ag/go/debian/dependency"
 	"pault.ag/go/debian/version"
@@ -61,19 +62,20 @@ type DSC struct {
 	Version          version.Version
 	Origin           string
 	Maintainer       string
-	Uploaders        []string
+	Uploaders        []string `delim:"," strip:"\n\r\t "`
 	Homepage         string
 	StandardsVersion string                `control:"Standards-Version"`
 	BuildDepends     dependency.Dependency `control:"Build-Depends"`
 
+	BuildConflicts      dependency.Dependency `control:"Build-Conflicts"`
+	BuildConflictsIndep dependency.Dependency `control:"Build-Conflicts-Indep"`
+
 	ChecksumsSha1   []SHA1DebianFileHash   `control:"Checksums-Sha1" delim:"\n" strip:"\n\r\t "`
 	ChecksumsSha256 []SHA256DebianFileHash `control:"Checksums-Sha256" delim:"\n" strip:"\n\r\t "`
+	ChecksumsSha512 []SHA512DebianFileHash `control:"Checksums-Sha512" delim:"\n" strip:"\n\r\t "`
 	Files           []FileListDSCFileHash  `control:"Files" delim:"\n" strip:"\n\r\t "`
 
-	/*
-		TODO:
-			Package-List
-	*/
+	PackageList []PackageListEntry `control:"Package-List" delim:"\n" strip:"\n\r\t "`
 }
 
 // Given a bunch of DSC objects, sort the packages topologically by
@@ -155,6 +157,44 @@ func ParseDsc(reader *bufio.Reader, path string) (*DSC, error) {
 	return &ret, nil
 }
 
+// Parse the Format field of this .dsc into a SourceFormat.
+func (d *DSC) SourceFormat() (SourceFormat, error) {
+	return ParseSourceFormat(d.Format)
+}
+
+// ValidateControl checks that the Format (when set) is one dpkg-source
+// knows about, as per SourceFormat.Valid, so that a strict Decoder or
+// Encoder holds a DSC to the same rules as a SourceFormat member.
+func (d DSC) ValidateControl() error {
+	if d.Format == "" {
+		return nil
+	}
+	format, err := d.SourceFormat()
+	if err != nil {
+		return err
+	}
+	return format.Valid()
+}
+
+// Check to see if this .dsc is for a native package. For the "1.0"
+// format, that's decided by the lack of a .diff.gz in the Files list;
+// "2.0" packages are never native.
+func (d *DSC) IsNative() (bool, error) {
+	format, err := d.SourceFormat()
+	if err != nil {
+		return false, err
+	}
+	if format.Version != "1.0" {
+		return format.IsNative(), nil
+	}
+	for _, file := range d.FileList() {
+		if strings.HasSuffix(file.Filename, ".diff.gz") {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 // Check to see if this .dsc contains any arch:all binary packages along
 // with any arch dependent packages.
 func (d *DSC) HasArchAll() bool {
@@ -173,6 +213,33 @@ func (d *DSC) Maintainers() []string {
 	return append([]string{d.Maintainer}, d.Uploaders...)
 }
 
+// Return the files that make up this source package, along with their
+// size and strongest hash on offer. Newer .dsc files may leave out the md5
+// Files list entirely, so this takes whichever of Checksums-Sha512,
+// Checksums-Sha256, Checksums-Sha1 or Files is present, in that order.
+func (d *DSC) FileList() []DebianFileHash {
+	ret := []DebianFileHash{}
+	switch {
+	case len(d.ChecksumsSha512) != 0:
+		for _, file := range d.ChecksumsSha512 {
+			ret = append(ret, file.DebianFileHash)
+		}
+	case len(d.ChecksumsSha256) != 0:
+		for _, file := range d.ChecksumsSha256 {
+			ret = append(ret, file.DebianFileHash)
+		}
+	case len(d.ChecksumsSha1) != 0:
+		for _, file := range d.ChecksumsSha1 {
+			ret = append(ret, file.DebianFileHash)
+		}
+	default:
+		for _, file := range d.Files {
+			ret = append(ret, file.DebianFileHash)
+		}
+	}
+	return ret
+}
+
 func (d DSC) validateHash(hash DebianFileHash) (bool, error) {
 	hash.Filename = filepath.Join(filepath.Dir(d.Filename), hash.Filename)
 	if ok, err := hash.Validate(); err != nil {
@@ -195,6 +262,11 @@ func (d DSC) Validate() (bool, error) {
 			return ok, err
 		}
 	}
+	for _, f := range d.ChecksumsSha512 {
+		if ok, err := d.validateHash(f.DebianFileHash); err != nil || !ok {
+			return ok, err
+		}
+	}
 	for _, f := range d.Files {
 		if ok, err := d.validateHash(f.DebianFileHash); err != nil || !ok {
 			return ok, err
@@ -26,6 +26,7 @@ import (
 	"testing"
 
 	"pault.ag/go/debian/control"
+	"pault.ag/go/debian/dependency"
 )
 
 /*
@@ -199,4 +200,106 @@ Files:
 	assert(t, c.HasArchAll())
 }
 
+func TestDSCUploadersParse(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader(`Format: 3.0 (quilt)
+Source: fbautostart
+Version: 2.718281828-1
+Maintainer: Paul Tagliamonte <paultag@ubuntu.com>
+Uploaders: "Doe, John" <jdoe@example.com>, Jane Roe <jroe@example.com>
+`))
+	c, err := control.ParseDsc(reader, "")
+	isok(t, err)
+	assert(t, len(c.Maintainers()) == 3)
+	assert(t, c.Uploaders[0] == `"Doe, John" <jdoe@example.com>`)
+	assert(t, c.Uploaders[1] == "Jane Roe <jroe@example.com>")
+}
+
+func TestDSCFileListSha256Only(t *testing.T) {
+	// Test DSC {{{
+	reader := bufio.NewReader(strings.NewReader(`Format: 3.0 (quilt)
+Source: fbautostart
+Version: 2.718281828-1
+Checksums-Sha256:
+ bb2fdfd4a38505905222ee02d8236a594bdf6eaefca23462294cacda631745c1 92748 fbautostart_2.718281828.orig.tar.gz
+ f7186d1bebde403527b5b3fd80406decaaf295366206667d5b402da962f0b772 2356 fbautostart_2.718281828-1.debian.tar.xz
+`))
+	// }}}
+	c, err := control.ParseDsc(reader, "")
+	isok(t, err)
+	assert(t, len(c.Files) == 0)
+
+	files := c.FileList()
+	assert(t, len(files) == 2)
+	assert(t, files[0].Algorithm == "sha256")
+	assert(t, files[0].Filename == "fbautostart_2.718281828.orig.tar.gz")
+	assert(t, files[1].Size == 2356)
+}
+
+func TestDSCFileListPrefersStrongest(t *testing.T) {
+	// Test DSC {{{
+	reader := bufio.NewReader(strings.NewReader(`Format: 1.0
+Source: fbautostart
+Version: 2.718281828-1
+Checksums-Sha512:
+ 6e6a5d2b7b7e07c0c78f8ec4f8dcd6a0d2bd8a2d5f1e8a9d5e7bd0f6a5b4e6c7d8e9f0a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1b2 92748 fbautostart_2.718281828.orig.tar.gz
+ 0f1e2d3c4b5a69788796a5b4c3d2e1f00f1e2d3c4b5a69788796a5b4c3d2e1f00f1e2d3c4b5a69788796a5b4c3d2e1f00f1e2d3c4b5a69788796a5b4c3d2e1f0 2356 fbautostart_2.718281828-1.diff.gz
+Files:
+ 06495f9b23b1c9b1bf35c2346cb48f63 92748 fbautostart_2.718281828.orig.tar.gz
+ f58c0e0bf4d56461e776232484c07301 2356 fbautostart_2.718281828-1.diff.gz
+`))
+	// }}}
+	c, err := control.ParseDsc(reader, "")
+	isok(t, err)
+
+	files := c.FileList()
+	assert(t, len(files) == 2)
+	assert(t, files[0].Algorithm == "sha512")
+	assert(t, files[1].Filename == "fbautostart_2.718281828-1.diff.gz")
+
+	native, err := c.IsNative()
+	isok(t, err)
+	assert(t, !native)
+}
+
+func TestDSCBuildConflictsParse(t *testing.T) {
+	// Test DSC {{{
+	reader := bufio.NewReader(strings.NewReader(`Format: 3.0 (quilt)
+Source: fbautostart
+Version: 2.718281828-1
+Build-Depends: debhelper (>= 9)
+Build-Conflicts: autoconf2.13, automake1.4 [amd64]
+Build-Conflicts-Indep: python-sphinx (<< 1.0) | python3-sphinx (<< 1.0)
+`))
+	// }}}
+	c, err := control.ParseDsc(reader, "")
+	isok(t, err)
+
+	assert(t, len(c.BuildConflicts.Relations) == 2)
+	assert(t, c.BuildConflicts.Relations[0].Possibilities[0].Name == "autoconf2.13")
+	assert(t, len(c.BuildConflictsIndep.Relations) == 1)
+	assert(t, len(c.BuildConflictsIndep.Relations[0].Possibilities) == 2)
+
+	/* Only amd64 has to keep automake1.4 away */
+	amd64, err := dependency.ParseArch("amd64")
+	isok(t, err)
+	arm64, err := dependency.ParseArch("arm64")
+	isok(t, err)
+	assert(t, len(c.BuildConflicts.GetPossibilities(*amd64)) == 2)
+	assert(t, len(c.BuildConflicts.GetPossibilities(*arm64)) == 1)
+
+	/* And back out again */
+	for _, dep := range []dependency.Dependency{c.BuildConflicts, c.BuildConflictsIndep} {
+		str, err := dep.MarshalControl()
+		isok(t, err)
+		again, err := dependency.Parse(str)
+		isok(t, err)
+		againStr, err := again.MarshalControl()
+		isok(t, err)
+		assert(t, str == againStr)
+	}
+	str, err := c.BuildConflicts.MarshalControl()
+	isok(t, err)
+	assert(t, str == "autoconf2.13, automake1.4 [amd64]")
+}
+
 // vim: foldmethod=marker
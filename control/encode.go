@@ -0,0 +1,557 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"pault.ag/go/debian/dependency"
+)
+
+// The Marshalable interface defines the interface that Marshal will use
+// to do custom packs of Structs.
+//
+// The return value is the string that will be written out as the value of
+// the RFC822 key this object relates to.
+type Marshalable interface {
+	MarshalControl() (string, error)
+}
+
+func marshalStructValueSlice(field reflect.Value, fieldType reflect.StructField) (string, error) {
+	var delim = " "
+	if it := fieldType.Tag.Get("delim"); it != "" {
+		delim = it
+	}
+	if delim == "auto" {
+		/* Either reads back fine, but only commas survive spaces in
+		 * the elements */
+		delim = ", "
+	}
+
+	data := []string{}
+	for i := 0; i < field.Len(); i++ {
+		value, err := marshalStructValue(field.Index(i), fieldType)
+		if err != nil {
+			return "", err
+		}
+		data = append(data, value)
+	}
+
+	if (fieldType.Tag.Get("fold") == "true" || delim == "\n") && len(data) != 0 {
+		/* One element per continuation line, starting on the line
+		 * after the key. The delimiter stays on the end of the line.
+		 * Newline delimited lists (such as Files) always go this way,
+		 * since the first line is meant to be empty. */
+		delim = strings.TrimRight(delim, " \t\n") + "\n"
+		return "\n" + strings.Join(data, delim), nil
+	}
+
+	return strings.Join(data, delim), nil
+}
+
+func marshalStructValueStruct(field reflect.Value, fieldType reflect.StructField) (string, error) {
+	if marshal, ok := field.Interface().(Marshalable); ok {
+		return marshal.MarshalControl()
+	}
+
+	return "", fmt.Errorf(
+		"Type '%s' does not implement control.Marshalable",
+		field.Type().Name(),
+	)
+}
+
+func marshalStructValue(field reflect.Value, fieldType reflect.StructField) (string, error) {
+	if dep, ok := field.Interface().(dependency.Dependency); ok && fieldType.Tag.Get("fold") == "true" && len(dep.Relations) != 0 {
+		/* One Relation per continuation line, the way dpkg writes out
+		 * Installed-Build-Depends */
+		style := dependency.DefaultStyle
+		if dep.Style != nil {
+			style = *dep.Style
+		}
+		style.Comma = strings.TrimRight(style.Comma, " \t") + "\n"
+		return "\n" + dep.StringWithStyle(style), nil
+	}
+
+	if codec, ok := lookupType(field.Type()); ok && codec.encode != nil {
+		return codec.encode(field.Interface())
+	}
+
+	if field.Type().Kind() != reflect.Ptr && isMarshalable(field) {
+		return field.Interface().(Marshalable).MarshalControl()
+	}
+
+	switch field.Type().Kind() {
+	case reflect.String:
+		value, _ := canonicalEnum(field.Type(), field.String())
+		return value, nil
+	case reflect.Bool:
+		if field.Bool() {
+			return "yes", nil
+		}
+		return "no", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(field.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(field.Uint(), 10), nil
+	case reflect.Ptr, reflect.Interface:
+		if field.IsNil() {
+			return "", nil
+		}
+		return marshalStructValue(field.Elem(), fieldType)
+	case reflect.Slice:
+		return marshalStructValueSlice(field, fieldType)
+	case reflect.Struct:
+		return marshalStructValueStruct(field, fieldType)
+	}
+	return "", fmt.Errorf("Unknown type of field: %s", field.Type())
+}
+
+// Check to see if the value is empty, as far as the omitempty option goes,
+// which is the same as for encoding/json: false, 0, "", a nil pointer or
+// interface, and a slice or map with nothing in it.
+func isEmptyValue(field reflect.Value) bool {
+	switch field.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return field.Len() == 0
+	case reflect.Bool:
+		return !field.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return field.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return field.Uint() == 0
+	case reflect.Ptr, reflect.Interface:
+		return field.IsNil()
+	}
+	return false
+}
+
+func isMarshalable(field reflect.Value) bool {
+	_, ok := field.Interface().(Marshalable)
+	return ok
+}
+
+func convertToParagraph(incoming reflect.Value, para *Paragraph) error {
+	paragraphType := reflect.TypeOf(Paragraph{})
+	extras := []int{}
+
+	for i := 0; i < incoming.NumField(); i++ {
+		field := incoming.Field(i)
+		fieldType := incoming.Type().Field(i)
+
+		if fieldType.Anonymous && fieldType.Type == paragraphType {
+			continue
+		}
+
+		if fieldType.PkgPath != "" {
+			/* Unexported, we can't get at it anyway */
+			continue
+		}
+
+		paragraphKey := controlKey(fieldType)
+
+		if paragraphKey == "-" {
+			continue
+		}
+
+		if isExtra(fieldType) {
+			extras = append(extras, i)
+			continue
+		}
+
+		_, registered := lookupType(field.Type())
+		if field.Type().Kind() == reflect.Struct && !registered && !isMarshalable(field) {
+			/* Nested structs get flattened into this Paragraph, the same
+			 * way decodePointer walks into them. */
+			if err := convertToParagraph(field, para); err != nil {
+				return err
+			}
+			continue
+		}
+
+		required := fieldType.Tag.Get("required") == "true"
+		omitEmpty := hasControlOption(fieldType, "omitempty")
+		if omitEmpty && !required && isEmptyValue(field) {
+			continue
+		}
+
+		value, err := marshalStructValue(field, fieldType)
+		if err != nil {
+			return fmt.Errorf(
+				"pault.ag/go/debian/control: failed to marshal %s: %s",
+				fieldType.Name,
+				err,
+			)
+		}
+
+		if required && strings.TrimSpace(value) == "" {
+			/* Unmarshal wouldn't take this back, so don't write it */
+			return fmt.Errorf(
+				"pault.ag/go/debian/control: required field %s is empty",
+				fieldType.Name,
+			)
+		}
+
+		if omitEmpty && value == "" {
+			/* Such as a zero version.Version */
+			continue
+		}
+
+		para.Set(paragraphKey, value)
+	}
+
+	/* The order the keys were read in, if we've got it */
+	order := []string{}
+	if index, is := isParagraph(incoming); is {
+		order = incoming.Field(index).Interface().(Paragraph).Order
+	}
+
+	for _, i := range extras {
+		if err := encodeExtra(incoming.Field(i), incoming.Type().Field(i), order, para); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Given a struct (or a pointer to one), convert it into a Paragraph, using
+// the same rules Unmarshal uses to map keys to struct members.
+func ConvertToParagraph(incoming interface{}) (*Paragraph, error) {
+	val := reflect.Indirect(reflect.ValueOf(incoming))
+	if val.Type().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Ouchie! I can only convert a struct")
+	}
+
+	para := Paragraph{
+		Values: map[string]string{},
+		Order:  []string{},
+	}
+	if err := convertToParagraph(val, &para); err != nil {
+		return nil, err
+	}
+	return &para, nil
+}
+
+// How an Encoder deals with newlines in the middle of a value.
+type NewlineMode int
+
+const (
+	// Write every line after the first out as a continuation line. This
+	// is the default, and always gives a well formed Paragraph, but may
+	// not be what's wanted for a field that is only supposed to be one
+	// line long, such as Package.
+	FoldNewlines NewlineMode = iota
+
+	// Return an error for a newline in any field that isn't allowed more
+	// than one line (see multilineFields), or isn't a relation field, or
+	// a member tagged `fold:"true"` or `delim:"\n"`. This is worth
+	// setting when field values come from user input.
+	RejectNewlines
+)
+
+// Fields (other than the relation fields) that may span more than one line.
+var multilineFields = map[string]bool{
+	"Description":             true,
+	"Changes":                 true,
+	"Uploaders":               true,
+	"Binary":                  true,
+	"Files":                   true,
+	"Checksums-Md5":           true,
+	"Checksums-Sha1":          true,
+	"Checksums-Sha256":        true,
+	"Checksums-Sha512":        true,
+	"Package-List":            true,
+	"Installed-Build-Depends": true,
+	"Environment":             true,
+	"MD5Sum":                  true,
+	"SHA1":                    true,
+	"SHA256":                  true,
+	"SHA512":                  true,
+}
+
+// An Encoder writes RFC822-alike Debian control-file Paragraphs out to an
+// io.Writer, one after another, separated by a blank line.
+type Encoder struct {
+	writer         *bufio.Writer
+	alreadyWritten bool
+	trailing       int
+	strict         bool
+	newlines       NewlineMode
+
+	/* Whether the newline ending the last field is left off */
+	noFinalNewline bool
+	/* Whether that newline is still to be written out */
+	held bool
+}
+
+// Create a new Encoder, which will write to the given io.Writer.
+func NewEncoder(writer io.Writer) *Encoder {
+	return &Encoder{writer: bufio.NewWriter(writer)}
+}
+
+// Encode the given struct (or list of structs) into the Encoder's
+// io.Writer. Each struct is written out as its own Paragraph. A Paragraph
+// may be given as well, which is written out as-is.
+func (e *Encoder) Encode(incoming interface{}) error {
+	val := reflect.Indirect(reflect.ValueOf(incoming))
+
+	switch val.Type().Kind() {
+	case reflect.Struct:
+		return e.encodeStruct(val)
+	case reflect.Slice:
+		for i := 0; i < val.Len(); i++ {
+			if err := e.encodeStruct(reflect.Indirect(val.Index(i))); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf(
+			"Ouchie! I don't know how to deal with a %s",
+			val.Type(),
+		)
+	}
+}
+
+// In strict mode, the Encoder checks that fields with rules beyond their
+// syntax follow them, and refuses to write out Paragraphs that do not.
+// Currently, that's Provides, which may only use the = version relation,
+// enum types (see RegisterEnum), which have to hold a registered value,
+// and anything Validatable, such as SourceFormat, which has to be a format
+// dpkg knows about.
+func (e *Encoder) SetStrict(strict bool) {
+	e.strict = strict
+}
+
+// Set how the Encoder deals with newlines in field values, see NewlineMode.
+func (e *Encoder) SetNewlineMode(mode NewlineMode) {
+	e.newlines = mode
+}
+
+// Find the keys of the members of a struct that are written out one
+// element per line, which is those tagged `fold:"true"`, or with a newline
+// in their delim.
+func foldedKeys(incoming reflect.Type, keys map[string]bool) {
+	if incoming.Kind() != reflect.Struct {
+		return
+	}
+	paragraphType := reflect.TypeOf(Paragraph{})
+
+	for i := 0; i < incoming.NumField(); i++ {
+		fieldType := incoming.Field(i)
+		if fieldType.Anonymous && fieldType.Type == paragraphType {
+			continue
+		}
+
+		_, registered := lookupType(fieldType.Type)
+		if fieldType.Type.Kind() == reflect.Struct && !registered &&
+			!fieldType.Type.Implements(marshalableType) {
+			foldedKeys(fieldType.Type, keys)
+			continue
+		}
+		if fieldType.Tag.Get("fold") == "true" || strings.Contains(fieldType.Tag.Get("delim"), "\n") {
+			keys[controlKey(fieldType)] = true
+		}
+	}
+}
+
+func (e *Encoder) checkNewlines(para *Paragraph, folded map[string]bool) error {
+	for _, key := range para.Order {
+		value := para.Values[key]
+		if !strings.Contains(value, "\n") {
+			continue
+		}
+		if multilineFields[key] || canonicalizeListFields[key] || folded[key] {
+			continue
+		}
+		return fmt.Errorf(
+			"pault.ag/go/debian/control: value of %s has a newline in it: %q",
+			key, value,
+		)
+	}
+	return nil
+}
+
+func (e *Encoder) validate(para *Paragraph) error {
+	if value, ok := para.Values["Provides"]; ok && value != "" {
+		provides, err := dependency.Parse(value)
+		if err != nil {
+			return err
+		}
+		if err := provides.ValidateProvides(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Set the number of blank lines Close writes after the last Paragraph.
+// The default is 0, which ends the output right after the last field.
+func (e *Encoder) SetTrailingBlankLines(n int) {
+	e.trailing = n
+}
+
+// Set whether Close ends the output with a single blank line after the last
+// Paragraph, as some tools expect of debian/control. This is shorthand for
+// SetTrailingBlankLines with 1 or 0; the default is not to.
+func (e *Encoder) SetTrailingNewline(newline bool) {
+	if newline {
+		e.trailing = 1
+	} else {
+		e.trailing = 0
+	}
+}
+
+// Set whether the last field of the last Paragraph ends in a newline. The
+// default is true; false leaves it off, for files that end without one
+// (see Decoder.FinalNewline). Since the Encoder can't know which Paragraph
+// is the last, once this is false the newline is held back until the next
+// Paragraph or Close, so Close has to be called, and it's the setting at
+// that point which counts. Any trailing blank lines set with
+// SetTrailingBlankLines bring the newline back, since they follow it.
+func (e *Encoder) SetFinalNewline(newline bool) {
+	e.noFinalNewline = !newline
+}
+
+// Close finishes off the stream by writing out any trailing blank lines
+// set with SetTrailingBlankLines. It does not close the underlying
+// io.Writer.
+func (e *Encoder) Close() error {
+	if e.held && (!e.noFinalNewline || e.trailing > 0) {
+		if _, err := e.writer.WriteString("\n"); err != nil {
+			return err
+		}
+	}
+	e.held = false
+	if _, err := e.writer.WriteString(strings.Repeat("\n", e.trailing)); err != nil {
+		return err
+	}
+	return e.writer.Flush()
+}
+
+func (e *Encoder) encodeStruct(incoming reflect.Value) error {
+	var para *Paragraph
+	if it, ok := incoming.Interface().(Paragraph); ok {
+		para = &it
+	} else if it, err := ConvertToParagraph(incoming.Interface()); err != nil {
+		return err
+	} else {
+		para = it
+	}
+
+	if e.newlines == RejectNewlines {
+		folded := map[string]bool{}
+		foldedKeys(incoming.Type(), folded)
+		if err := e.checkNewlines(para, folded); err != nil {
+			return err
+		}
+	}
+
+	if e.strict {
+		if err := validateEnums(incoming); err != nil {
+			return err
+		}
+		if err := e.validate(para); err != nil {
+			return err
+		}
+	}
+
+	if e.held {
+		if _, err := e.writer.WriteString("\n"); err != nil {
+			return err
+		}
+		e.held = false
+	}
+	if e.alreadyWritten {
+		if _, err := e.writer.WriteString("\n"); err != nil {
+			return err
+		}
+	}
+
+	if !e.noFinalNewline {
+		if _, err := para.WriteTo(e.writer); err != nil {
+			return err
+		}
+		e.alreadyWritten = true
+		return e.writer.Flush()
+	}
+
+	/* The newline ending the last field is held back until we know if
+	 * there's another Paragraph to come, or if Close is to leave it off */
+	buf := bytes.Buffer{}
+	if _, err := para.WriteTo(&buf); err != nil {
+		return err
+	}
+	out := buf.Bytes()
+	if len(out) != 0 && out[len(out)-1] == '\n' {
+		out = out[:len(out)-1]
+		e.held = true
+	}
+	if _, err := e.writer.Write(out); err != nil {
+		return err
+	}
+	e.alreadyWritten = true
+	return e.writer.Flush()
+}
+
+// Given a struct (or list of structs), write it out to the io.Writer as an
+// RFC822-alike Debian control-file stream. This is the inverse of
+// Unmarshal, and uses the same struct tags to figure out which key each
+// member maps to.
+//
+// If you're packing a list of strings, the `delim:""` tag is used to join
+// the elements together. Adding the `fold:"true"` tag will put each element
+// on its own continuation line, which is how fields like Uploaders are
+// usually written in debian/control. Lists with a `delim:"\n"` tag (such
+// as Files, or Checksums-Sha256) are always written that way. The same tag
+// on a dependency.Dependency puts each Relation on its own line.
+//
+// Adding the omitempty option to the `control:""` tag, as in
+// `control:"Homepage,omitempty"`, leaves the key out entirely if the member
+// is empty (false, 0, "", nil, or a slice with nothing in it), or if it
+// would be written out as an empty value. Without it, empty members are
+// written out as a key with no value.
+//
+// Members tagged `required:"true"` that would be written out empty (or as
+// nothing but whitespace) are an error, the same as they are for
+// Unmarshal, even if they're tagged omitempty too.
+//
+// The keys in a map[string]string member tagged `control:",extra"` are
+// written out too. When the struct has a Paragraph member (as filled in by
+// Unmarshal), each key that's in its Order goes back where it was, right
+// after the key it followed; the rest are written out after all the other
+// members, sorted.
+//
+// Members of type bool are written out as yes or no, as with Essential.
+//
+// Objects that implement the Marshalable interface will be Marshaled via
+// that method call only.
+func Marshal(writer io.Writer, data interface{}) error {
+	return NewEncoder(writer).Encode(data)
+}
+
+// vim: foldmethod=marker
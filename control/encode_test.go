@@ -0,0 +1,459 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"pault.ag/go/debian/control"
+	"pault.ag/go/debian/dependency"
+	"pault.ag/go/debian/version"
+)
+
+/*
+ *
+ */
+
+type TestMarshalStruct struct {
+	Source    string
+	Version   version.Version
+	Arches    []dependency.Arch `control:"Architecture"`
+	Uploaders []string          `delim:"," strip:"\n\r\t " fold:"true"`
+	Ignored   string            `control:"-"`
+}
+
+func TestBasicMarshal(t *testing.T) {
+	foo := TestMarshalStruct{
+		Source:  "fbautostart",
+		Version: version.Version{Version: "2.718281828", Revision: "1"},
+		Arches:  []dependency.Arch{{ABI: "gnu", OS: "linux", CPU: "amd64"}},
+		Uploaders: []string{
+			"John Doe <jdoe@example.com>",
+			"Foo Bar <fnord@baz.fnord>",
+		},
+		Ignored: "nope",
+	}
+
+	out := bytes.Buffer{}
+	isok(t, control.Marshal(&out, foo))
+	assert(t, out.String() == `Source: fbautostart
+Version: 2.718281828-1
+Architecture: amd64
+Uploaders:
+ John Doe <jdoe@example.com>,
+ Foo Bar <fnord@baz.fnord>
+`)
+}
+
+func TestArrayMarshal(t *testing.T) {
+	all := []dependency.Arch{{ABI: "all", OS: "all", CPU: "all"}}
+	foo := []TestMarshalStruct{
+		{Source: "foo", Version: version.Version{Version: "1.0"}, Arches: all},
+		{Source: "bar", Version: version.Version{Version: "2.0"}, Arches: all},
+	}
+
+	out := bytes.Buffer{}
+	isok(t, control.Marshal(&out, foo))
+	assert(t, out.String() == `Source: foo
+Version: 1.0
+Architecture: all
+Uploaders:
+
+Source: bar
+Version: 2.0
+Architecture: all
+Uploaders:
+`)
+}
+
+func TestUploadersFoldMarshal(t *testing.T) {
+	// Test Paragraph {{{
+	para := `Source: fbautostart
+Version: 2.718281828-1
+Architecture: amd64 sparc
+Uploaders:
+ John Doe <jdoe@example.com>,
+ Foo Bar <fnord@baz.fnord>,
+ Jane Roe <jroe@example.com>
+`
+	// }}}
+	foo := TestMarshalStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(para)))
+	assert(t, len(foo.Uploaders) == 3)
+	assert(t, foo.Uploaders[0] == "John Doe <jdoe@example.com>")
+	assert(t, foo.Uploaders[2] == "Jane Roe <jroe@example.com>")
+
+	out := bytes.Buffer{}
+	isok(t, control.Marshal(&out, foo))
+	assert(t, out.String() == para)
+
+	/* A single-line Uploaders gets re-folded one per line */
+	foo = TestMarshalStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(`Source: fbautostart
+Uploaders: John Doe <jdoe@example.com>, Foo Bar <fnord@baz.fnord>
+`)))
+	assert(t, len(foo.Uploaders) == 2)
+	assert(t, foo.Uploaders[1] == "Foo Bar <fnord@baz.fnord>")
+
+	out = bytes.Buffer{}
+	isok(t, control.Marshal(&out, foo))
+	assert(t, out.String() == `Source: fbautostart
+Version:
+Architecture:
+Uploaders:
+ John Doe <jdoe@example.com>,
+ Foo Bar <fnord@baz.fnord>
+`)
+}
+
+func TestTrailingBlankLinesRoundTrip(t *testing.T) {
+	for _, data := range []string{
+		"Package: foo\n\nPackage: bar\n",
+		"Package: foo\n\nPackage: bar\n\n",
+		"Package: foo\n\nPackage: bar\n\n\n",
+		/* No newline at the end at all */
+		"Package: foo\n\nPackage: bar",
+		"Package: foo\nDescription: foo\n bar",
+	} {
+		decoder := control.NewDecoder(strings.NewReader(data))
+		paragraphs := []control.Paragraph{}
+		for {
+			para := control.Paragraph{}
+			err := decoder.Decode(&para)
+			if err == io.EOF {
+				break
+			}
+			isok(t, err)
+			paragraphs = append(paragraphs, para)
+		}
+		assert(t, len(paragraphs) > 0)
+		assert(t, decoder.FinalNewline() == strings.HasSuffix(data, "\n"))
+
+		out := bytes.Buffer{}
+		encoder := control.NewEncoder(&out)
+		encoder.SetTrailingBlankLines(decoder.TrailingBlankLines())
+		encoder.SetFinalNewline(decoder.FinalNewline())
+		isok(t, encoder.Encode(paragraphs))
+		isok(t, encoder.Close())
+		assert(t, out.String() == data)
+
+		/* Transform keeps it all just the same */
+		out = bytes.Buffer{}
+		isok(t, control.Transform(strings.NewReader(data), &out, func(*control.Paragraph) error {
+			return nil
+		}))
+		assert(t, out.String() == data)
+	}
+}
+
+type TestProvidesStruct struct {
+	Package  string
+	Provides dependency.Dependency
+}
+
+func TestTrailingNewlineMarshal(t *testing.T) {
+	for _, newline := range []bool{false, true} {
+		out := bytes.Buffer{}
+		encoder := control.NewEncoder(&out)
+		encoder.SetTrailingNewline(newline)
+		isok(t, encoder.Encode([]TestOffsetStruct{{Package: "foo"}, {Package: "bar"}}))
+		isok(t, encoder.Close())
+
+		expected := "Package: foo\n\nPackage: bar\n"
+		if newline {
+			expected += "\n"
+		}
+		if out.String() != expected {
+			t.Errorf("%t: got %q", newline, out.String())
+		}
+	}
+}
+
+func TestVersionedProvidesMarshal(t *testing.T) {
+	para := `Package: foo
+Provides: bar (= 1.0-1), baz
+`
+	foo := TestProvidesStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(para)))
+	assert(t, foo.Provides.Relations[0].Possibilities[0].Version.Operator == "=")
+	assert(t, foo.Provides.Relations[0].Possibilities[0].Version.Number == "1.0-1")
+
+	out := bytes.Buffer{}
+	encoder := control.NewEncoder(&out)
+	encoder.SetStrict(true)
+	isok(t, encoder.Encode(foo))
+	assert(t, out.String() == para)
+
+	/* Only = is allowed in a Provides */
+	foo = TestProvidesStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(`Package: foo
+Provides: bar (>= 1.0-1)
+`)))
+
+	out = bytes.Buffer{}
+	isok(t, control.Marshal(&out, foo))
+
+	out = bytes.Buffer{}
+	encoder = control.NewEncoder(&out)
+	encoder.SetStrict(true)
+	notok(t, encoder.Encode(foo))
+	assert(t, out.Len() == 0)
+}
+
+func TestAutoListMarshal(t *testing.T) {
+	out := bytes.Buffer{}
+	isok(t, control.Marshal(&out, TestAutoListStruct{Arches: []string{"amd64", "i386"}}))
+	assert(t, out.String() == "Arches: amd64, i386\n")
+}
+
+type TestNewlineStruct struct {
+	Package     string
+	Description string
+	Depends     []string `delim:",\n"`
+}
+
+func TestNewlineModeMarshal(t *testing.T) {
+	foo := TestNewlineStruct{
+		Package:     "foo\nEvil: yes",
+		Description: "foo\n bar",
+	}
+
+	/* By default, it's folded in, and can't turn into a new key */
+	out := bytes.Buffer{}
+	isok(t, control.Marshal(&out, foo))
+	assert(t, out.String() == `Package: foo
+ Evil: yes
+Description: foo
+  bar
+Depends:
+`)
+
+	out = bytes.Buffer{}
+	encoder := control.NewEncoder(&out)
+	encoder.SetNewlineMode(control.RejectNewlines)
+	err := encoder.Encode(foo)
+	notok(t, err)
+	assert(t, strings.Contains(err.Error(), "Package"))
+	assert(t, out.Len() == 0)
+
+	/* Fields that may be more than one line long are fine */
+	foo.Package = "foo"
+	foo.Depends = []string{"bar", "baz"}
+	isok(t, encoder.Encode(foo))
+	assert(t, out.String() == `Package: foo
+Description: foo
+  bar
+Depends: bar,
+ baz
+`)
+
+	/* Starting with a newline doesn't make a field multi-line */
+	out.Reset()
+	foo.Package = "\nfoo"
+	err = encoder.Encode(foo)
+	notok(t, err)
+	assert(t, strings.Contains(err.Error(), "Package"))
+	assert(t, out.Len() == 0)
+
+	/* But fold members (whatever their key) may */
+	isok(t, encoder.Encode(TestMarshalStruct{
+		Source:    "foo",
+		Uploaders: []string{"John Doe <jdoe@example.com>", "Foo Bar <fnord@baz.fnord>"},
+	}))
+	isok(t, encoder.Encode(TestFoldedStruct{
+		Package: "foo",
+		Things:  []string{"a", "b"},
+	}))
+	assert(t, strings.HasSuffix(out.String(), "\nX-Things:\n a,\n b\n"))
+}
+
+type TestFoldedStruct struct {
+	Package string
+	Things  []string `control:"X-Things" delim:"," fold:"true"`
+}
+
+type TestBoolStruct struct {
+	Package        string
+	Essential      bool
+	BuildEssential bool `control:"Build-Essential"`
+}
+
+func TestBoolMarshal(t *testing.T) {
+	out := bytes.Buffer{}
+	isok(t, control.Marshal(&out, TestBoolStruct{
+		Package:   "base-files",
+		Essential: true,
+	}))
+	assert(t, out.String() == `Package: base-files
+Essential: yes
+Build-Essential: no
+`)
+
+	foo := TestBoolStruct{}
+	isok(t, control.Unmarshal(&foo, &out))
+	assert(t, foo.Essential)
+	assert(t, !foo.BuildEssential)
+}
+
+type TestIntStruct struct {
+	Package       string
+	InstalledSize uint   `control:"Installed-Size"`
+	Size          uint64 `unit:"bytes"`
+	Small         uint8  `control:"X-Small"`
+	Offset        int32  `control:"X-Offset"`
+}
+
+func TestUintRoundTrip(t *testing.T) {
+	foo := TestIntStruct{
+		Package:       "fbautostart",
+		InstalledSize: 51,
+		Size:          1 << 40,
+		Small:         255,
+		Offset:        -12,
+	}
+	out := bytes.Buffer{}
+	isok(t, control.Marshal(&out, foo))
+	assert(t, out.String() == `Package: fbautostart
+Installed-Size: 51
+Size: 1099511627776
+X-Small: 255
+X-Offset: -12
+`)
+
+	bar := TestIntStruct{}
+	isok(t, control.Unmarshal(&bar, &out))
+	assert(t, bar == foo)
+
+	isok(t, control.Unmarshal(&bar, strings.NewReader("Package: foo\nInstalled-Size:\nSize: 2K\n")))
+	assert(t, bar.InstalledSize == 0)
+	assert(t, bar.Size == 2048)
+
+	for _, input := range []string{
+		"Installed-Size: -1\n",
+		"X-Small: 256\n",
+		"X-Offset: 4294967296\n",
+		"Size: nope\n",
+	} {
+		notok(t, control.Unmarshal(&bar, strings.NewReader("Package: foo\n"+input)))
+	}
+}
+
+type TestOmitEmptyStruct struct {
+	Package   string
+	Version   version.Version   `control:",omitempty"`
+	Homepage  string            `control:"Homepage,omitempty"`
+	Size      int               `control:",omitempty"`
+	Essential bool              `control:",omitempty"`
+	Arches    []dependency.Arch `control:"Architecture,omitempty"`
+	Origin    *string           `control:",omitempty"`
+	Section   string
+}
+
+func TestOmitEmptyMarshal(t *testing.T) {
+	out := bytes.Buffer{}
+	isok(t, control.Marshal(&out, TestOmitEmptyStruct{Package: "fbautostart"}))
+	assert(t, out.String() == `Package: fbautostart
+Section:
+`)
+
+	origin := ""
+	out.Reset()
+	isok(t, control.Marshal(&out, TestOmitEmptyStruct{
+		Package:   "fbautostart",
+		Version:   version.Version{Version: "2.718281828", Revision: "1"},
+		Homepage:  "https://example.com",
+		Size:      51,
+		Essential: true,
+		Arches:    []dependency.Arch{{ABI: "gnu", OS: "linux", CPU: "amd64"}},
+		Origin:    &origin,
+		Section:   "misc",
+	}))
+	assert(t, out.String() == `Package: fbautostart
+Version: 2.718281828-1
+Homepage: https://example.com
+Size: 51
+Essential: yes
+Architecture: amd64
+Section: misc
+`)
+
+	/* The options don't get in the way of decoding */
+	foo := TestOmitEmptyStruct{}
+	isok(t, control.Unmarshal(&foo, &out))
+	assert(t, foo.Homepage == "https://example.com")
+	assert(t, foo.Size == 51)
+	assert(t, foo.Arches[0].CPU == "amd64")
+}
+
+type TestDependsMarshalStruct struct {
+	Source       string
+	BuildDepends dependency.Dependency `control:"Build-Depends"`
+	Depends      *dependency.Dependency
+}
+
+func TestDependencyRoundTrip(t *testing.T) {
+	input := `Source: fbautostart
+Build-Depends: debhelper-compat (= 13), python3:any (>= 3.9), libfoo-dev [!hurd-any] | libbar-dev, ${misc:Depends}
+Depends: foo
+`
+	foo := TestDependsMarshalStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(input)))
+
+	out := bytes.Buffer{}
+	isok(t, control.Marshal(&out, foo))
+	assert(t, out.String() == input)
+
+	bar := TestDependsMarshalStruct{}
+	isok(t, control.Unmarshal(&bar, &out))
+	assert(t, bar.BuildDepends.String() == foo.BuildDepends.String())
+}
+
+type TestRequiredMarshalStruct struct {
+	Package  string          `required:"true"`
+	Version  version.Version `control:"Version,omitempty" required:"true"`
+	Homepage string          `control:"Homepage,omitempty"`
+}
+
+func TestRequiredMarshal(t *testing.T) {
+	out := bytes.Buffer{}
+	foo := TestRequiredMarshalStruct{Package: "fbautostart"}
+	isok(t, foo.Version.UnmarshalControl("2.718281828-1"))
+	isok(t, control.Marshal(&out, foo))
+	assert(t, out.String() == "Package: fbautostart\nVersion: 2.718281828-1\n")
+
+	/* Missing, or as good as */
+	for _, bad := range []TestRequiredMarshalStruct{
+		{Version: foo.Version},
+		{Package: " \t", Version: foo.Version},
+		{Package: "fbautostart"},
+	} {
+		out.Reset()
+		err := control.Marshal(&out, bad)
+		notok(t, err)
+		assert(t, strings.Contains(err.Error(), "required field"))
+		assert(t, out.Len() == 0)
+	}
+}
+
+// vim: foldmethod=marker
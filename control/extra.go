@@ -0,0 +1,174 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var extraType = reflect.TypeOf(map[string]string{})
+
+// Check to see if the struct member is a catch-all for keys that no other
+// member maps to, as tagged with `control:",extra"`.
+func isExtra(fieldType reflect.StructField) bool {
+	return hasControlOption(fieldType, "extra")
+}
+
+// Add every control key the struct type maps to into keys, lowercased,
+// walking into nested structs the same way decodePointer does.
+func declaredKeys(incoming reflect.Type, keys map[string]bool) {
+	paragraphType := reflect.TypeOf(Paragraph{})
+
+	for i := 0; i < incoming.NumField(); i++ {
+		fieldType := incoming.Field(i)
+		if fieldType.Anonymous && fieldType.Type == paragraphType {
+			continue
+		}
+		if isExtra(fieldType) {
+			continue
+		}
+
+		_, registered := lookupType(fieldType.Type)
+		if fieldType.Type.Kind() == reflect.Struct && !registered &&
+			!reflect.PtrTo(fieldType.Type).Implements(unmarshalableType) {
+			declaredKeys(fieldType.Type, keys)
+		}
+		keys[strings.ToLower(controlKey(fieldType))] = true
+	}
+}
+
+// Fill in the catch-all member of the struct (if it has one) with every
+// key of the Paragraph that no other member maps to.
+func decodeExtra(incoming reflect.Value, para Paragraph) error {
+	for i := 0; i < incoming.NumField(); i++ {
+		fieldType := incoming.Type().Field(i)
+		if !isExtra(fieldType) {
+			continue
+		}
+		if fieldType.Type != extraType {
+			return fmt.Errorf(
+				"pault.ag/go/debian/control: %s is tagged extra, but isn't a map[string]string",
+				fieldType.Name,
+			)
+		}
+
+		keys := map[string]bool{}
+		declaredKeys(incoming.Type(), keys)
+
+		extra := map[string]string{}
+		for _, key := range para.Order {
+			if !keys[strings.ToLower(key)] {
+				extra[key] = para.Values[key]
+			}
+		}
+		incoming.Field(i).Set(reflect.ValueOf(extra))
+		return nil
+	}
+	return nil
+}
+
+// Add the keys in the catch-all member to the Paragraph. Keys that are in
+// order (the Order of the struct's Paragraph, when it has one) go back
+// where they were in it, right after the key they followed, and any others
+// go after everything else, sorted. Keys that are already set by another
+// member win.
+func encodeExtra(field reflect.Value, fieldType reflect.StructField, order []string, para *Paragraph) error {
+	if fieldType.Type != extraType {
+		return fmt.Errorf(
+			"pault.ag/go/debian/control: %s is tagged extra, but isn't a map[string]string",
+			fieldType.Name,
+		)
+	}
+	extra := field.Interface().(map[string]string)
+	keys := []string{}
+	seen := map[string]bool{}
+	for _, key := range order {
+		if _, ok := extra[key]; ok && !seen[key] {
+			keys = append(keys, key)
+			seen[key] = true
+		}
+	}
+	rest := []string{}
+	for key := range extra {
+		if !seen[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+
+	placed := map[string]bool{}
+	for _, key := range keys {
+		if _, ok := para.Values[key]; !ok {
+			para.Set(key, extra[key])
+			placed[key] = true
+		}
+	}
+	for _, key := range rest {
+		if _, ok := para.Values[key]; !ok {
+			para.Set(key, extra[key])
+		}
+	}
+	if len(placed) != 0 {
+		para.Order = placeByOrder(para.Order, order, placed)
+	}
+	return nil
+}
+
+// Move each of the placed keys in keys to just after the key that came
+// before it in order (or to the front, if nothing did), so that something
+// like Package, X-Foo, Version comes back out the way it went in.
+func placeByOrder(keys []string, order []string, placed map[string]bool) []string {
+	ret := []string{}
+	for _, key := range keys {
+		if !placed[key] {
+			ret = append(ret, key)
+		}
+	}
+
+	indexOf := func(key string) int {
+		for i, it := range ret {
+			if it == key {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for i, key := range order {
+		if !placed[key] || indexOf(key) >= 0 {
+			continue
+		}
+		at := 0
+		for j := i - 1; j >= 0; j-- {
+			if index := indexOf(order[j]); index >= 0 {
+				at = index + 1
+				break
+			}
+		}
+		ret = append(ret[:at], append([]string{key}, ret[at:]...)...)
+	}
+	return ret
+}
+
+// vim: foldmethod=marker
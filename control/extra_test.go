@@ -0,0 +1,176 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"pault.ag/go/debian/control"
+	"pault.ag/go/debian/version"
+)
+
+/*
+ *
+ */
+
+type TestExtraInner struct {
+	Homepage string
+}
+
+type TestExtraStruct struct {
+	Package string
+	Version version.Version
+	TestExtraInner
+	Origin string            `control:"-"`
+	Extra  map[string]string `control:",extra"`
+}
+
+func TestExtraUnmarshal(t *testing.T) {
+	foo := TestExtraStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(`Package: fbautostart
+X-Vendor-Thing: yes
+Version: 2.718281828-1
+Homepage: https://example.com
+Extra: also not a member
+Origin: Debian
+`)))
+	assert(t, foo.Package == "fbautostart")
+	assert(t, foo.Homepage == "https://example.com")
+	assert(t, len(foo.Extra) == 3)
+	assert(t, foo.Extra["X-Vendor-Thing"] == "yes")
+	assert(t, foo.Extra["Extra"] == "also not a member")
+	/* Origin is ignored on purpose, but isn't declared by anything */
+	assert(t, foo.Extra["Origin"] == "Debian")
+
+	/* Decoding again doesn't keep the old keys around */
+	isok(t, control.Unmarshal(&foo, strings.NewReader("Package: foo\n")))
+	assert(t, len(foo.Extra) == 0)
+}
+
+func TestExtraRoundTrip(t *testing.T) {
+	input := `Package: fbautostart
+Version: 2.718281828-1
+Homepage: https://example.com
+X-B: b
+X-A: a
+`
+	foo := TestExtraStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(input)))
+
+	out := bytes.Buffer{}
+	isok(t, control.Marshal(&out, foo))
+	assert(t, out.String() == `Package: fbautostart
+Version: 2.718281828-1
+Homepage: https://example.com
+X-A: a
+X-B: b
+`)
+
+	/* Declared members win over the same key in Extra */
+	foo.Extra["Package"] = "nope"
+	out.Reset()
+	isok(t, control.Marshal(&out, foo))
+	assert(t, strings.HasPrefix(out.String(), "Package: fbautostart\n"))
+	assert(t, !strings.Contains(out.String(), "nope"))
+
+	isok(t, control.ValidateStruct(foo))
+}
+
+type TestExtraParagraphStruct struct {
+	control.Paragraph
+
+	Package string
+	Extra   map[string]string `control:",extra"`
+}
+
+func TestExtraKeepsOrder(t *testing.T) {
+	input := `Package: fbautostart
+X-B: b
+X-A: a
+`
+	foo := TestExtraParagraphStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(input)))
+
+	/* With a Paragraph to go by, the keys stay where they were */
+	out := bytes.Buffer{}
+	isok(t, control.Marshal(&out, foo))
+	assert(t, out.String() == input)
+
+	/* And anything new is sorted on after them */
+	foo.Extra["X-D"] = "d"
+	foo.Extra["X-C"] = "c"
+	out.Reset()
+	isok(t, control.Marshal(&out, foo))
+	assert(t, out.String() == input+"X-C: c\nX-D: d\n")
+}
+
+type TestExtraPlacedStruct struct {
+	control.Paragraph
+
+	Package string
+	Version string
+	Extra   map[string]string `control:",extra"`
+}
+
+func TestExtraKeepsPlace(t *testing.T) {
+	input := `X-First: 1
+Package: fbautostart
+X-Foo: bar
+X-Bar: foo
+Version: 2.718281828-1
+X-Last: z
+`
+	foo := TestExtraPlacedStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(input)))
+	assert(t, len(foo.Extra) == 4)
+
+	/* Extra keys stay in amongst the declared ones */
+	out := bytes.Buffer{}
+	isok(t, control.Marshal(&out, foo))
+	assert(t, out.String() == input)
+
+	/* Even once the key that came before one is gone */
+	delete(foo.Extra, "X-Foo")
+	foo.Extra["X-New"] = "new"
+	out.Reset()
+	isok(t, control.Marshal(&out, foo))
+	assert(t, out.String() == `X-First: 1
+Package: fbautostart
+X-Bar: foo
+Version: 2.718281828-1
+X-Last: z
+X-New: new
+`)
+}
+
+type TestBadExtraStruct struct {
+	Package string
+	Extra   []string `control:",extra"`
+}
+
+func TestBadExtraUnmarshal(t *testing.T) {
+	foo := TestBadExtraStruct{}
+	notok(t, control.Unmarshal(&foo, strings.NewReader("Package: foo\n")))
+}
+
+// vim: foldmethod=marker
@@ -32,6 +32,7 @@ import (
 	"crypto/md5"
 	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
 )
 
 func hashFile(path string, algo hash.Hash) (string, error) {
@@ -77,6 +78,8 @@ func (d *DebianFileHash) Validate() (bool, error) {
 		algo = sha1.New()
 	case "sha256":
 		algo = sha256.New()
+	case "sha512":
+		algo = sha512.New()
 	default:
 		return false, fmt.Errorf("Unknown algorithm: %s", d.Algorithm)
 	}
@@ -87,7 +90,7 @@ func (d *DebianFileHash) Validate() (bool, error) {
 	return fileHash == d.Hash, nil
 }
 
-// {{{ SHA DebianFileHash (both 1 and 256)
+// {{{ SHA DebianFileHash (1, 256 and 512)
 
 type SHADebianFileHash struct {
 	DebianFileHash
@@ -96,8 +99,10 @@ type SHADebianFileHash struct {
 func (c *SHADebianFileHash) unmarshalControl(algorithm, data string) error {
 	var err error
 	c.Algorithm = algorithm
-	vals := strings.Split(data, " ")
-	if len(data) < 4 {
+	/* Release files pad the size out into a column, so there may be
+	 * more than one space between the values */
+	vals := strings.Fields(data)
+	if len(vals) < 3 {
 		return fmt.Errorf("Error: Unknown SHA Hash line: '%s'", data)
 	}
 
@@ -110,6 +115,20 @@ func (c *SHADebianFileHash) unmarshalControl(algorithm, data string) error {
 	return nil
 }
 
+func (c SHADebianFileHash) MarshalControl() (string, error) {
+	return fmt.Sprintf("%s %d %s", c.Hash, c.Size, c.Filename), nil
+}
+
+// {{{ MD5 DebianFileHash
+
+type MD5DebianFileHash struct{ SHADebianFileHash }
+
+func (c *MD5DebianFileHash) UnmarshalControl(data string) error {
+	return c.unmarshalControl("md5", data)
+}
+
+// }}}
+
 // {{{ SHA1 DebianFileHash
 
 type SHA1DebianFileHash struct{ SHADebianFileHash }
@@ -130,6 +149,16 @@ func (c *SHA256DebianFileHash) UnmarshalControl(data string) error {
 
 // }}}
 
+// {{{ SHA512 DebianFileHash
+
+type SHA512DebianFileHash struct{ SHADebianFileHash }
+
+func (c *SHA512DebianFileHash) UnmarshalControl(data string) error {
+	return c.unmarshalControl("sha512", data)
+}
+
+// }}}
+
 // }}}
 
 // vim: foldmethod=marker
@@ -0,0 +1,131 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A SourceFormat is the value of a .dsc's Format field, such as
+// "3.0 (quilt)", split into the format Version ("3.0") and the Variant
+// in parens ("quilt"). The legacy "1.0" and "2.0" formats have no Variant.
+type SourceFormat struct {
+	Version string
+	Variant string
+}
+
+// Parse a Format field value into a SourceFormat.
+func ParseSourceFormat(data string) (SourceFormat, error) {
+	ret := SourceFormat{}
+	return ret, ret.UnmarshalControl(data)
+}
+
+func (f *SourceFormat) UnmarshalControl(data string) error {
+	data = strings.TrimSpace(data)
+	version, variant := data, ""
+	if i := strings.Index(data, " "); i >= 0 {
+		version = data[:i]
+		variant = strings.TrimSpace(data[i:])
+		if !strings.HasPrefix(variant, "(") || !strings.HasSuffix(variant, ")") {
+			return fmt.Errorf("Malformed source format variant: '%s'", data)
+		}
+		variant = variant[1 : len(variant)-1]
+		if variant == "" {
+			return fmt.Errorf("Empty source format variant: '%s'", data)
+		}
+	}
+
+	switch version {
+	case "1.0", "2.0":
+		if variant != "" {
+			return fmt.Errorf("Source format %s has no variants: '%s'", version, data)
+		}
+	case "3.0":
+		if variant == "" {
+			return fmt.Errorf("Source format 3.0 needs a variant: '%s'", data)
+		}
+	default:
+		return fmt.Errorf("Unknown source format: '%s'", data)
+	}
+
+	f.Version = version
+	f.Variant = variant
+	return nil
+}
+
+// The only variants of the 3.0 format dpkg-source knows how to deal with.
+var sourceFormatVariants = map[string]bool{
+	"native": true,
+	"quilt":  true,
+	"git":    true,
+	"bzr":    true,
+	"custom": true,
+}
+
+// Check that this is a format dpkg-source can actually unpack, which is
+// 1.0, 2.0, or 3.0 with one of the native, quilt, git, bzr or custom
+// variants. Parsing is more lenient than this, and will take any variant
+// of 3.0; this is what a strict Decoder or Encoder uses.
+func (f SourceFormat) Valid() error {
+	switch f.Version {
+	case "1.0", "2.0":
+		if f.Variant == "" {
+			return nil
+		}
+	case "3.0":
+		if sourceFormatVariants[f.Variant] {
+			return nil
+		}
+	}
+	return fmt.Errorf("Unknown source format: '%s'", f)
+}
+
+// ValidateControl checks the format is Valid, for a strict Decoder or
+// Encoder (see Validatable).
+func (f SourceFormat) ValidateControl() error {
+	return f.Valid()
+}
+
+func (f SourceFormat) MarshalControl() (string, error) {
+	return f.String(), nil
+}
+
+func (f SourceFormat) String() string {
+	if f.Variant == "" {
+		return f.Version
+	}
+	return fmt.Sprintf("%s (%s)", f.Version, f.Variant)
+}
+
+// Return true if this format is known to be native, which is only the case
+// for "3.0 (native)". A "1.0" package may be native or not, depending on
+// whether it ships a .diff.gz, which this can't tell; see DSC.IsNative.
+func (f SourceFormat) IsNative() bool {
+	return f.Version == "3.0" && f.Variant == "native"
+}
+
+// Return true if this format is "3.0 (quilt)".
+func (f SourceFormat) IsQuilt() bool {
+	return f.Version == "3.0" && f.Variant == "quilt"
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,176 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"pault.ag/go/debian/control"
+)
+
+/*
+ *
+ */
+
+func TestSourceFormatParse(t *testing.T) {
+	for _, tc := range []struct {
+		input   string
+		version string
+		variant string
+		native  bool
+	}{
+		{"1.0", "1.0", "", false},
+		{"2.0", "2.0", "", false},
+		{"3.0 (quilt)", "3.0", "quilt", false},
+		{"3.0 (native)", "3.0", "native", true},
+	} {
+		format, err := control.ParseSourceFormat(tc.input)
+		isok(t, err)
+		assert(t, format.Version == tc.version)
+		assert(t, format.Variant == tc.variant)
+		assert(t, format.IsNative() == tc.native)
+		assert(t, format.String() == tc.input)
+	}
+
+	for _, input := range []string{"", "3.0", "1.0 (quilt)", "3.0 quilt", "3.0 ()", "4.0 (quilt)"} {
+		_, err := control.ParseSourceFormat(input)
+		notok(t, err)
+	}
+}
+
+func TestSourceFormatValid(t *testing.T) {
+	for _, input := range []string{
+		"1.0", "2.0", "3.0 (native)", "3.0 (quilt)",
+		"3.0 (git)", "3.0 (bzr)", "3.0 (custom)",
+	} {
+		format, err := control.ParseSourceFormat(input)
+		isok(t, err)
+		isok(t, format.Valid())
+	}
+
+	/* Parses fine, but dpkg-source has no idea what to do with it */
+	format, err := control.ParseSourceFormat("3.0 (svn)")
+	isok(t, err)
+	notok(t, format.Valid())
+	notok(t, control.SourceFormat{Version: "4.0"}.Valid())
+}
+
+type TestFormatStruct struct {
+	Source string
+	Format control.SourceFormat
+}
+
+func TestSourceFormatStrict(t *testing.T) {
+	for input, ok := range map[string]bool{
+		"Source: foo\nFormat: 3.0 (quilt)\n": true,
+		"Source: foo\nFormat: 3.0 (svn)\n":   false,
+		"Source: foo\n":                      true,
+	} {
+		lenient := control.NewDecoder(strings.NewReader(input))
+		isok(t, lenient.Decode(&TestFormatStruct{}))
+
+		strict := control.NewDecoder(strings.NewReader(input))
+		strict.SetStrict(true)
+		err := strict.Decode(&TestFormatStruct{})
+		if (err == nil) != ok {
+			t.Errorf("%q: strict Decode gave %v", input, err)
+		}
+	}
+
+	out := bytes.Buffer{}
+	encoder := control.NewEncoder(&out)
+	encoder.SetStrict(true)
+	notok(t, encoder.Encode(TestFormatStruct{
+		Source: "foo",
+		Format: control.SourceFormat{Version: "3.0", Variant: "svn"},
+	}))
+}
+
+func TestDSCFormatStrict(t *testing.T) {
+	for input, ok := range map[string]bool{
+		"Format: 3.0 (quilt)\nSource: foo\n": true,
+		"Format: 3.0 (svn)\nSource: foo\n":   false,
+		"Format: 4.0\nSource: foo\n":         false,
+		"Source: foo\n":                      true,
+	} {
+		/* Format is just a string, so lenient mode takes anything */
+		lenient := control.NewDecoder(strings.NewReader(input))
+		isok(t, lenient.Decode(&control.DSC{}))
+
+		strict := control.NewDecoder(strings.NewReader(input))
+		strict.SetStrict(true)
+		err := strict.Decode(&control.DSC{})
+		if (err == nil) != ok {
+			t.Errorf("%q: strict Decode gave %v", input, err)
+		}
+	}
+
+	out := bytes.Buffer{}
+	encoder := control.NewEncoder(&out)
+	encoder.SetStrict(true)
+	notok(t, encoder.Encode(control.DSC{Format: "3.0 (svn)", Source: "foo"}))
+}
+
+/* Has a Valid method, but isn't Validatable, so it's left alone */
+type TestNotValidatable string
+
+func (TestNotValidatable) Valid() error {
+	return fmt.Errorf("Never valid")
+}
+
+type TestValidStruct struct {
+	Source string
+	Thing  TestNotValidatable
+}
+
+func TestOnlyValidatableChecked(t *testing.T) {
+	decoder := control.NewDecoder(strings.NewReader("Source: foo\nThing: bar\n"))
+	decoder.SetStrict(true)
+	isok(t, decoder.Decode(&TestValidStruct{}))
+}
+
+func TestDSCIsNative(t *testing.T) {
+	for _, tc := range []struct {
+		format string
+		files  string
+		native bool
+	}{
+		{"1.0", " 06495f9b23b1c9b1bf35c2346cb48f63 92748 foo_1.0.tar.gz\n", true},
+		{"1.0", " 06495f9b23b1c9b1bf35c2346cb48f63 92748 foo_1.0.orig.tar.gz\n f58c0e0bf4d56461e776232484c07301 2356 foo_1.0-1.diff.gz\n", false},
+		{"2.0", " 06495f9b23b1c9b1bf35c2346cb48f63 92748 foo_1.0.orig.tar.gz\n", false},
+		{"3.0 (quilt)", " 06495f9b23b1c9b1bf35c2346cb48f63 92748 foo_1.0.orig.tar.gz\n", false},
+		{"3.0 (native)", " 06495f9b23b1c9b1bf35c2346cb48f63 92748 foo_1.0.tar.xz\n", true},
+	} {
+		dsc, err := control.ParseDsc(bufio.NewReader(strings.NewReader(
+			"Format: "+tc.format+"\nSource: foo\nFiles:\n"+tc.files,
+		)), "")
+		isok(t, err)
+		native, err := dsc.IsNative()
+		isok(t, err)
+		assert(t, native == tc.native)
+	}
+}
+
+// vim: foldmethod=marker
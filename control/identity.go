@@ -0,0 +1,160 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// An Identity is a person (or team) as written in the Maintainer, Uploaders
+// or Changed-By fields, such as "Paul Tagliamonte <paultag@debian.org>".
+//
+// Name is decoded for display: surrounding double quotes are dropped (along
+// with the backslashes escaping any \" or \\ inside of them), and RFC2047
+// encoded-words (such as "=?UTF-8?q?J=C3=B6rg?=", which some tools
+// write for non-ASCII names) are turned into plain UTF-8. RawName is the
+// name exactly as it was written, which is what gets written back out.
+//
+// Comment is whatever was in parens after the email, as in
+// "Jane Doe <jane@example.com> (team lead)", without the parens.
+type Identity struct {
+	Name    string
+	RawName string
+	Email   string
+	Comment string
+}
+
+// Split a trailing comment in parens off the end of a value, as in
+// "Jane Doe <jane@example.com> (team lead)", returning the value (with
+// the whitespace before the comment trimmed off) and the comment, without
+// its parens. Parens may nest inside the comment. If the value doesn't end
+// in a comment, it's returned as-is, with an empty comment.
+func SplitComment(data string) (string, string) {
+	if !strings.HasSuffix(data, ")") {
+		return data, ""
+	}
+	depth := 0
+	for i := len(data) - 1; i >= 0; i-- {
+		switch data[i] {
+		case ')':
+			depth++
+		case '(':
+			depth--
+			if depth == 0 {
+				return strings.TrimSpace(data[:i]), data[i+1 : len(data)-1]
+			}
+		}
+	}
+	return data, ""
+}
+
+// Parse an Identity out of a string of the form "Name <email>".
+func ParseIdentity(data string) (Identity, error) {
+	ret := Identity{}
+	return ret, ret.UnmarshalControl(data)
+}
+
+func (i *Identity) UnmarshalControl(data string) error {
+	data, comment := SplitComment(strings.TrimSpace(data))
+	start := strings.LastIndex(data, "<")
+	if start < 0 || !strings.HasSuffix(data, ">") {
+		return fmt.Errorf("Identity '%s' is not of the form 'Name <email>'", data)
+	}
+
+	rawName := strings.TrimSpace(data[:start])
+	name, err := decodeName(rawName)
+	if err != nil {
+		return fmt.Errorf("Identity '%s' has an encoded-word we can't decode: %v", data, err)
+	}
+
+	i.Name = name
+	i.RawName = rawName
+	i.Email = data[start+1 : len(data)-1]
+	i.Comment = comment
+	return nil
+}
+
+// Turn a name as written into a name for display, by dropping any
+// surrounding double quotes (and unescaping what's inside of them), and
+// decoding RFC2047 encoded-words.
+func decodeName(rawName string) (string, error) {
+	name := rawName
+	if len(name) >= 2 && strings.HasPrefix(name, `"`) && strings.HasSuffix(name, `"`) {
+		name = unquoteName(name[1 : len(name)-1])
+	}
+	decoder := mime.WordDecoder{}
+	return decoder.DecodeHeader(name)
+}
+
+// Drop the backslash from each backslash-escaped character of a quoted
+// name, so that `Foo \"Bar\"` is Foo "Bar". A lone backslash at the very
+// end is kept as-is.
+func unquoteName(name string) string {
+	if !strings.Contains(name, `\`) {
+		return name
+	}
+	ret := strings.Builder{}
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+1 < len(name) {
+			i++
+		}
+		ret.WriteByte(name[i])
+	}
+	return ret.String()
+}
+
+// Escape the backslashes and double quotes in a name, and put it in double
+// quotes, which is the inverse of what decodeName does to a quoted name.
+func quoteName(name string) string {
+	return `"` + nameEscaper.Replace(name) + `"`
+}
+
+var nameEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func (i Identity) MarshalControl() (string, error) {
+	return i.String(), nil
+}
+
+// Return the Identity as "Name <email>", using the RawName if it's set and
+// still decodes to the Name (so a Name that's been changed since parsing
+// isn't clobbered by the old one). The Comment (if any) goes on the end, in
+// parens.
+func (i Identity) String() string {
+	name := i.RawName
+	if decoded, err := decodeName(name); name == "" || err != nil || decoded != i.Name {
+		name = i.Name
+		if strings.ContainsAny(name, ",\"\\") {
+			name = quoteName(name)
+		}
+	}
+	ret := "<" + i.Email + ">"
+	if name != "" {
+		ret = name + " " + ret
+	}
+	if i.Comment != "" {
+		ret += " (" + i.Comment + ")"
+	}
+	return ret
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,181 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"pault.ag/go/debian/control"
+)
+
+/*
+ *
+ */
+
+func TestIdentityParse(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		name  string
+		email string
+	}{
+		{"Paul Tagliamonte <paultag@debian.org>", "Paul Tagliamonte", "paultag@debian.org"},
+		{`"Doe, Jane" <jdoe@example.com>`, "Doe, Jane", "jdoe@example.com"},
+		{"=?UTF-8?q?J=C3=B6rg_Frings-F=C3=BCrst?= <debian@jff.email>", "Jörg Frings-Fürst", "debian@jff.email"},
+		{"=?utf-8?b?SsO2cmc=?= Example <joerg@example.com>", "Jörg Example", "joerg@example.com"},
+		{"Debian Go Packaging Team <team+pkg-go@tracker.debian.org>", "Debian Go Packaging Team", "team+pkg-go@tracker.debian.org"},
+	} {
+		identity, err := control.ParseIdentity(tc.input)
+		isok(t, err)
+		assert(t, identity.Name == tc.name)
+		assert(t, identity.Email == tc.email)
+		/* The raw form is what goes back out */
+		assert(t, identity.String() == tc.input)
+	}
+
+	for _, input := range []string{"", "Paul Tagliamonte", "Paul <paultag@debian.org", "=?x-unknown?q?J=C3=B6rg?= <a@b>"} {
+		_, err := control.ParseIdentity(input)
+		notok(t, err)
+	}
+}
+
+func TestIdentityString(t *testing.T) {
+	assert(t, control.Identity{Name: "Jörg", Email: "j@example.com"}.String() == "Jörg <j@example.com>")
+	assert(t, control.Identity{Name: "Doe, Jane", Email: "j@example.com"}.String() == `"Doe, Jane" <j@example.com>`)
+	assert(t, control.Identity{Name: `Foo "Bar"`, Email: "x"}.String() == `"Foo \"Bar\"" <x>`)
+	assert(t, control.Identity{Name: `Foo\Bar`, Email: "x"}.String() == `"Foo\\Bar" <x>`)
+}
+
+func TestIdentityEscapedQuotes(t *testing.T) {
+	for input, name := range map[string]string{
+		`"Foo \"Bar\"" <x>`:      `Foo "Bar"`,
+		`"Foo\\Bar" <x>`:         `Foo\Bar`,
+		`"Doe, \"JD\" Jane" <x>`: `Doe, "JD" Jane`,
+		`"Trailing \\\"" <x>`:    `Trailing \"`,
+	} {
+		identity, err := control.ParseIdentity(input)
+		isok(t, err)
+		assert(t, identity.Name == name)
+		assert(t, identity.String() == input)
+
+		/* Writing out the Name alone gets us back the same thing */
+		assert(t, control.Identity{Name: identity.Name, Email: "x"}.String() == input)
+	}
+
+	/* An escaped quote doesn't end the quoted name in a list */
+	foo := TestIdentityStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(`Maintainer: Bob <bob@example.com>
+Uploaders: "Doe, \"JD\", Jane" <jdoe@example.com>, Bob <bob@example.com>
+`)))
+	assert(t, len(foo.Uploaders) == 2)
+	assert(t, foo.Uploaders[0].Name == `Doe, "JD", Jane`)
+	assert(t, foo.Uploaders[1].Name == "Bob")
+}
+
+type TestIdentityStruct struct {
+	Maintainer control.Identity
+	Uploaders  []control.Identity `delim:"," strip:"\n\r\t "`
+}
+
+func TestIdentityUnmarshal(t *testing.T) {
+	foo := TestIdentityStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(`Maintainer: =?UTF-8?q?J=C3=B6rg?= <joerg@example.com>
+Uploaders: "Doe, Jane" <jdoe@example.com>, Bob <bob@example.com>
+`)))
+	assert(t, foo.Maintainer.Name == "Jörg")
+	assert(t, foo.Maintainer.RawName == "=?UTF-8?q?J=C3=B6rg?=")
+	assert(t, len(foo.Uploaders) == 2)
+	assert(t, foo.Uploaders[0].Name == "Doe, Jane")
+	assert(t, foo.Uploaders[1].Email == "bob@example.com")
+}
+
+func TestIdentityChangedName(t *testing.T) {
+	foo := TestIdentityStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(`Maintainer: =?UTF-8?q?J=C3=B6rg?= <joerg@example.com>
+Uploaders: "Doe, Jane" <jdoe@example.com>
+`)))
+
+	/* Untouched, the name goes back out the way it came in */
+	out := bytes.Buffer{}
+	isok(t, control.Marshal(&out, foo))
+	assert(t, out.String() == `Maintainer: =?UTF-8?q?J=C3=B6rg?= <joerg@example.com>
+Uploaders: "Doe, Jane" <jdoe@example.com>
+`)
+
+	/* Once changed, the stale RawName is ignored */
+	foo.Maintainer.Name = "Jürgen"
+	foo.Uploaders[0].Name = "Roe, Jane"
+	out = bytes.Buffer{}
+	isok(t, control.Marshal(&out, foo))
+	assert(t, out.String() == `Maintainer: Jürgen <joerg@example.com>
+Uploaders: "Roe, Jane" <jdoe@example.com>
+`)
+}
+
+func TestIdentityComment(t *testing.T) {
+	identity, err := control.ParseIdentity("Jane Doe <jane@x> (team lead)")
+	isok(t, err)
+	assert(t, identity.Name == "Jane Doe")
+	assert(t, identity.Email == "jane@x")
+	assert(t, identity.Comment == "team lead")
+	assert(t, identity.String() == "Jane Doe <jane@x> (team lead)")
+
+	/* Parens in the name aren't a comment */
+	identity, err = control.ParseIdentity("Jane (JD) Doe <jane@x>")
+	isok(t, err)
+	assert(t, identity.Name == "Jane (JD) Doe")
+	assert(t, identity.Comment == "")
+
+	identity, err = control.ParseIdentity("Jane Doe <jane@x> (lead (go))")
+	isok(t, err)
+	assert(t, identity.Comment == "lead (go)")
+
+	_, err = control.ParseIdentity("Jane Doe (team lead)")
+	notok(t, err)
+
+	foo := TestIdentityStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(`Maintainer: Jane Doe <jane@x> (team lead)
+Uploaders: Bob <bob@example.com> (go, python), "Doe, John" <jdoe@example.com>
+`)))
+	assert(t, foo.Maintainer.Comment == "team lead")
+	assert(t, len(foo.Uploaders) == 2)
+	assert(t, foo.Uploaders[0].Comment == "go, python")
+	assert(t, foo.Uploaders[1].Name == "Doe, John")
+}
+
+func TestSplitComment(t *testing.T) {
+	for input, expected := range map[string][2]string{
+		"foo (bar)":     {"foo", "bar"},
+		"foo":           {"foo", ""},
+		"foo (a (b) c)": {"foo", "a (b) c"},
+		"foo bar)":      {"foo bar)", ""},
+		"(only)":        {"", "only"},
+		"foo (bar) baz": {"foo (bar) baz", ""},
+	} {
+		value, comment := control.SplitComment(input)
+		if value != expected[0] || comment != expected[1] {
+			t.Errorf("%q: got %q, %q", input, value, comment)
+		}
+	}
+}
+
+// vim: foldmethod=marker
@@ -22,6 +22,12 @@ package control
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
 
 	"pault.ag/go/debian/dependency"
 	"pault.ag/go/debian/version"
@@ -46,8 +52,8 @@ type BinaryIndex struct {
 	MultiArch      string `control:"Multi-Arch"`
 	Description    string
 	Homepage       string
-	DescriptionMD5 string   `control:"Description-md5"`
-	Tags           []string `delim:", "`
+	DescriptionMD5 string `control:"Description-md5"`
+	Tags           Tags   `control:"Tag"`
 	Section        string
 	Priority       string
 	Filename       string
@@ -55,6 +61,10 @@ type BinaryIndex struct {
 	MD5sum         string
 	SHA1           string
 	SHA256         string
+
+	// The Origin of this one package, as used for apt pinning. This is not
+	// the same thing as the Origin of the whole archive in the Release file.
+	Origin string
 }
 
 // Parse the Depends Dependency relation on this package.
@@ -72,6 +82,16 @@ func (index *BinaryIndex) GetBreaks() dependency.Dependency {
 	return index.getOptionalDependencyField("Breaks")
 }
 
+// Parse the Depends Conflicts relation on this package.
+func (index *BinaryIndex) GetConflicts() dependency.Dependency {
+	return index.getOptionalDependencyField("Conflicts")
+}
+
+// Parse the Depends Provides relation on this package.
+func (index *BinaryIndex) GetProvides() dependency.Dependency {
+	return index.getOptionalDependencyField("Provides")
+}
+
 // Parse the Depends Replaces relation on this package.
 func (index *BinaryIndex) GetReplaces() dependency.Dependency {
 	return index.getOptionalDependencyField("Replaces")
@@ -82,6 +102,87 @@ func (index *BinaryIndex) GetPreDepends() dependency.Dependency {
 	return index.getOptionalDependencyField("Pre-Depends")
 }
 
+// Return the md5 of the Description, computed the same way apt and dak do
+// it for the Description-md5 field: the full (multi-line) Description, as
+// written in the control file, with a trailing newline.
+func (index *BinaryIndex) computeDescriptionMD5() string {
+	description := strings.Join(strings.Split(index.Description, "\n"), "\n ")
+	sum := md5.Sum([]byte(description + "\n"))
+	return hex.EncodeToString(sum[:])
+}
+
+// Validate the BinaryIndex by checking that the fields agree with each other.
+//
+// Currently, this checks the Description-md5 against the Description. Since
+// most Packages files only carry the synopsis in Description (the long
+// description lives in the Translation files), this is only checked when
+// the Description contains the long description as well.
+func (index *BinaryIndex) Validate() (bool, error) {
+	if index.DescriptionMD5 != "" && strings.Contains(index.Description, "\n") {
+		if sum := index.computeDescriptionMD5(); sum != index.DescriptionMD5 {
+			return false, fmt.Errorf(
+				"Error! Description-md5 mismatch! %s != %s",
+				sum, index.DescriptionMD5,
+			)
+		}
+	}
+	return true, nil
+}
+
+func relationTriggers(dep dependency.Dependency, other BinaryIndex) bool {
+	provides := other.GetProvides()
+	for _, possibility := range dep.GetAllPossibilities() {
+		if possibility.Name == other.Package {
+			if possibility.Version == nil || possibility.Version.Triggers(other.Version) {
+				return true
+			}
+		}
+		for _, provided := range provides.GetAllPossibilities() {
+			if possibility.Name != provided.Name {
+				continue
+			}
+			if possibility.Version == nil {
+				return true
+			}
+			/* Only a versioned Provides can match a versioned relation */
+			if provided.Version == nil || provided.Version.Operator != "=" {
+				continue
+			}
+			if v, err := version.Parse(provided.Version.Number); err == nil && possibility.Version.Triggers(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Check to see if this package Breaks the other package, that is, if any
+// of the Breaks relations name the other package, and the other package's
+// Version falls within the (optional) version range given.
+//
+// Note that this is inverted from Depends: for Breaks, being in the range
+// is the bad case.
+//
+// Relations on a virtual package match anything that Provides it, except
+// for the package itself, so the usual idiom of Provides and Conflicts on
+// the same virtual name (like mail-transport-agent) doesn't flag a package
+// as breaking itself.
+func (index *BinaryIndex) Breaks(other BinaryIndex) bool {
+	if other.Package == index.Package {
+		return false
+	}
+	return relationTriggers(index.GetBreaks(), other)
+}
+
+// Check to see if this package Conflicts with the other package, using
+// the same rules as BinaryIndex.Breaks.
+func (index *BinaryIndex) Conflicts(other BinaryIndex) bool {
+	if other.Package == index.Package {
+		return false
+	}
+	return relationTriggers(index.GetConflicts(), other)
+}
+
 // The SourceIndex struct represents the exported APT Source index
 // file, as seen on Debian (and Debian derived) mirrors, as well as the
 // cached version in /var/lib/apt/lists/.
@@ -101,7 +202,7 @@ type SourceIndex struct {
 
 	Architecture []dependency.Arch
 
-	StandardsVersion string
+	StandardsVersion string `control:"Standards-Version"`
 	Format           string
 	Files            []string `delim:"\n"`
 	VcsBrowser       string   `control:"Vcs-Browser"`
@@ -119,6 +220,16 @@ func (index *SourceIndex) GetBuildDepends() dependency.Dependency {
 	return index.getOptionalDependencyField("Build-Depends")
 }
 
+// Parse the Depends Build-Conflicts relation on this package.
+func (index *SourceIndex) GetBuildConflicts() dependency.Dependency {
+	return index.getOptionalDependencyField("Build-Conflicts")
+}
+
+// Parse the Depends Build-Conflicts-Indep relation on this package.
+func (index *SourceIndex) GetBuildConflictsIndep() dependency.Dependency {
+	return index.getOptionalDependencyField("Build-Conflicts-Indep")
+}
+
 // Given a reader, parse out a list of BinaryIndex structs.
 func ParseBinaryIndex(reader *bufio.Reader) (ret []BinaryIndex, err error) {
 	ret = []BinaryIndex{}
@@ -126,6 +237,205 @@ func ParseBinaryIndex(reader *bufio.Reader) (ret []BinaryIndex, err error) {
 	return ret, err
 }
 
+// Given a reader, parse out BinaryIndex structs, partitioned by their
+// Architecture (such as "amd64"). Architecture: all packages are returned
+// under their own "all" key; use DuplicateArchAll to fold them into each
+// of the other Architectures instead. Within each list, packages are kept
+// in the order they were read in. A package without an Architecture can't
+// go anywhere, and is an error.
+func PartitionByArch(reader io.Reader) (map[string][]BinaryIndex, error) {
+	ret := map[string][]BinaryIndex{}
+	decoder := NewDecoder(reader)
+	for {
+		index := BinaryIndex{}
+		if err := decoder.Decode(&index); err == io.EOF {
+			return ret, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if index.Architecture == (dependency.Arch{}) {
+			return nil, fmt.Errorf(
+				"Package '%s' has no Architecture (in paragraph %d)",
+				index.Package, decoder.count-1,
+			)
+		}
+		arch := index.Architecture.String()
+		ret[arch] = append(ret[arch], index)
+	}
+}
+
+// Given the output of PartitionByArch, return a new map where the "all"
+// packages have been appended to every other Architecture's list, which is
+// what a per-arch Packages file contains. If there are no other
+// Architectures, the "all" key is kept as-is.
+func DuplicateArchAll(arches map[string][]BinaryIndex) map[string][]BinaryIndex {
+	ret := map[string][]BinaryIndex{}
+	for arch, packages := range arches {
+		if arch == "all" {
+			continue
+		}
+		ret[arch] = append(append([]BinaryIndex{}, packages...), arches["all"]...)
+	}
+	if len(ret) == 0 && len(arches["all"]) != 0 {
+		ret["all"] = arches["all"]
+	}
+	return ret
+}
+
+// A PackagesIndex gives random access to the BinaryIndex entries of a
+// Packages file, given a map of package name to the byte offset of its
+// Paragraph (as reported by Decoder.Offset), so that looking up a single
+// package doesn't need to scan the whole file.
+type PackagesIndex struct {
+	reader  io.ReaderAt
+	Offsets map[string]int64
+}
+
+// Create a new PackagesIndex over the given Packages file. If offsets is
+// nil, one is built by scanning through the reader once.
+func NewPackagesIndex(reader io.ReaderAt, offsets map[string]int64) (*PackagesIndex, error) {
+	if offsets == nil {
+		var err error
+		offsets, err = PackagesOffsets(io.NewSectionReader(reader, 0, 1<<62))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &PackagesIndex{reader: reader, Offsets: offsets}, nil
+}
+
+// Scan through a Packages file, and return a map of each package's name to
+// the byte offset its Paragraph starts at. If a package is listed more than
+// once, the first one wins.
+func PackagesOffsets(reader io.Reader) (map[string]int64, error) {
+	ret := map[string]int64{}
+	decoder := NewDecoder(reader)
+	for {
+		para := Paragraph{}
+		if err := decoder.Decode(&para); err == io.EOF {
+			return ret, nil
+		} else if err != nil {
+			return nil, err
+		}
+		name := para.Values["Package"]
+		if _, ok := ret[name]; !ok {
+			ret[name] = decoder.Offset()
+		}
+	}
+}
+
+// Scan through a Packages file, and return the name of each package, in the
+// order they're listed. Only the Package field is picked out of each
+// Paragraph; nothing else is parsed, which makes this a lot quicker than
+// decoding the whole index just to get at the names.
+func PackageNames(reader io.Reader) ([]string, error) {
+	ret := []string{}
+	in := bufio.NewReader(reader)
+	found := false
+	continued := false
+	for {
+		line, err := in.ReadSlice('\n')
+		if !continued {
+			if len(line) == 0 || line[0] == '\n' {
+				/* Blank line, so on to the next Paragraph */
+				found = false
+			} else if !found && bytes.HasPrefix(line, []byte("Package:")) {
+				ret = append(ret, strings.TrimSpace(string(line[len("Package:"):])))
+				found = true
+			}
+		}
+		/* Lines that don't fit the buffer come in bits; only the start
+		 * of the line can have a key on it */
+		continued = err == bufio.ErrBufferFull
+
+		if err == io.EOF {
+			return ret, nil
+		} else if err != nil && !continued {
+			return nil, err
+		}
+	}
+}
+
+// Look up the BinaryIndex for the named package, reading only its own
+// Paragraph.
+func (idx *PackagesIndex) Lookup(name string) (*BinaryIndex, error) {
+	offset, ok := idx.Offsets[name]
+	if !ok {
+		return nil, fmt.Errorf("No package named '%s' in the index", name)
+	}
+
+	ret := BinaryIndex{}
+	decoder := NewDecoder(io.NewSectionReader(idx.reader, offset, 1<<62))
+	if err := decoder.Decode(&ret); err == io.EOF {
+		return nil, fmt.Errorf("No Paragraph at offset %d", offset)
+	} else if err != nil {
+		return nil, err
+	}
+	if ret.Package != name {
+		return nil, fmt.Errorf("Offset %d is for '%s', not '%s'", offset, ret.Package, name)
+	}
+	return &ret, nil
+}
+
+// Read the index into a map keyed by name:arch, along with the keys in the
+// order they were read in. If the same name:arch is in there more than
+// once, the first entry is the one that's kept.
+func readPackagesByKey(reader io.Reader) ([]string, map[string]BinaryIndex, error) {
+	keys := []string{}
+	ret := map[string]BinaryIndex{}
+	decoder := NewDecoder(reader)
+	for {
+		index := BinaryIndex{}
+		if err := decoder.Decode(&index); err == io.EOF {
+			return keys, ret, nil
+		} else if err != nil {
+			return nil, nil, err
+		}
+		key := index.Package + ":" + index.Architecture.String()
+		/* Anything after the first with the same key is dropped */
+		if _, ok := ret[key]; !ok {
+			keys = append(keys, key)
+			ret[key] = index
+		}
+	}
+}
+
+// Compare two Packages indices, matching packages up by their name and
+// Architecture. Packages only in the new index are added, those only in
+// the old index are removed, and those whose Version went up or down (as
+// per version.Compare) are changed; for those, the entry from the new
+// index is returned. Each list is in the order of the index it came from.
+//
+// Should either index list the same name and Architecture more than once
+// (as a flat repository holding a few versions of a package might), only
+// the first of them counts, and the rest are ignored.
+func DiffPackages(old, new io.Reader) (added, removed, changed []BinaryIndex, err error) {
+	oldKeys, oldPackages, err := readPackagesByKey(old)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	newKeys, newPackages, err := readPackagesByKey(new)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	added, removed, changed = []BinaryIndex{}, []BinaryIndex{}, []BinaryIndex{}
+	for _, key := range newKeys {
+		oldIndex, ok := oldPackages[key]
+		if !ok {
+			added = append(added, newPackages[key])
+		} else if version.Compare(oldIndex.Version, newPackages[key].Version) != 0 {
+			changed = append(changed, newPackages[key])
+		}
+	}
+	for _, key := range oldKeys {
+		if _, ok := newPackages[key]; !ok {
+			removed = append(removed, oldPackages[key])
+		}
+	}
+	return added, removed, changed, nil
+}
+
 // Given a reader, parse out a list of SourceIndex structs.
 func ParseSourceIndex(reader *bufio.Reader) (ret []SourceIndex, err error) {
 	ret = []SourceIndex{}
@@ -133,4 +443,112 @@ func ParseSourceIndex(reader *bufio.Reader) (ret []SourceIndex, err error) {
 	return ret, err
 }
 
+// Given a list of SourceIndex entries, return a map from the name of each
+// package that's build-depended on to the names of the source packages
+// that build-depend on it (in any of Build-Depends, Build-Depends-Arch or
+// Build-Depends-Indep), in the order they were given. Every alternative
+// counts, and architecture restrictions are not taken into account, so
+// this is the set of sources that may need a rebuild when a package changes.
+func BuildReverseBuildDepends(srcs []SourceIndex) map[string][]string {
+	ret := map[string][]string{}
+	seen := map[string]map[string]bool{}
+
+	for _, src := range srcs {
+		for _, field := range []string{
+			"Build-Depends", "Build-Depends-Arch", "Build-Depends-Indep",
+		} {
+			dep := src.getOptionalDependencyField(field)
+			for _, possibility := range dep.GetAllPossibilities() {
+				if possibility.Substvar {
+					continue
+				}
+				name := possibility.Name
+				if seen[name] == nil {
+					seen[name] = map[string]bool{}
+				}
+				if seen[name][src.Package] {
+					continue
+				}
+				seen[name][src.Package] = true
+				ret[name] = append(ret[name], src.Package)
+			}
+		}
+	}
+	return ret
+}
+
+// Return the names of the binary packages this source builds, going by
+// the Binary field, as well as the Package-List field, if there is one.
+func (index *SourceIndex) binaryNames() []string {
+	ret := []string{}
+	for _, binary := range index.Binaries {
+		if binary = strings.TrimSpace(binary); binary != "" {
+			ret = append(ret, binary)
+		}
+	}
+	for _, line := range strings.Split(index.Values["Package-List"], "\n") {
+		/* hello deb devel optional arch=any */
+		if fields := strings.Fields(line); len(fields) != 0 {
+			ret = append(ret, fields[0])
+		}
+	}
+	return ret
+}
+
+// Return the name of the source package this binary was built from, which
+// is the Source field (without any version in parens), or the Package
+// itself if there's no Source field.
+func (index *BinaryIndex) sourceName() string {
+	if fields := strings.Fields(index.Source); len(fields) != 0 {
+		return fields[0]
+	}
+	return index.Package
+}
+
+// Check a Sources index against a Packages index for the two of them to
+// agree with each other.
+//
+// orphanBinaries are the binary packages in pkgs whose source (going by
+// their Source field) isn't in srcs, or is, but doesn't list the binary in
+// its Binary or Package-List field. missingBinaries are the binaries that
+// a source in srcs lists, but that aren't in pkgs at all. Since
+// Package-List names the binaries for every architecture (and udebs and
+// all), pkgs should cover all of those for missingBinaries to be useful.
+//
+// Both lists are in the order they were found in, without duplicates.
+func CrossCheck(srcs []SourceIndex, pkgs []BinaryIndex) (orphanBinaries, missingBinaries []string) {
+	builds := map[string]map[string]bool{}
+	for _, src := range srcs {
+		if builds[src.Package] == nil {
+			builds[src.Package] = map[string]bool{}
+		}
+		for _, binary := range src.binaryNames() {
+			builds[src.Package][binary] = true
+		}
+	}
+
+	present := map[string]bool{}
+	orphaned := map[string]bool{}
+	for _, pkg := range pkgs {
+		present[pkg.Package] = true
+		if builds[pkg.sourceName()][pkg.Package] || orphaned[pkg.Package] {
+			continue
+		}
+		orphaned[pkg.Package] = true
+		orphanBinaries = append(orphanBinaries, pkg.Package)
+	}
+
+	missing := map[string]bool{}
+	for _, src := range srcs {
+		for _, binary := range src.binaryNames() {
+			if present[binary] || missing[binary] {
+				continue
+			}
+			missing[binary] = true
+			missingBinaries = append(missingBinaries, binary)
+		}
+	}
+	return orphanBinaries, missingBinaries
+}
+
 // vim: foldmethod=marker
@@ -22,6 +22,10 @@ package control_test
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
 	"strings"
 	"testing"
 
@@ -191,4 +195,462 @@ SHA256: fa53e4f50349c5c9b564b8dc1da86c503b0baf56ab95a4ef6e204b6f77bfe70c
 	assert(t, ddmsDepends.GetAllPossibilities()[0].Version.Number == "22.2+git20130830~92d25d6-1")
 }
 
+func TestBinaryIndexValidateDescriptionMD5(t *testing.T) {
+	// Test Binary Index {{{
+	stanza := `Package: hello
+Version: 2.10-1
+Architecture: amd64
+Description: example package based on GNU hello
+ The GNU hello program produces a familiar, friendly greeting.  It
+ allows non-programmers to use a classic computer science tool which
+ would otherwise be unavailable to them.
+ .
+ Seriously, though: this is an example of how to do a Debian package.
+Description-md5: %s
+`
+	// }}}
+	description := `example package based on GNU hello
+ The GNU hello program produces a familiar, friendly greeting.  It
+ allows non-programmers to use a classic computer science tool which
+ would otherwise be unavailable to them.
+ .
+ Seriously, though: this is an example of how to do a Debian package.
+`
+	sum := md5.Sum([]byte(description))
+
+	packages, err := control.ParseBinaryIndex(bufio.NewReader(strings.NewReader(
+		fmt.Sprintf(stanza, hex.EncodeToString(sum[:])),
+	)))
+	isok(t, err)
+	ok, err := packages[0].Validate()
+	isok(t, err)
+	assert(t, ok)
+
+	packages, err = control.ParseBinaryIndex(bufio.NewReader(strings.NewReader(
+		fmt.Sprintf(stanza, "0123456789abcdef0123456789abcdef"),
+	)))
+	isok(t, err)
+	ok, err = packages[0].Validate()
+	notok(t, err)
+	assert(t, !ok)
+
+	/* Only the synopsis; nothing to check against */
+	packages, err = control.ParseBinaryIndex(bufio.NewReader(strings.NewReader(`Package: hello
+Description: example package based on GNU hello
+Description-md5: 0123456789abcdef0123456789abcdef
+`)))
+	isok(t, err)
+	ok, err = packages[0].Validate()
+	isok(t, err)
+	assert(t, ok)
+}
+
+func TestPartitionByArch(t *testing.T) {
+	// Test Binary Index {{{
+	data := `Package: foo
+Version: 1.0-1
+Architecture: amd64
+
+Package: foo-doc
+Version: 1.0-1
+Architecture: all
+
+Package: foo
+Version: 1.0-1
+Architecture: i386
+
+Package: bar
+Version: 2.0-1
+Architecture: amd64
+`
+	// }}}
+	arches, err := control.PartitionByArch(strings.NewReader(data))
+	isok(t, err)
+	assert(t, len(arches) == 3)
+	assert(t, len(arches["amd64"]) == 2)
+	assert(t, arches["amd64"][0].Package == "foo")
+	assert(t, arches["amd64"][1].Package == "bar")
+	assert(t, len(arches["i386"]) == 1)
+	assert(t, len(arches["all"]) == 1)
+	assert(t, arches["all"][0].Package == "foo-doc")
+
+	merged := control.DuplicateArchAll(arches)
+	assert(t, len(merged) == 2)
+	assert(t, len(merged["amd64"]) == 3)
+	assert(t, merged["amd64"][2].Package == "foo-doc")
+	assert(t, len(merged["i386"]) == 2)
+	assert(t, merged["i386"][1].Package == "foo-doc")
+	/* The input map isn't modified */
+	assert(t, len(arches["amd64"]) == 2)
+
+	/* Nothing to put the arch:all packages into */
+	arches, err = control.PartitionByArch(strings.NewReader(`Package: foo-doc
+Architecture: all
+`))
+	isok(t, err)
+	merged = control.DuplicateArchAll(arches)
+	assert(t, len(merged["all"]) == 1)
+
+	/* No Architecture at all */
+	_, err = control.PartitionByArch(strings.NewReader("Package: foo\n\nPackage: bar\nVersion: 1.0\n"))
+	assert(t, err != nil && err.Error() == "Package 'foo' has no Architecture (in paragraph 0)")
+}
+
+func TestPackagesIndexLookup(t *testing.T) {
+	// Test Binary Index {{{
+	data := `Package: foo
+Version: 1.0-1
+Architecture: amd64
+Description: Foo
+ It's foo.
+
+Package: bar
+Version: 2.0-1
+Architecture: all
+
+
+Package: baz
+Version: 3.0-1
+Architecture: amd64
+Depends: foo (>= 1.0), bar
+`
+	// }}}
+	idx, err := control.NewPackagesIndex(strings.NewReader(data), nil)
+	isok(t, err)
+	assert(t, len(idx.Offsets) == 3)
+
+	for _, tc := range []struct {
+		name    string
+		version string
+	}{
+		{"baz", "3.0-1"},
+		{"foo", "1.0-1"},
+		{"bar", "2.0-1"},
+		{"baz", "3.0-1"},
+	} {
+		pkg, err := idx.Lookup(tc.name)
+		isok(t, err)
+		assert(t, pkg.Package == tc.name)
+		assert(t, pkg.Version.String() == tc.version)
+	}
+
+	pkg, err := idx.Lookup("baz")
+	isok(t, err)
+	assert(t, pkg.GetDepends().Relations[1].Possibilities[0].Name == "bar")
+
+	_, err = idx.Lookup("nope")
+	notok(t, err)
+
+	/* A stale index doesn't hand back the wrong package */
+	idx, err = control.NewPackagesIndex(strings.NewReader(data), map[string]int64{
+		"foo": idx.Offsets["bar"],
+	})
+	isok(t, err)
+	_, err = idx.Lookup("foo")
+	notok(t, err)
+}
+
+func TestBinaryIndexOrigin(t *testing.T) {
+	data := `Package: foo
+Version: 1.0-1
+Architecture: amd64
+Origin: Example
+`
+	indices, err := control.ParseBinaryIndex(bufio.NewReader(strings.NewReader(data)))
+	isok(t, err)
+	assert(t, len(indices) == 1)
+	assert(t, indices[0].Origin == "Example")
+
+	out := bytes.Buffer{}
+	isok(t, control.Marshal(&out, indices[0]))
+	again := control.BinaryIndex{}
+	isok(t, control.Unmarshal(&again, &out))
+	assert(t, again.Origin == "Example")
+	assert(t, again.Values["Origin"] == "Example")
+}
+
+func TestDiffPackages(t *testing.T) {
+	// Test Binary Indices {{{
+	old := `Package: foo
+Version: 1.0-1
+Architecture: amd64
+
+Package: foo
+Version: 1.0-1
+Architecture: i386
+
+Package: bar
+Version: 2.0-1
+Architecture: all
+
+Package: baz
+Version: 3.0-1
+Architecture: amd64
+
+Package: gone
+Version: 1.0
+Architecture: amd64
+`
+	new := `Package: foo
+Version: 1.0-2
+Architecture: amd64
+
+Package: foo
+Version: 1.0-1
+Architecture: i386
+
+Package: bar
+Version: 1:1.0-1
+Architecture: all
+
+Package: baz
+Version: 2.9-1
+Architecture: amd64
+
+Package: baz
+Version: 3.0-1
+Architecture: i386
+`
+	// }}}
+	added, removed, changed, err := control.DiffPackages(strings.NewReader(old), strings.NewReader(new))
+	isok(t, err)
+
+	assert(t, len(added) == 1)
+	assert(t, added[0].Package == "baz" && added[0].Architecture.CPU == "i386")
+
+	assert(t, len(removed) == 1)
+	assert(t, removed[0].Package == "gone")
+
+	/* An upgrade, an epoch bump, and a downgrade */
+	assert(t, len(changed) == 3)
+	assert(t, changed[0].Package == "foo" && changed[0].Version.Revision == "2")
+	assert(t, changed[1].Package == "bar" && changed[1].Version.Epoch == 1)
+	assert(t, changed[2].Package == "baz" && changed[2].Version.Version == "2.9")
+
+	added, removed, changed, err = control.DiffPackages(strings.NewReader(old), strings.NewReader(old))
+	isok(t, err)
+	assert(t, len(added) == 0 && len(removed) == 0 && len(changed) == 0)
+
+	/* Only the first foo:amd64 counts, so nothing changed */
+	added, removed, changed, err = control.DiffPackages(
+		strings.NewReader(old+"\nPackage: foo\nVersion: 0.9-1\nArchitecture: amd64\n"),
+		strings.NewReader(old),
+	)
+	isok(t, err)
+	assert(t, len(added) == 0 && len(removed) == 0 && len(changed) == 0)
+}
+
+func TestBinaryIndexBreaks(t *testing.T) {
+	// Test Binary Index {{{
+	reader := bufio.NewReader(strings.NewReader(`Package: foo
+Version: 2.0-1
+Architecture: amd64
+Breaks: bar (<< 1.5)
+Conflicts: baz
+
+Package: bar
+Version: 1.5~rc1-1
+Architecture: amd64
+
+Package: bar
+Version: 1.5-1
+Architecture: amd64
+
+Package: baz
+Version: 0.1-1
+Architecture: amd64
+`))
+	// }}}
+	packages, err := control.ParseBinaryIndex(reader)
+	isok(t, err)
+	assert(t, len(packages) == 4)
+
+	foo := packages[0]
+	assert(t, foo.Breaks(packages[1]))
+	assert(t, !foo.Breaks(packages[2]))
+	assert(t, !foo.Breaks(packages[3]))
+
+	assert(t, !foo.Conflicts(packages[1]))
+	assert(t, foo.Conflicts(packages[3]))
+
+	/* Nothing is Broken the other way around */
+	assert(t, !packages[1].Breaks(foo))
+}
+
+func TestBinaryIndexConflictsSelfProvides(t *testing.T) {
+	// Test Binary Index {{{
+	reader := bufio.NewReader(strings.NewReader(`Package: postfix
+Version: 3.7.6-1
+Architecture: amd64
+Provides: mail-transport-agent
+Conflicts: mail-transport-agent
+
+Package: exim4-daemon-light
+Version: 4.96-15
+Architecture: amd64
+Provides: mail-transport-agent
+Conflicts: mail-transport-agent
+
+Package: mutt
+Version: 2.2.9-1
+Architecture: amd64
+`))
+	// }}}
+	packages, err := control.ParseBinaryIndex(reader)
+	isok(t, err)
+	assert(t, len(packages) == 3)
+
+	postfix, exim, mutt := packages[0], packages[1], packages[2]
+
+	/* Providing and Conflicting the same name isn't a conflict with yourself */
+	assert(t, !postfix.Conflicts(postfix))
+	assert(t, !exim.Conflicts(exim))
+
+	/* But it is with anything else that Provides it */
+	assert(t, postfix.Conflicts(exim))
+	assert(t, exim.Conflicts(postfix))
+
+	assert(t, !postfix.Conflicts(mutt))
+}
+
+func TestBuildReverseBuildDepends(t *testing.T) {
+	// Test Sources Index {{{
+	reader := bufio.NewReader(strings.NewReader(`Package: hello
+Binary: hello
+Version: 2.10-3
+Build-Depends: debhelper-compat (= 13), libfoo-dev [amd64] | libbar-dev
+Build-Depends-Indep: texinfo
+
+Package: fbautostart
+Binary: fbautostart
+Version: 2.718281828-1
+Build-Depends: debhelper-compat (= 13), libfoo-dev
+Build-Depends-Arch: libfoo-dev (>= 1.0)
+
+Package: nothing
+Binary: nothing
+Version: 1.0-1
+`))
+	// }}}
+	sources, err := control.ParseSourceIndex(reader)
+	isok(t, err)
+	assert(t, len(sources) == 3)
+
+	rdeps := control.BuildReverseBuildDepends(sources)
+	assert(t, len(rdeps) == 4)
+	assert(t, strings.Join(rdeps["debhelper-compat"], " ") == "hello fbautostart")
+	assert(t, strings.Join(rdeps["libfoo-dev"], " ") == "hello fbautostart")
+	assert(t, strings.Join(rdeps["libbar-dev"], " ") == "hello")
+	assert(t, strings.Join(rdeps["texinfo"], " ") == "hello")
+	assert(t, len(rdeps["hello"]) == 0)
+}
+
+func TestCrossCheck(t *testing.T) {
+	// Test Sources Index {{{
+	sources, err := control.ParseSourceIndex(bufio.NewReader(strings.NewReader(`Package: hello
+Binary: hello, hello-doc
+Version: 2.10-3
+
+Package: fbautostart
+Binary: fbautostart
+Version: 2.718281828-1
+Package-List:
+ fbautostart deb misc optional arch=any
+ fbautostart-udeb udeb debian-installer optional arch=any
+`)))
+	// }}}
+	isok(t, err)
+
+	// Test Packages Index {{{
+	packages, err := control.ParseBinaryIndex(bufio.NewReader(strings.NewReader(`Package: hello
+Version: 2.10-3
+Architecture: amd64
+
+Package: hello-doc
+Source: hello (2.10-3)
+Version: 2.10-3
+Architecture: all
+
+Package: fbautostart
+Version: 2.718281828-1
+Architecture: amd64
+
+Package: libfoo1
+Source: foo
+Version: 1.0-1
+Architecture: amd64
+
+Package: hello-extra
+Source: hello
+Version: 2.10-3
+Architecture: amd64
+`)))
+	// }}}
+	isok(t, err)
+
+	orphans, missing := control.CrossCheck(sources, packages)
+	assert(t, strings.Join(orphans, " ") == "libfoo1 hello-extra")
+	assert(t, strings.Join(missing, " ") == "fbautostart-udeb")
+
+	orphans, missing = control.CrossCheck(sources, nil)
+	assert(t, len(orphans) == 0)
+	assert(t, strings.Join(missing, " ") == "hello hello-doc fbautostart fbautostart-udeb")
+}
+
+func TestPackageNames(t *testing.T) {
+	// Test Packages {{{
+	input := `Package: foo
+Version: 1.0-1
+Description: foo
+ Package: not-a-package
+
+Version: 2.0-1
+Package: bar
+Description: bar
+ .
+ ` + strings.Repeat("x", 10000) + `Package: nope
+
+Package: baz`
+	// }}}
+	names, err := control.PackageNames(strings.NewReader(input))
+	isok(t, err)
+	assert(t, strings.Join(names, " ") == "foo bar baz")
+
+	names, err = control.PackageNames(strings.NewReader(""))
+	isok(t, err)
+	assert(t, len(names) == 0)
+}
+
+func BenchmarkPackageNames(b *testing.B) {
+	index := largeIndex()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		names, err := control.PackageNames(strings.NewReader(index))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(names) != 2000 {
+			b.Fatalf("Got %d names", len(names))
+		}
+	}
+}
+
+func BenchmarkPackageNamesDecode(b *testing.B) {
+	index := largeIndex()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		packages := []control.BinaryIndex{}
+		if err := control.Unmarshal(&packages, strings.NewReader(index)); err != nil {
+			b.Fatal(err)
+		}
+		names := []string{}
+		for _, pkg := range packages {
+			names = append(names, pkg.Package)
+		}
+		if len(names) != 2000 {
+			b.Fatalf("Got %d names", len(names))
+		}
+	}
+}
+
 // vim: foldmethod=marker
@@ -0,0 +1,138 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// An Override is a single line of an apt-ftparchive(1) override file,
+// which remaps the Priority and Section of a binary package, as in:
+//
+//	hello optional devel
+//	fbautostart optional x11 Jane Doe <jroe@example.com> => John Doe <jdoe@example.com>
+//
+// The (optional) maintainer field either gives a new Maintainer outright,
+// or has the form "old [// old]... => new", in which case the Maintainer
+// is only replaced if it's one of the old ones.
+type Override struct {
+	Package  string
+	Priority string
+	Section  string
+
+	Maintainer     string
+	OldMaintainers []string
+}
+
+// Overrides maps package names to their Override.
+type Overrides map[string]Override
+
+// Given a path on the filesystem, parse the override file off the disk.
+func ParseOverridesFile(path string) (Overrides, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseOverrides(f)
+}
+
+// Parse an apt-ftparchive override file. Blank lines and # comments are
+// skipped.
+func ParseOverrides(reader io.Reader) (Overrides, error) {
+	ret := Overrides{}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("Malformed override line: '%s'", scanner.Text())
+		}
+
+		override := Override{
+			Package:  fields[0],
+			Priority: fields[1],
+			Section:  fields[2],
+		}
+		if maintainer := strings.Join(fields[3:], " "); maintainer != "" {
+			if els := strings.SplitN(maintainer, "=>", 2); len(els) == 2 {
+				for _, old := range strings.Split(els[0], "//") {
+					override.OldMaintainers = append(override.OldMaintainers, strings.TrimSpace(old))
+				}
+				maintainer = strings.TrimSpace(els[1])
+			}
+			override.Maintainer = maintainer
+		}
+		ret[override.Package] = override
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (index *BinaryIndex) setOverride(key, value string, field *string) {
+	*field = value
+	if index.Values != nil {
+		index.Set(key, value)
+	}
+}
+
+// Apply the Override for this package (if there is one) to the BinaryIndex,
+// setting its Section, Priority and, where the Override says so, its
+// Maintainer. The raw Paragraph values are updated to match. Returns false
+// if there's no Override for the package.
+func (o Overrides) Apply(index *BinaryIndex) bool {
+	override, ok := o[index.Package]
+	if !ok {
+		return false
+	}
+
+	index.setOverride("Priority", override.Priority, &index.Priority)
+	index.setOverride("Section", override.Section, &index.Section)
+
+	if override.Maintainer == "" {
+		return true
+	}
+	if len(override.OldMaintainers) == 0 {
+		index.setOverride("Maintainer", override.Maintainer, &index.Maintainer)
+		return true
+	}
+	for _, old := range override.OldMaintainers {
+		if old == index.Maintainer {
+			index.setOverride("Maintainer", override.Maintainer, &index.Maintainer)
+			break
+		}
+	}
+	return true
+}
+
+// vim: foldmethod=marker
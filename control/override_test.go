@@ -0,0 +1,113 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"pault.ag/go/debian/control"
+)
+
+/*
+ *
+ */
+
+// Test Override file {{{
+const testOverrides = `# Overrides for main
+hello optional devel
+fbautostart extra x11 Jane Roe <jroe@example.com> // J. Roe <jroe@example.com> => John Doe <jdoe@example.com>
+bar important admin   Someone Else <else@example.com>
+`
+
+// }}}
+
+func TestParseOverrides(t *testing.T) {
+	overrides, err := control.ParseOverrides(strings.NewReader(testOverrides))
+	isok(t, err)
+	assert(t, len(overrides) == 3)
+
+	assert(t, overrides["hello"].Priority == "optional")
+	assert(t, overrides["hello"].Section == "devel")
+	assert(t, overrides["hello"].Maintainer == "")
+
+	fbautostart := overrides["fbautostart"]
+	assert(t, fbautostart.Maintainer == "John Doe <jdoe@example.com>")
+	assert(t, len(fbautostart.OldMaintainers) == 2)
+	assert(t, fbautostart.OldMaintainers[1] == "J. Roe <jroe@example.com>")
+
+	assert(t, overrides["bar"].Maintainer == "Someone Else <else@example.com>")
+
+	_, err = control.ParseOverrides(strings.NewReader("hello optional\n"))
+	notok(t, err)
+}
+
+func TestApplyOverrides(t *testing.T) {
+	// Test Binary Index {{{
+	reader := bufio.NewReader(strings.NewReader(`Package: hello
+Version: 2.10-3
+Maintainer: Santiago Vila <sanvila@debian.org>
+Priority: extra
+Section: misc
+
+Package: fbautostart
+Version: 2.718281828-1
+Maintainer: Jane Roe <jroe@example.com>
+
+Package: bash
+Version: 5.2-1
+Section: shells
+`))
+	// }}}
+	packages, err := control.ParseBinaryIndex(reader)
+	isok(t, err)
+	overrides, err := control.ParseOverrides(strings.NewReader(testOverrides))
+	isok(t, err)
+
+	hello := packages[0]
+	assert(t, overrides.Apply(&hello))
+	assert(t, hello.Priority == "optional")
+	assert(t, hello.Section == "devel")
+	assert(t, hello.Maintainer == "Santiago Vila <sanvila@debian.org>")
+
+	out := bytes.Buffer{}
+	_, err = hello.Paragraph.WriteTo(&out)
+	isok(t, err)
+	assert(t, out.String() == `Package: hello
+Version: 2.10-3
+Maintainer: Santiago Vila <sanvila@debian.org>
+Priority: optional
+Section: devel
+`)
+
+	fbautostart := packages[1]
+	assert(t, overrides.Apply(&fbautostart))
+	assert(t, fbautostart.Maintainer == "John Doe <jdoe@example.com>")
+	assert(t, fbautostart.Values["Section"] == "x11")
+
+	bash := packages[2]
+	assert(t, !overrides.Apply(&bash))
+	assert(t, bash.Section == "shells")
+}
+
+// vim: foldmethod=marker
@@ -22,6 +22,7 @@ package control
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"strings"
@@ -29,22 +30,6 @@ import (
 	"golang.org/x/crypto/openpgp/clearsign"
 )
 
-// func encodeValue(value string) string {
-// 	ret := ""
-//
-// 	lines := strings.Split(value, "\n")
-// 	for _, line := range lines {
-// 		line = strings.Trim(line, " \t\r\n")
-// 		if line == "" {
-// 			line = "."
-// 		}
-// 		line = " " + line
-// 		ret = ret + line + "\n"
-// 	}
-//
-// 	return ret
-// }
-
 // A Paragraph is a block of RFC2822-like key value pairs. This struct contains
 // two methods to fetch values, a Map called Values, and a Slice called
 // Order, which maintains the ordering as defined in the RFC2822-like block
@@ -53,41 +38,193 @@ type Paragraph struct {
 	Order  []string
 }
 
-// func (para Paragraph) String() string {
-// 	ret := ""
-//
-// 	for _, key := range para.Order {
-// 		value := encodeValue(para.Values[key])
-// 		ret = ret + fmt.Sprintf("%s:%s", key, value)
-// 	}
+// Set the value of key in the Paragraph. Keys that are not already in the
+// Paragraph are added to the end of .Order.
+func (para *Paragraph) Set(key, value string) {
+	if para.Values == nil {
+		para.Values = map[string]string{}
+	}
+	if _, ok := para.Values[key]; !ok {
+		para.Order = append(para.Order, key)
+	}
+	para.Values[key] = value
+}
+
+// Return the keys of the Paragraph, in order. This is a copy of .Order, so
+// it's fine to change it without messing up the Paragraph.
+func (para Paragraph) Keys() []string {
+	return append([]string{}, para.Order...)
+}
+
+// Return a deep copy of the Paragraph, with its own .Values and .Order, so
+// that changes to the copy (say, with Set) don't show up in the original.
+func (para Paragraph) Clone() Paragraph {
+	values := make(map[string]string, len(para.Values))
+	for key, value := range para.Values {
+		values[key] = value
+	}
+	return Paragraph{
+		Values: values,
+		Order:  append([]string{}, para.Order...),
+	}
+}
+
+// Call fn with each key and value of the Paragraph, in order, until fn
+// returns false.
+func (para Paragraph) Range(fn func(key, value string) bool) {
+	for _, key := range para.Order {
+		if !fn(key, para.Values[key]) {
+			return
+		}
+	}
+}
+
+// Write the Paragraph out to the io.Writer as an RFC2822-like block, with
+// keys in the order given by .Order. Values that span more than one line
+// are written out using continuation lines, each starting with a single
+// space. If the first line of a multi-line value is empty, the value starts
+// on the line following the key (as is done for Files and friends). Empty
+// lines past the first are written as " .", as in a Description, since a
+// line with nothing but whitespace on it isn't allowed in a field. An
+// empty value is written as the key and colon alone, with no space after.
 //
-// 	return ret
-// }
+// Values parsed by ParseParagraph keep any indentation past the single
+// space that marks a continuation line, so a Paragraph that's parsed and
+// then written back out comes out the same as it went in.
+func (para Paragraph) WriteTo(out io.Writer) (int64, error) {
+	var written int64
+	for _, key := range para.Order {
+		n, err := io.WriteString(out, key+":"+encodeValue(para.Values[key]))
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func encodeValue(value string) string {
+	lines := strings.Split(value, "\n")
+
+	ret := ""
+	if lines[0] != "" {
+		ret = " " + lines[0]
+	}
+	ret += "\n"
+
+	for _, line := range lines[1:] {
+		if strings.TrimSpace(line) == "" {
+			line = "."
+		}
+		ret += " " + line + "\n"
+	}
+	return ret
+}
 
 func ParseOpenPGPParagraph(reader *bufio.Reader) (ret *Paragraph, ohshit error) {
+	parser := paragraphParser{reader: reader}
+	return parser.parseOpenPGP()
+}
+
+// Given a bufio.Reader, go through and return a Paragraph.
+func ParseParagraph(reader *bufio.Reader) (ret *Paragraph, ohshit error) {
+	parser := paragraphParser{reader: reader}
+	return parser.parse()
+}
+
+// The paragraphParser keeps track of how far into the stream we've read,
+// so that the Decoder can tell where each Paragraph started.
+type paragraphParser struct {
+	reader *bufio.Reader
+
+	/* Bytes consumed from reader so far */
+	offset int64
+	/* Offset of the first line of the last Paragraph parsed */
+	start int64
+	/* Lines read so far, and the (1-based) line the last Paragraph
+	 * parsed started on */
+	line      int
+	startLine int
+	/* Blank lines read since the end of the last Paragraph */
+	trailing int
+	/* Whether the last line read didn't end in a newline */
+	unterminated bool
+	/* If not 0, the longest a line or field value may be, in bytes */
+	maxLength int
+	/* Whether full-line # comments are dropped, which is only allowed
+	 * in debian/control */
+	comments bool
+}
+
+func (p *paragraphParser) readLine() (string, error) {
+	if p.maxLength == 0 {
+		line, err := p.reader.ReadString('\n')
+		p.offset += int64(len(line))
+		if line != "" {
+			p.line++
+			p.unterminated = line[len(line)-1] != '\n'
+		}
+		return line, err
+	}
+
+	/* Read in chunks, so we stop before buffering up an endless line */
+	line := []byte{}
+	for {
+		chunk, err := p.reader.ReadSlice('\n')
+		line = append(line, chunk...)
+		p.offset += int64(len(chunk))
+		if len(line) > p.maxLength+1 {
+			return "", fmt.Errorf(
+				"pault.ag/go/debian/control: line %d: longer than %d bytes",
+				p.line+1, p.maxLength,
+			)
+		}
+		if err != bufio.ErrBufferFull {
+			if len(line) != 0 {
+				p.line++
+				p.unterminated = line[len(line)-1] != '\n'
+			}
+			return string(line), err
+		}
+	}
+}
+
+func (p *paragraphParser) parseOpenPGP() (ret *Paragraph, ohshit error) {
 	els := ""
 	for {
-		line, err := reader.ReadString('\n')
+		line, err := p.readLine()
+		els = els + line
 		if err == io.EOF {
+			/* That includes the armor footer, even without a newline */
 			break
+		} else if err != nil {
+			return nil, err
 		}
-		els = els + line
 	}
+	/* clearsign skips past the armor headers (such as "Hash: SHA256")
+	 * and the blank line after them, so the Plaintext starts with the
+	 * first real field. Unlike block.Bytes (which is what gets signed),
+	 * the Plaintext has \n line endings, and ends in a newline. */
 	block, _ := clearsign.Decode([]byte(els))
-	/**
-	 * XXX: With the block, we need to validate everything.
-	 *
-	 * We need to hit openpgp.CheckDetachedSignature with block and
-	 * a keyring. For now, it'll ignore all signature checking entirely.
-	 */
-	return ParseParagraph(bufio.NewReader(strings.NewReader(string(block.Bytes))))
+	if block == nil {
+		return nil, fmt.Errorf("Malformed OpenPGP clearsigned message")
+	}
+	/* No signature checking here; that's what DecodeClearsigned and
+	 * VerifyClearsigned are for. */
+	inner := paragraphParser{
+		reader:    bufio.NewReader(bytes.NewReader(block.Plaintext)),
+		maxLength: p.maxLength,
+		comments:  p.comments,
+	}
+	return inner.parse()
 }
 
-// Given a bufio.Reader, go through and return a Paragraph.
-func ParseParagraph(reader *bufio.Reader) (ret *Paragraph, ohshit error) {
-	line, _ := reader.Peek(15)
+func (p *paragraphParser) parse() (ret *Paragraph, ohshit error) {
+	line, _ := p.reader.Peek(15)
 	if string(line) == "-----BEGIN PGP " {
-		return ParseOpenPGPParagraph(reader)
+		p.start = p.offset
+		p.startLine = p.line + 1
+		return p.parseOpenPGP()
 	}
 
 	ret = &Paragraph{
@@ -100,20 +237,47 @@ func ParseParagraph(reader *bufio.Reader) (ret *Paragraph, ohshit error) {
 	var noop = " \n\r\t"
 
 	for {
-		line, err := reader.ReadString('\n')
-		if err == io.EOF {
+		start := p.offset
+		line, err := p.readLine()
+		/* A last line without a newline comes back along with the
+		 * io.EOF; it's only over once there's nothing left at all. */
+		if err == io.EOF && line == "" {
 			if len(ret.Order) == 0 {
 				return nil, nil
 			}
 			return ret, nil
+		} else if err != nil && err != io.EOF {
+			return nil, err
 		}
 		if line == "\n" {
+			p.trailing++
+			if len(ret.Order) == 0 {
+				/* Extra blank lines between Paragraphs */
+				continue
+			}
 			break
 		}
+		p.trailing = 0
 
-		if line[0] == ' ' {
+		if p.comments && line[0] == '#' {
+			/* A comment, as found in debian/control, which may be in
+			 * amongst the continuation lines of a folded field too. No
+			 * key may start with a #, so these are just dropped. */
+			continue
+		}
+
+		if line[0] == ' ' || line[0] == '\t' {
+			/* Only the one space that marks this as a continuation line
+			 * goes; anything past that (such as the extra indent of a
+			 * verbatim line in a Description) is part of the value. */
 			line = line[1:]
-			ret.Values[key] += "\n" + strings.Trim(line, noop)
+			ret.Values[key] += "\n" + strings.TrimRight(line, noop)
+			if p.maxLength != 0 && len(ret.Values[key]) > p.maxLength {
+				return nil, fmt.Errorf(
+					"pault.ag/go/debian/control: line %d: value of %s is longer than %d bytes",
+					p.line, key, p.maxLength,
+				)
+			}
 			continue
 		}
 
@@ -121,6 +285,11 @@ func ParseParagraph(reader *bufio.Reader) (ret *Paragraph, ohshit error) {
 
 		switch len(els) {
 		case 2:
+			if len(ret.Order) == 0 {
+				p.start = start
+				p.startLine = p.line
+			}
+
 			key = strings.Trim(els[0], noop)
 			value = strings.Trim(els[1], noop)
 
@@ -128,7 +297,10 @@ func ParseParagraph(reader *bufio.Reader) (ret *Paragraph, ohshit error) {
 			ret.Order = append(ret.Order, key)
 			continue
 		default:
-			return nil, fmt.Errorf("Line %q is not 'key: val'", line)
+			return nil, fmt.Errorf(
+				"pault.ag/go/debian/control: line %d: malformed field, no colon found: %q",
+				p.line, strings.TrimRight(line, "\n"),
+			)
 		}
 	}
 
@@ -22,10 +22,14 @@ package control_test
 
 import (
 	"bufio"
+	"bytes"
+	"io"
 	"log"
 	"strings"
 	"testing"
 
+	"golang.org/x/crypto/openpgp/clearsign"
+
 	"pault.ag/go/debian/control"
 )
 
@@ -69,7 +73,13 @@ func TestBasicControlParse(t *testing.T) {
 	assert(t, len(deb822.Order) == len(deb822.Values))
 	assert(t, deb822.Values["Foo"] == "bar")
 
+	/* No newline at the end of the file doesn't lose the last field */
 	reader = bufio.NewReader(strings.NewReader(`Foo: bar`))
+	deb822, err = control.ParseParagraph(reader)
+	isok(t, err)
+	assert(t, deb822 != nil)
+	assert(t, deb822.Values["Foo"] == "bar")
+
 	deb822, err = control.ParseParagraph(reader)
 	assert(t, deb822 == nil)
 	assert(t, err == nil)
@@ -145,32 +155,166 @@ But-not: me
 	assert(t, deb822.Values["But-not"] == "me")
 }
 
-// func TestSeralize(t *testing.T) {
-// 	// Test Paragraph {{{
-// 	para := `Foo: bar
-// Bar-Baz: fnord:and:this:here
-//  and:not
-//  second:here
-//  line
-//  here
-// Hello: world
-//
-// But-not: me
-// `
-// 	// }}}
-// 	reader := bufio.NewReader(strings.NewReader(para))
-// 	deb822, err := control.ParseParagraph(reader)
-// 	isok(t, err)
-//
-// 	out := deb822.String()
-// 	assert(t, out == `Foo: bar
-// Bar-Baz: fnord:and:this:here
-//  and:not
-//  second:here
-//  line
-//  here
-// Hello: world
-// `)
-// }
+func TestSerialize(t *testing.T) {
+	// Test Paragraph {{{
+	para := `Foo: bar
+Bar-Baz: fnord:and:this:here
+ and:not
+ second:here
+ line
+ here
+Hello: world
+
+But-not: me
+`
+	// }}}
+	reader := bufio.NewReader(strings.NewReader(para))
+	deb822, err := control.ParseParagraph(reader)
+	isok(t, err)
+
+	out := bytes.Buffer{}
+	_, err = deb822.WriteTo(&out)
+	isok(t, err)
+	assert(t, out.String() == `Foo: bar
+Bar-Baz: fnord:and:this:here
+ and:not
+ second:here
+ line
+ here
+Hello: world
+`)
+}
+
+func TestDescriptionRoundTrip(t *testing.T) {
+	// Test Paragraph {{{
+	input := `Package: python3-fbautostart
+Description: lightweight autostarter for fluxbox
+ fbautostart is a small program which starts programs listed in
+ .desktop files, as outlined in the freedesktop.org spec.
+ .
+ It can be run like this:
+ .
+   $ fbautostart --mode=FLUXBOX
+     (for the verbatim folks)
+ .
+ Some more text.
+Files:
+ d41d8cd98f00b204e9800998ecf8427e 0 fbautostart_2.718281828.orig.tar.gz
+`
+	// }}}
+	para, err := control.ParseParagraph(bufio.NewReader(strings.NewReader(input)))
+	isok(t, err)
+	assert(t, strings.Contains(para.Values["Description"], "\n  $ fbautostart --mode=FLUXBOX\n    (for"))
+
+	out := bytes.Buffer{}
+	_, err = para.WriteTo(&out)
+	isok(t, err)
+	assert(t, out.String() == input)
+
+	/* Through a struct, too */
+	pkg := control.BinaryIndex{}
+	isok(t, control.Unmarshal(&pkg, strings.NewReader(input)))
+	encoded, err := control.ConvertToParagraph(pkg)
+	isok(t, err)
+	out.Reset()
+	_, err = encoded.WriteTo(&out)
+	isok(t, err)
+	assert(t, strings.Contains(out.String(), input[strings.Index(input, "Description:"):strings.Index(input, "Files:")]))
+
+	/* Blank lines set by hand come out as " ." */
+	para.Set("Description", "foo\nbar\n\nbaz")
+	out.Reset()
+	_, err = para.WriteTo(&out)
+	isok(t, err)
+	assert(t, strings.Contains(out.String(), "Description: foo\n bar\n .\n baz\n"))
+}
+
+func TestParagraphKeys(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader(`Source: fbautostart
+Version: 2.718281828-1
+Maintainer: Paul Tagliamonte <paultag@ubuntu.com>
+Build-Depends: debhelper (>= 9)
+`))
+	deb822, err := control.ParseParagraph(reader)
+	isok(t, err)
+
+	keys := deb822.Keys()
+	assert(t, strings.Join(keys, " ") == "Source Version Maintainer Build-Depends")
+
+	/* Messing with the copy leaves the Paragraph alone */
+	keys[0] = "Package"
+	keys = append(keys[:1], keys[2:]...)
+	assert(t, deb822.Order[0] == "Source")
+	assert(t, deb822.Order[2] == "Maintainer")
+	assert(t, len(deb822.Keys()) == 4)
+
+	seen := []string{}
+	deb822.Range(func(key, value string) bool {
+		seen = append(seen, key+"="+value)
+		return key != "Version"
+	})
+	assert(t, len(seen) == 2)
+	assert(t, seen[0] == "Source=fbautostart")
+	assert(t, seen[1] == "Version=2.718281828-1")
+}
+
+func TestParagraphClone(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader(`Source: fbautostart
+Version: 2.718281828-1
+`))
+	deb822, err := control.ParseParagraph(reader)
+	isok(t, err)
+
+	clone := deb822.Clone()
+	clone.Set("Version", "3.14-1")
+	clone.Set("Maintainer", "Paul Tagliamonte <paultag@debian.org>")
+	clone.Order[0] = "Package"
+
+	assert(t, len(deb822.Order) == 2)
+	assert(t, deb822.Order[0] == "Source")
+	assert(t, deb822.Values["Version"] == "2.718281828-1")
+	_, ok := deb822.Values["Maintainer"]
+	assert(t, !ok)
+
+	assert(t, len(clone.Order) == 3)
+	assert(t, clone.Values["Version"] == "3.14-1")
+	assert(t, clone.Values["Source"] == "fbautostart")
+
+	/* A zero Paragraph clones into one that can be Set */
+	empty := control.Paragraph{}.Clone()
+	empty.Set("Source", "fbautostart")
+	assert(t, empty.Values["Source"] == "fbautostart")
+}
+
+func TestOpenPGPArmorHeadersParse(t *testing.T) {
+	entity := newTestEntity(t, "archive")
+	out := bytes.Buffer{}
+	plaintext, err := clearsign.Encode(&out, entity.PrivateKey, nil)
+	isok(t, err)
+	io.WriteString(plaintext, "Origin: Debian\nLabel: Debian\nSuite: unstable\n")
+	isok(t, plaintext.Close())
+	signed := out.String()
+	assert(t, strings.Contains(signed, "\nHash: SHA256\n\nOrigin: Debian\n"))
+
+	for _, input := range []string{
+		signed,
+		/* More than one armor header */
+		strings.Replace(signed, "Hash: SHA256\n", "Hash: SHA256\nHash: SHA512\n", 1),
+	} {
+		para, err := control.ParseParagraph(bufio.NewReader(strings.NewReader(input)))
+		isok(t, err)
+		assert(t, para.Order[0] == "Origin")
+		assert(t, para.Values["Origin"] == "Debian")
+		assert(t, para.Values["Suite"] == "unstable")
+		_, ok := para.Values["Hash"]
+		assert(t, !ok)
+	}
+
+	/* No signature at all isn't something we can make sense of */
+	_, err = control.ParseParagraph(bufio.NewReader(strings.NewReader(
+		"-----BEGIN PGP SIGNED MESSAGE-----\nHash: SHA256\n\nOrigin: Debian\n",
+	)))
+	notok(t, err)
+}
 
 // vim: foldmethod=marker
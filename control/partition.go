@@ -0,0 +1,103 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control
+
+import (
+	"pault.ag/go/debian/dependency"
+	"pault.ag/go/debian/version"
+)
+
+// An index of what a set of BinaryIndex entries can satisfy, by name.
+type satisfiers struct {
+	packages map[string][]version.Version
+	provides map[string][]*dependency.VersionRelation
+}
+
+func newSatisfiers(available []BinaryIndex) satisfiers {
+	ret := satisfiers{
+		packages: map[string][]version.Version{},
+		provides: map[string][]*dependency.VersionRelation{},
+	}
+	for _, pkg := range available {
+		ret.packages[pkg.Package] = append(ret.packages[pkg.Package], pkg.Version)
+		provides := pkg.GetProvides()
+		for _, possibility := range provides.GetAllPossibilities() {
+			ret.provides[possibility.Name] = append(ret.provides[possibility.Name], possibility.Version)
+		}
+	}
+	return ret
+}
+
+func (s satisfiers) satisfies(possibility dependency.Possibility) bool {
+	if possibility.Substvar {
+		return false
+	}
+	for _, v := range s.packages[possibility.Name] {
+		if possibility.SatisfiedBy(possibility.Name, v) {
+			return true
+		}
+	}
+	for _, provided := range s.provides[possibility.Name] {
+		if possibility.Version == nil {
+			return true
+		}
+		/* Only a versioned Provides can satisfy a versioned relation */
+		if provided == nil || provided.Operator != "=" {
+			continue
+		}
+		if v, err := version.Parse(provided.Number); err == nil && possibility.Version.SatisfiedBy(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Split the Relations of dep into those that at least one of the available
+// packages can satisfy, and those that none of them can, which is a quick
+// way to spot broken dependencies before doing any real resolving.
+//
+// A Possibility is satisfied by a package of that name whose Version is
+// within the Possibility's version range (if any), or by a package that
+// Provides that name. Virtual packages are counted as per Debian policy: an
+// unversioned Provides only satisfies unversioned relations, while a
+// versioned one, such as "foo (= 1.0)", satisfies versioned relations that
+// its version is in, too. Architecture restrictions are not taken into
+// account, and substvars (such as ${misc:Depends}) are never satisfied.
+func PartitionRelations(dep dependency.Dependency, available []BinaryIndex) (satisfiable, unsatisfiable []dependency.Relation) {
+	index := newSatisfiers(available)
+	for _, relation := range dep.Relations {
+		ok := false
+		for _, possibility := range relation.Possibilities {
+			if index.satisfies(*possibility) {
+				ok = true
+				break
+			}
+		}
+		if ok {
+			satisfiable = append(satisfiable, *relation)
+		} else {
+			unsatisfiable = append(unsatisfiable, *relation)
+		}
+	}
+	return satisfiable, unsatisfiable
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,74 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"strings"
+	"testing"
+
+	"pault.ag/go/debian/control"
+	"pault.ag/go/debian/dependency"
+)
+
+/*
+ *
+ */
+
+func TestPartitionRelations(t *testing.T) {
+	// Test Packages {{{
+	available := []control.BinaryIndex{}
+	isok(t, control.Unmarshal(&available, strings.NewReader(`Package: libc6
+Version: 2.36-9
+
+Package: python3
+Version: 3.11.2-1
+
+Package: exim4-daemon-light
+Version: 4.96-15
+Provides: mail-transport-agent
+
+Package: libfoo-compat
+Version: 1.0-1
+Provides: libfoo (= 2.0)
+`)))
+	// }}}
+
+	dep, err := dependency.Parse("libc6 (>= 2.34), python2 | python3, ${misc:Depends}, mail-transport-agent, postfix (>= 3), libfoo (>= 1.5), libfoo (>= 3), mail-transport-agent (>= 1), libc6 (<< 2)")
+	isok(t, err)
+
+	satisfiable, unsatisfiable := control.PartitionRelations(*dep, available)
+
+	names := func(relations []dependency.Relation) string {
+		ret := []string{}
+		for _, relation := range relations {
+			ret = append(ret, relation.Possibilities[0].String())
+		}
+		return strings.Join(ret, ", ")
+	}
+	assert(t, names(satisfiable) == "libc6 (>= 2.34), python2, mail-transport-agent, libfoo (>= 1.5)")
+	assert(t, names(unsatisfiable) == "${misc:Depends}, postfix (>= 3), libfoo (>= 3), mail-transport-agent (>= 1), libc6 (<< 2)")
+
+	satisfiable, unsatisfiable = control.PartitionRelations(*dep, nil)
+	assert(t, len(satisfiable) == 0)
+	assert(t, len(unsatisfiable) == len(dep.Relations))
+}
+
+// vim: foldmethod=marker
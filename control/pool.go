@@ -0,0 +1,52 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control
+
+import (
+	"path"
+	"strings"
+)
+
+// Return the directory under pool/<component>/ that files of the given
+// source package live in, which is the first letter of its name, or the
+// first four for packages starting with "lib" (so libfoo goes in libf).
+func poolPrefix(source string) string {
+	n := 1
+	if strings.HasPrefix(source, "lib") {
+		n = 4
+	}
+	if len(source) < n {
+		/* dak puts a source named just "lib" in lib/ */
+		return source
+	}
+	return source[:n]
+}
+
+// Return the path a file of the given source package is put at in an
+// archive's pool, as used in the Filename field of a Packages index, and
+// the Directory field of a Sources index. For instance,
+// PoolPath("main", "libfoo", "libfoo1_1.0-1_amd64.deb") is
+// "pool/main/libf/libfoo/libfoo1_1.0-1_amd64.deb".
+func PoolPath(component, source, filename string) string {
+	return path.Join("pool", component, poolPrefix(source), source, filename)
+}
+
+// vim: foldmethod=marker
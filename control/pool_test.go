@@ -0,0 +1,54 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"testing"
+
+	"pault.ag/go/debian/control"
+)
+
+/*
+ *
+ */
+
+func TestPoolPath(t *testing.T) {
+	for _, tc := range []struct {
+		component string
+		source    string
+		filename  string
+		expected  string
+	}{
+		{"main", "hello", "hello_2.10-3.dsc", "pool/main/h/hello/hello_2.10-3.dsc"},
+		{"main", "libfoo", "libfoo1_1.0-1_amd64.deb", "pool/main/libf/libfoo/libfoo1_1.0-1_amd64.deb"},
+		{"contrib", "libxml2", "libxml2_2.9.14.orig.tar.xz", "pool/contrib/libx/libxml2/libxml2_2.9.14.orig.tar.xz"},
+		{"main", "lib", "lib_1.0.dsc", "pool/main/lib/lib/lib_1.0.dsc"},
+		{"non-free", "li", "li_1.0.dsc", "pool/non-free/l/li/li_1.0.dsc"},
+		{"main", "hello", "", "pool/main/h/hello"},
+		{"main", "x", "x_1.0.dsc", "pool/main/x/x/x_1.0.dsc"},
+	} {
+		if got := control.PoolPath(tc.component, tc.source, tc.filename); got != tc.expected {
+			t.Errorf("%s %s %s: got %q, expected %q", tc.component, tc.source, tc.filename, got, tc.expected)
+		}
+	}
+}
+
+// vim: foldmethod=marker
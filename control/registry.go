@@ -0,0 +1,238 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+type interfaceKey struct {
+	Type          reflect.Type
+	Discriminator string
+}
+
+var (
+	interfacesLock sync.RWMutex
+	interfaces     = map[interfaceKey]func() interface{}{}
+)
+
+// Teach the decoder how to fill in struct fields (or slice elements) of an
+// interface type, which it can't create on its own. The constructor has to
+// return a pointer to a new, empty concrete value that implements iface;
+// that value is then decoded as usual (by its UnmarshalControl method, if
+// it has one), and stored into the field.
+//
+// The discriminator is the control key the value was read from, such as
+// "Checksums-Sha256", which lets the one interface type map to different
+// concrete types depending on the field. A discriminator of "" is used for
+// any key that doesn't have a constructor of its own.
+//
+// iface is given as a pointer to the interface, as in
+// reflect.TypeOf((*FileHash)(nil)). This is safe to call from init().
+func RegisterInterface(iface reflect.Type, discriminator string, constructor func() interface{}) {
+	if iface.Kind() == reflect.Ptr {
+		iface = iface.Elem()
+	}
+	if iface.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("control: %s is not an interface type", iface))
+	}
+
+	interfacesLock.Lock()
+	defer interfacesLock.Unlock()
+	interfaces[interfaceKey{iface, discriminator}] = constructor
+}
+
+func lookupInterface(iface reflect.Type, discriminator string) func() interface{} {
+	interfacesLock.RLock()
+	defer interfacesLock.RUnlock()
+	if constructor, ok := interfaces[interfaceKey{iface, discriminator}]; ok {
+		return constructor
+	}
+	return interfaces[interfaceKey{iface, ""}]
+}
+
+func decodeInterface(incoming reflect.Value, incomingField reflect.StructField, data string) error {
+	key := controlKey(incomingField)
+	constructor := lookupInterface(incoming.Type(), key)
+	if constructor == nil {
+		return fmt.Errorf(
+			"No constructor registered for interface '%s' (field %s)",
+			incoming.Type(), key,
+		)
+	}
+
+	target := reflect.ValueOf(constructor())
+	if target.Kind() != reflect.Ptr || !target.Type().Implements(incoming.Type()) {
+		return fmt.Errorf(
+			"Constructor for '%s' returned a %s, which isn't a pointer implementing it",
+			incoming.Type(), target.Type(),
+		)
+	}
+	if err := decodeValue(target.Elem(), incomingField, data); err != nil {
+		return err
+	}
+	incoming.Set(target)
+	return nil
+}
+
+type typeCodec struct {
+	decode func(string) (interface{}, error)
+	encode func(interface{}) (string, error)
+}
+
+var (
+	typesLock sync.RWMutex
+	types     = map[reflect.Type]typeCodec{}
+)
+
+// Teach the decoder and encoder about a type which can't carry methods of
+// its own, such as [][]string, or a struct from another package. decode is
+// handed the field value, and has to return a value of type t; encode is
+// handed a value of type t, and returns the field value to write out.
+// Either may be nil, in which case that side falls back to the usual rules.
+//
+// Registered types are tried before anything else, and struct types aren't
+// flattened into the Paragraph. The lookup is keyed on the exact type, so
+// this has no effect on named types built on top of it. This is safe to
+// call from init().
+func RegisterType(t reflect.Type, decode func(string) (interface{}, error), encode func(interface{}) (string, error)) {
+	typesLock.Lock()
+	defer typesLock.Unlock()
+	types[t] = typeCodec{decode: decode, encode: encode}
+}
+
+func lookupType(t reflect.Type) (typeCodec, bool) {
+	typesLock.RLock()
+	defer typesLock.RUnlock()
+	codec, ok := types[t]
+	return codec, ok
+}
+
+var (
+	enumsLock sync.RWMutex
+	enums     = map[reflect.Type][]string{}
+)
+
+// Register a named string type (such as `type Priority string`) as an enum,
+// which may only take on one of the given values. When decoding, a value
+// that matches one of them (ignoring case) is stored using the spelling
+// given here, and the same goes for encoding, so "Optional" is written out
+// as "optional". Values that don't match anything are kept as they are,
+// unless the Decoder (or Encoder) is in strict mode, in which case they're
+// an error.
+//
+// t may be given as a pointer to the type, as with RegisterInterface. This
+// is safe to call from init().
+func RegisterEnum(t reflect.Type, values []string) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.String {
+		panic(fmt.Sprintf("control: %s is not a string type", t))
+	}
+
+	enumsLock.Lock()
+	defer enumsLock.Unlock()
+	enums[t] = append([]string{}, values...)
+}
+
+// Return the canonical spelling of the value, if t is a registered enum.
+// The bool is false if t is an enum and the value isn't one of its values.
+func canonicalEnum(t reflect.Type, value string) (string, bool) {
+	enumsLock.RLock()
+	defer enumsLock.RUnlock()
+	values, ok := enums[t]
+	if !ok {
+		return value, true
+	}
+	for _, it := range values {
+		if strings.EqualFold(it, value) {
+			return it, true
+		}
+	}
+	return value, false
+}
+
+// The Validatable interface is for types that can tell if their value is
+// one that's allowed, on top of having decoded fine, such as SourceFormat,
+// or a DSC, which checks its Format. In strict mode (see Decoder.SetStrict
+// and Encoder.SetStrict), ValidateControl is called on each struct, and
+// each of its members, that implements it and isn't empty, and any error
+// it returns is one for the whole Paragraph.
+type Validatable interface {
+	ValidateControl() error
+}
+
+// Walk the struct, and check every enum typed field (or element of a
+// slice) holds one of its registered values, and that every Validatable
+// field checks out. Empty values are allowed, since those are just missing
+// fields.
+func validateEnums(incoming reflect.Value) error {
+	if incoming.IsValid() && incoming.Kind() != reflect.Ptr &&
+		incoming.CanInterface() && !incoming.IsZero() {
+		/* Pointers are checked once we get to what they point to */
+		if it, ok := incoming.Interface().(Validatable); ok {
+			if err := it.ValidateControl(); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch incoming.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if incoming.IsNil() {
+			return nil
+		}
+		return validateEnums(incoming.Elem())
+	case reflect.Struct:
+		for i := 0; i < incoming.NumField(); i++ {
+			if incoming.Type().Field(i).PkgPath != "" {
+				continue
+			}
+			if err := validateEnums(incoming.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < incoming.Len(); i++ {
+			if err := validateEnums(incoming.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		value := incoming.String()
+		if _, ok := canonicalEnum(incoming.Type(), value); !ok && value != "" {
+			enumsLock.RLock()
+			defer enumsLock.RUnlock()
+			return fmt.Errorf(
+				"Invalid %s '%s', expected one of: %s",
+				incoming.Type().Name(), value,
+				strings.Join(enums[incoming.Type()], ", "),
+			)
+		}
+	}
+	return nil
+}
+
+// vim: foldmethod=marker
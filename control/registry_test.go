@@ -0,0 +1,238 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"pault.ag/go/debian/control"
+)
+
+/*
+ *
+ */
+
+type TestFileHash interface {
+	Validate() (bool, error)
+}
+
+type TestUnregistered interface {
+	Unregistered()
+}
+
+func init() {
+	fileHash := reflect.TypeOf((*TestFileHash)(nil))
+	control.RegisterInterface(fileHash, "Checksums-Md5", func() interface{} {
+		return &control.MD5DebianFileHash{}
+	})
+	control.RegisterInterface(fileHash, "Checksums-Sha256", func() interface{} {
+		return &control.SHA256DebianFileHash{}
+	})
+	control.RegisterInterface(fileHash, "", func() interface{} {
+		return &control.SHA1DebianFileHash{}
+	})
+
+	control.RegisterEnum(reflect.TypeOf(TestPriority("")), []string{
+		"required", "important", "standard", "optional", "extra",
+	})
+}
+
+type TestPolymorphicStruct struct {
+	Md5    []TestFileHash `control:"Checksums-Md5" delim:"\n" strip:"\n\r\t "`
+	Sha256 []TestFileHash `control:"Checksums-Sha256" delim:"\n" strip:"\n\r\t "`
+	Other  TestFileHash   `control:"X-Checksum"`
+}
+
+func TestInterfaceUnmarshal(t *testing.T) {
+	// Test Paragraph {{{
+	data := `Checksums-Md5:
+ 06495f9b23b1c9b1bf35c2346cb48f63 92748 fbautostart_2.718281828.orig.tar.gz
+Checksums-Sha256:
+ bb2fdfd4a38505905222ee02d8236a594bdf6eaefca23462294cacda631745c1 92748 fbautostart_2.718281828.orig.tar.gz
+ f7186d1bebde403527b5b3fd80406decaaf295366206667d5b402da962f0b772 2356 fbautostart_2.718281828-1.debian.tar.xz
+X-Checksum: bc36310c15edc9acf48f0a1daf548bcc6f861372 92748 fbautostart_2.718281828.orig.tar.gz
+`
+	// }}}
+	foo := TestPolymorphicStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(data)))
+
+	assert(t, len(foo.Md5) == 1)
+	md5, ok := foo.Md5[0].(*control.MD5DebianFileHash)
+	assert(t, ok)
+	assert(t, md5.Algorithm == "md5")
+
+	assert(t, len(foo.Sha256) == 2)
+	sha256, ok := foo.Sha256[1].(*control.SHA256DebianFileHash)
+	assert(t, ok)
+	assert(t, sha256.Size == 2356)
+
+	/* No constructor for X-Checksum, so the default one is used */
+	sha1, ok := foo.Other.(*control.SHA1DebianFileHash)
+	assert(t, ok)
+	assert(t, sha1.Algorithm == "sha1")
+
+	/* And back out again */
+	out := bytes.Buffer{}
+	isok(t, control.Marshal(&out, foo))
+	again := TestPolymorphicStruct{}
+	isok(t, control.Unmarshal(&again, &out))
+	assert(t, again.Sha256[1].(*control.SHA256DebianFileHash).Hash == sha256.Hash)
+}
+
+func TestUnregisteredInterfaceUnmarshal(t *testing.T) {
+	foo := struct{ Value TestUnregistered }{}
+	notok(t, control.Unmarshal(&foo, strings.NewReader("Value: foo\n")))
+}
+
+type TestPriority string
+
+type TestEnumStruct struct {
+	Package  string
+	Priority TestPriority
+	Others   []TestPriority `control:"X-Other-Priorities"`
+}
+
+func TestEnumUnmarshal(t *testing.T) {
+	foo := TestEnumStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(`Package: foo
+Priority: Optional
+X-Other-Priorities: extra IMPORTANT
+`)))
+	assert(t, foo.Priority == "optional")
+	assert(t, len(foo.Others) == 2)
+	assert(t, foo.Others[1] == "important")
+
+	/* Out of strict mode, anything goes */
+	isok(t, control.Unmarshal(&foo, strings.NewReader("Package: foo\nPriority: whenever\n")))
+	assert(t, foo.Priority == "whenever")
+}
+
+func TestEnumStrictUnmarshal(t *testing.T) {
+	decoder := control.NewDecoder(strings.NewReader(`Package: foo
+Priority: optional
+
+Package: bar
+Priority: whenever
+
+Package: baz
+X-Other-Priorities: standard sometimes
+`))
+	decoder.SetStrict(true)
+
+	foo := TestEnumStruct{}
+	isok(t, decoder.Decode(&foo))
+	assert(t, foo.Priority == "optional")
+	notok(t, decoder.Decode(&foo))
+	notok(t, decoder.Decode(&foo))
+}
+
+func TestEnumMarshal(t *testing.T) {
+	writer := bytes.Buffer{}
+	isok(t, control.Marshal(&writer, TestEnumStruct{
+		Package:  "foo",
+		Priority: "OPTIONAL",
+		Others:   []TestPriority{"Extra"},
+	}))
+	assert(t, writer.String() == `Package: foo
+Priority: optional
+X-Other-Priorities: extra
+`)
+
+	encoder := control.NewEncoder(&writer)
+	encoder.SetStrict(true)
+	notok(t, encoder.Encode(TestEnumStruct{Package: "foo", Priority: "whenever"}))
+}
+
+type TestPoint struct {
+	X, Y int
+}
+
+type TestPointStruct struct {
+	Package string
+	Origin  TestPoint
+	Points  []TestPoint `delim:" "`
+}
+
+func init() {
+	control.RegisterType(reflect.TypeOf(TestPoint{}), func(data string) (interface{}, error) {
+		point := TestPoint{}
+		if _, err := fmt.Sscanf(data, "%d,%d", &point.X, &point.Y); err != nil {
+			return nil, err
+		}
+		return point, nil
+	}, func(value interface{}) (string, error) {
+		point := value.(TestPoint)
+		return fmt.Sprintf("%d,%d", point.X, point.Y), nil
+	})
+}
+
+func TestRegisteredTypeUnmarshal(t *testing.T) {
+	foo := TestPointStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(`Package: foo
+Origin: 1,2
+Points: 3,4 5,6
+X: 10
+`)))
+	assert(t, foo.Origin.X == 1)
+	assert(t, foo.Origin.Y == 2)
+	assert(t, len(foo.Points) == 2)
+	assert(t, foo.Points[1].Y == 6)
+
+	notok(t, control.Unmarshal(&foo, strings.NewReader("Package: foo\nOrigin: nope\n")))
+}
+
+func TestRegisteredTypeMarshal(t *testing.T) {
+	out := bytes.Buffer{}
+	isok(t, control.Marshal(&out, TestPointStruct{
+		Package: "foo",
+		Origin:  TestPoint{1, 2},
+		Points:  []TestPoint{{3, 4}, {5, 6}},
+	}))
+	assert(t, out.String() == `Package: foo
+Origin: 1,2
+Points: 3,4 5,6
+`)
+}
+
+type TestBadPoint struct {
+	X int
+}
+
+type TestBadPointStruct struct {
+	Origin TestBadPoint
+}
+
+func TestRegisteredTypeWrongValue(t *testing.T) {
+	control.RegisterType(reflect.TypeOf(TestBadPoint{}), func(data string) (interface{}, error) {
+		return data, nil
+	}, nil)
+
+	foo := TestBadPointStruct{}
+	err := control.Unmarshal(&foo, strings.NewReader("Origin: 1\n"))
+	notok(t, err)
+	assert(t, strings.Contains(err.Error(), "returned a string"))
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,73 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"pault.ag/go/debian/dependency"
+)
+
+func init() {
+	RegisterType(reflect.TypeOf([][]string{}), decodeAlternatives, encodeAlternatives)
+}
+
+// Decode a relation field such as Depends into a list of alternatives,
+// so that "a | b, c" becomes [["a", "b"], ["c"]]. This is what a field
+// typed as [][]string gets, for resolvers that only care about names.
+//
+// Only the package names survive; any version constraints, architecture
+// restrictions, :arch qualifiers and build profiles are dropped. Use a
+// dependency.Dependency field if any of that matters.
+func decodeAlternatives(data string) (interface{}, error) {
+	ret := [][]string{}
+	if strings.TrimSpace(data) == "" {
+		return ret, nil
+	}
+
+	dep, err := dependency.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, relation := range dep.Relations {
+		names := []string{}
+		for _, possibility := range relation.Possibilities {
+			names = append(names, possibility.Name)
+		}
+		ret = append(ret, names)
+	}
+	return ret, nil
+}
+
+func encodeAlternatives(incoming interface{}) (string, error) {
+	relations := []string{}
+	for _, names := range incoming.([][]string) {
+		if len(names) == 0 {
+			return "", fmt.Errorf("Empty set of alternatives")
+		}
+		relations = append(relations, strings.Join(names, " | "))
+	}
+	return strings.Join(relations, ", "), nil
+}
+
+// vim: foldmethod=marker
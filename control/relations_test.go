@@ -0,0 +1,95 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"strings"
+	"testing"
+
+	"pault.ag/go/debian/control"
+	"pault.ag/go/debian/dependency"
+)
+
+/*
+ *
+ */
+
+type alternativesTest struct {
+	Package string
+	Depends [][]string
+}
+
+type alternativesParsedTest struct {
+	alternativesTest
+	Parsed dependency.Dependency `control:"Depends"`
+}
+
+func TestAlternativesUnmarshal(t *testing.T) {
+	// Test Paragraph {{{
+	reader := strings.NewReader(`Package: foo
+Depends: a | b (>= 1.0), c [amd64],
+ d:any | e
+`)
+	// }}}
+	el := alternativesParsedTest{}
+	isok(t, control.Unmarshal(&el, reader))
+
+	assert(t, len(el.Depends) == 3)
+	assert(t, strings.Join(el.Depends[0], " ") == "a b")
+	assert(t, strings.Join(el.Depends[1], " ") == "c")
+	assert(t, strings.Join(el.Depends[2], " ") == "d e")
+
+	/* Same shape as the full Dependency, just without the details */
+	assert(t, len(el.Parsed.Relations) == len(el.Depends))
+	for i, relation := range el.Parsed.Relations {
+		assert(t, len(relation.Possibilities) == len(el.Depends[i]))
+		for j, possibility := range relation.Possibilities {
+			assert(t, possibility.Name == el.Depends[i][j])
+		}
+	}
+}
+
+func TestAlternativesRoundTrip(t *testing.T) {
+	el := alternativesTest{
+		Package: "foo",
+		Depends: [][]string{{"a", "b"}, {"c"}},
+	}
+	para, err := control.ConvertToParagraph(el)
+	isok(t, err)
+	assert(t, para.Values["Depends"] == "a | b, c")
+
+	again := alternativesTest{}
+	isok(t, control.Unmarshal(&again, strings.NewReader("Package: foo\nDepends: "+para.Values["Depends"]+"\n")))
+	assert(t, len(again.Depends) == 2)
+	assert(t, strings.Join(again.Depends[0], " ") == "a b")
+	assert(t, strings.Join(again.Depends[1], " ") == "c")
+
+	empty := alternativesTest{}
+	isok(t, control.Unmarshal(&empty, strings.NewReader("Package: foo\n")))
+	assert(t, len(empty.Depends) == 0)
+}
+
+func TestAlternativesUnmarshalError(t *testing.T) {
+	el := alternativesTest{}
+	notok(t, control.Unmarshal(&el, strings.NewReader("Package: foo\nDepends: a (>> \n")))
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,251 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"pault.ag/go/debian/dependency"
+	"pault.ag/go/debian/internal/decompress"
+)
+
+// The Release struct is the encapsulation of a Debian archive's Release (or
+// InRelease) file, which describes a suite, and lists the checksums of all
+// the indices in it.
+type Release struct {
+	Paragraph
+
+	Origin               string
+	Label                string
+	Suite                string
+	Version              string
+	Codename             string
+	Date                 time.Time
+	ValidUntil           time.Time `control:"Valid-Until"`
+	NotAutomatic         string    `control:"NotAutomatic"`
+	ButAutomaticUpgrades string    `control:"ButAutomaticUpgrades"`
+	AcquireByHash        string    `control:"Acquire-By-Hash"`
+	SignedBy             string    `control:"Signed-By"`
+
+	Architectures []dependency.Arch
+	Components    []string
+	Description   string
+
+	MD5Sum []MD5DebianFileHash    `control:"MD5Sum" delim:"\n" strip:"\n\r\t "`
+	SHA1   []SHA1DebianFileHash   `control:"SHA1" delim:"\n" strip:"\n\r\t "`
+	SHA256 []SHA256DebianFileHash `control:"SHA256" delim:"\n" strip:"\n\r\t "`
+	SHA512 []SHA512DebianFileHash `control:"SHA512" delim:"\n" strip:"\n\r\t "`
+}
+
+// The order fields are written out in a Release file, matching what
+// apt-ftparchive(1) emits.
+var releaseFieldOrder = []string{
+	"Origin", "Label", "Suite", "Version", "Codename", "Date", "Valid-Until",
+	"NotAutomatic", "ButAutomaticUpgrades", "Acquire-By-Hash", "Signed-By",
+	"Architectures", "Components", "Description",
+	"MD5Sum", "SHA1", "SHA256", "SHA512",
+}
+
+// Given a path on the filesystem, Parse the file off the disk and return
+// a pointer to a brand new Release struct, unless error is set to a value
+// other than nil.
+func ParseReleaseFile(path string) (*Release, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseRelease(bufio.NewReader(f))
+}
+
+// Given a bufio.Reader, consume the Reader, and return a Release object
+// for use.
+func ParseRelease(reader *bufio.Reader) (*Release, error) {
+	ret := Release{}
+	if err := Unmarshal(&ret, reader); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// Check to see if this Release has indices (such as Packages) for the given
+// component and architecture, that is, if both are listed in Components
+// and Architectures. Components are compared as written in the Release,
+// which, for some archives, includes a prefix, as in "updates/main". A
+// wildcard arch (such as linux-any) is never offered, and all is only
+// offered if it's listed itself.
+func (release *Release) Offers(component string, arch dependency.Arch) bool {
+	if !arch.IsConcrete() && arch.CPU != "all" {
+		return false
+	}
+
+	found := false
+	for _, el := range release.Components {
+		if el == component {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+
+	for _, el := range release.Architectures {
+		if el.Is(&arch) {
+			return true
+		}
+	}
+	return false
+}
+
+// Look up the index at the given path (such as main/binary-amd64/Packages)
+// in the hash sections of this Release, and return the entry for its
+// smallest variant, be it .xz, .gz, or the uncompressed file itself, which
+// is the one to download with the least traffic. Only variants that
+// DecompressReader can read are considered, so a .zst is skipped unless a
+// Decompressor was registered for it (see RegisterDecompressor). The hash
+// is from the strongest algorithm the Release has (SHA512, then SHA256,
+// SHA1, and MD5Sum); the Filename tells which variant it was. If the index
+// isn't listed at all, false is returned.
+func (release *Release) FindIndex(path string) (DebianFileHash, bool) {
+	sections := [][]DebianFileHash{{}, {}, {}, {}}
+	for _, hash := range release.SHA512 {
+		sections[0] = append(sections[0], hash.DebianFileHash)
+	}
+	for _, hash := range release.SHA256 {
+		sections[1] = append(sections[1], hash.DebianFileHash)
+	}
+	for _, hash := range release.SHA1 {
+		sections[2] = append(sections[2], hash.DebianFileHash)
+	}
+	for _, hash := range release.MD5Sum {
+		sections[3] = append(sections[3], hash.DebianFileHash)
+	}
+
+	/* The index itself, or path plus a suffix we can decompress */
+	readable := func(filename string) bool {
+		if !strings.HasPrefix(filename, path) {
+			return false
+		}
+		suffix := filename[len(path):]
+		if suffix == "" {
+			return true
+		}
+		_, ok := decompress.Lookup(suffix)
+		return ok
+	}
+
+	for _, section := range sections {
+		found := false
+		best := DebianFileHash{}
+		for _, hash := range section {
+			if !readable(hash.Filename) {
+				continue
+			}
+			if !found || hash.Size < best.Size {
+				best = hash
+				found = true
+			}
+		}
+		if found {
+			return best, true
+		}
+	}
+	return DebianFileHash{}, false
+}
+
+// Format a hash section the way apt-ftparchive does, one file per line,
+// with the size right aligned in a 16 character wide column:
+//
+//	SHA256:
+//	 e3b0c442...b855                0 main/source/Sources
+func releaseHashes(hashes []SHADebianFileHash) string {
+	ret := ""
+	for _, hash := range hashes {
+		ret += fmt.Sprintf("\n%s %16d %s", hash.Hash, hash.Size, hash.Filename)
+	}
+	return ret
+}
+
+// Write the Release out to the io.Writer in the format apt-ftparchive
+// uses, which apt is happy to accept. Empty fields are left out, and keys
+// from the Paragraph that Release doesn't model come after the known ones
+// (but before the hash sections).
+func WriteRelease(writer io.Writer, release Release) error {
+	fields, err := ConvertToParagraph(release)
+	if err != nil {
+		return err
+	}
+
+	hashes := map[string][]SHADebianFileHash{}
+	for _, hash := range release.MD5Sum {
+		hashes["MD5Sum"] = append(hashes["MD5Sum"], hash.SHADebianFileHash)
+	}
+	for _, hash := range release.SHA1 {
+		hashes["SHA1"] = append(hashes["SHA1"], hash.SHADebianFileHash)
+	}
+	for _, hash := range release.SHA256 {
+		hashes["SHA256"] = append(hashes["SHA256"], hash.SHADebianFileHash)
+	}
+	for _, hash := range release.SHA512 {
+		hashes["SHA512"] = append(hashes["SHA512"], hash.SHADebianFileHash)
+	}
+
+	known := map[string]bool{}
+	for _, key := range releaseFieldOrder {
+		known[key] = true
+	}
+
+	para := Paragraph{Values: map[string]string{}, Order: []string{}}
+	for _, key := range releaseFieldOrder {
+		if key == "MD5Sum" {
+			/* Anything we don't know about goes before the hashes */
+			for _, extra := range release.Order {
+				if !known[extra] {
+					para.Set(extra, release.Values[extra])
+				}
+			}
+		}
+
+		if list, ok := hashes[key]; ok {
+			para.Set(key, releaseHashes(list))
+			continue
+		}
+
+		value := fields.Values[key]
+		if value == "" {
+			/* Struct fields win, but the raw values fill in the gaps */
+			value = release.Values[key]
+		}
+		if strings.TrimSpace(value) != "" {
+			para.Set(key, value)
+		}
+	}
+
+	return NewEncoder(writer).Encode(para)
+}
+
+// vim: foldmethod=marker
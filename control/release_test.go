@@ -0,0 +1,209 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"pault.ag/go/debian/control"
+	"pault.ag/go/debian/dependency"
+)
+
+/*
+ *
+ */
+
+// Test Release golden file {{{
+
+// A tiny archive, laid out the way `apt-ftparchive release` writes it (with
+// the APT::FTPArchive::Release fields set); the hashes are of the real files.
+const goldenRelease = `Origin: Example
+Label: Example
+Suite: stable
+Codename: example
+Date: Sat, 20 Jan 2024 12:00:00 UTC
+Architectures: amd64
+Components: main
+Description: An example archive
+MD5Sum:
+ 54c2dbda710105a71757671eb3f30414               51 main/binary-amd64/Packages
+ ed1b586dd4318af0fa9ceebffd097c60               70 main/binary-amd64/Packages.gz
+ 82a1ae90445e5284617f8a8cc3a53fa4               52 main/binary-amd64/Release
+ d41d8cd98f00b204e9800998ecf8427e                0 main/source/Sources
+ 4a4dd3598707603b3f76a2378a4504aa               20 main/source/Sources.gz
+SHA1:
+ 45983208f41cd0245656194e8eef30b8a515abf8               51 main/binary-amd64/Packages
+ afe85e7b88579b24ca19d1c455d9ded05b677ed5               70 main/binary-amd64/Packages.gz
+ 9b61d92e2c42866227d305db51276f4714da5cd9               52 main/binary-amd64/Release
+ da39a3ee5e6b4b0d3255bfef95601890afd80709                0 main/source/Sources
+ a0fddd5458378c1bf3c10dd2f5c060d1347741ed               20 main/source/Sources.gz
+SHA256:
+ 50ab596bd22530812dc0ded894228c81ec55b077f1fe85f4224fd1cbf0c71bb4               51 main/binary-amd64/Packages
+ e7fee7b431c0a3cd386ae9a6231130e4e1bb733b8a87b828e1d733fec5d1a94e               70 main/binary-amd64/Packages.gz
+ 5d14a4f1b270a16b7797dfa013f0d0d3325c616762714c6e9a34295a4d9be351               52 main/binary-amd64/Release
+ e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855                0 main/source/Sources
+ f61f27bd17de546264aa58f40f3aafaac7021e0ef69c17f6b1b4cd7664a037ec               20 main/source/Sources.gz
+SHA512:
+ 9851a3eff24c7c55c7e12323463f339947eef12a6a81a567fec2424a7e9be47810fd8feeb2fb1833949d4c80a4ef87585406c674e5c679148974374ae7f067a9               51 main/binary-amd64/Packages
+ eda67a97d27a0c76ce741dbea36652273791c365ae05a775f88f833fb754d8f5aa6ddd94d70dd800c010a48fc3bdf332d9fcd73483885c67713ee9271cc2af14               70 main/binary-amd64/Packages.gz
+ 2bde22e4d9330b3c462ef38d6e24eac306f5d752535a17cb1d54548a347fcd6fcc017d2cd759ba8104675060c85fb5f6e60cc60ef470d8c2880cc1c42ee01b7d               52 main/binary-amd64/Release
+ cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e                0 main/source/Sources
+ 1b46b9b08d5b338be9d732a1724795b2eab63daffde377218727c90857b79fe6a47bceed495117fcde60f7339812ef75ef4c69f82dd79fb69b6cbf8006b521f2               20 main/source/Sources.gz
+`
+
+// }}}
+
+func TestReleaseParse(t *testing.T) {
+	release, err := control.ParseRelease(bufio.NewReader(strings.NewReader(goldenRelease)))
+	isok(t, err)
+
+	assert(t, release.Suite == "stable")
+	assert(t, release.Date.Equal(time.Date(2024, 1, 20, 12, 0, 0, 0, time.UTC)))
+	assert(t, release.ValidUntil.IsZero())
+	assert(t, len(release.Architectures) == 1)
+	assert(t, release.Architectures[0].CPU == "amd64")
+	assert(t, len(release.SHA256) == 5)
+	assert(t, release.SHA256[3].Size == 0)
+	assert(t, release.SHA256[4].Filename == "main/source/Sources.gz")
+	assert(t, release.SHA512[0].Algorithm == "sha512")
+}
+
+func TestWriteReleaseGolden(t *testing.T) {
+	release, err := control.ParseRelease(bufio.NewReader(strings.NewReader(goldenRelease)))
+	isok(t, err)
+
+	out := bytes.Buffer{}
+	isok(t, control.WriteRelease(&out, *release))
+	if out.String() != goldenRelease {
+		t.Fatalf("WriteRelease output differs from golden:\n%s", out.String())
+	}
+}
+
+func TestWriteReleaseFromScratch(t *testing.T) {
+	release := control.Release{
+		Suite:      "unstable",
+		Components: []string{"main", "contrib"},
+	}
+	hash := control.SHA256DebianFileHash{}
+	isok(t, hash.UnmarshalControl("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855 0 main/source/Sources"))
+	release.SHA256 = append(release.SHA256, hash)
+
+	out := bytes.Buffer{}
+	isok(t, control.WriteRelease(&out, release))
+	assert(t, out.String() == `Suite: unstable
+Components: main contrib
+SHA256:
+ e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855                0 main/source/Sources
+`)
+}
+
+func TestReleaseFindIndex(t *testing.T) {
+	// Test Release {{{
+	input := `Suite: unstable
+MD5Sum:
+ 0ac3a0e1c8f5e48bd0a74ba8eb5c5d91          1952205 main/binary-amd64/Packages.xz
+SHA256:
+ 3e1d6e2be2d6cf77a219c5b1f3f6e0b4c4d6e1bb8ef0e7a9e2d2cb2c9cb0fa0b         10419838 main/binary-amd64/Packages
+ 9d7f1c4c6beeb4bd1a80a3d3c4a7b5c0a8b6b7c2e5a9a0f3c1d1a3e6f6d5a4b1          2662142 main/binary-amd64/Packages.gz
+ 1d1a36b3c5dfb9cd2a8a0e0fdbe9e94f1c6c3a0b4d3b7f5e8a0b1c2d3e4f5a6b          1952205 main/binary-amd64/Packages.xz
+ 7c1e0b7a3f7b3f9d2a1c6e5d4b3a2f1e0d9c8b7a6f5e4d3c2b1a0f9e8d7c6b5a          1802205 main/binary-amd64/Packages.zst
+ 0d9c8b7a6f5e4d3c2b1a0f9e8d7c6b5a7c1e0b7a3f7b3f9d2a1c6e5d4b3a2f1e          1702205 main/binary-amd64/Packages.findindex
+ 5b4e3cbb7d1c1f0d2e8d7a6d5f4e3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a8f7e6d              119 main/binary-amd64/Release
+ a2f1e0d9c8b7a6f5e4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a8f7e6d5c4b3a2f1            22144 main/binary-amd64/Packages.diff/Index
+ e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855                0 main/source/Sources
+ f61f27bd17de546264aa58f40f3aafaac7021e0ef69c17f6b1b4cd7664a037ec               20 main/source/Sources.gz
+`
+	// }}}
+	release, err := control.ParseRelease(bufio.NewReader(strings.NewReader(input)))
+	isok(t, err)
+
+	/* The .zst is smaller, but there's nothing to decompress it with */
+	hash, ok := release.FindIndex("main/binary-amd64/Packages")
+	assert(t, ok)
+	assert(t, hash.Filename == "main/binary-amd64/Packages.xz")
+	assert(t, hash.Algorithm == "sha256")
+	assert(t, hash.Size == 1952205)
+	assert(t, hash.Hash == "1d1a36b3c5dfb9cd2a8a0e0fdbe9e94f1c6c3a0b4d3b7f5e8a0b1c2d3e4f5a6b")
+
+	/* An empty index is smaller uncompressed */
+	hash, ok = release.FindIndex("main/source/Sources")
+	assert(t, ok)
+	assert(t, hash.Filename == "main/source/Sources")
+
+	hash, ok = release.FindIndex("main/binary-amd64/Release")
+	assert(t, ok)
+	assert(t, hash.Size == 119)
+
+	_, ok = release.FindIndex("main/binary-i386/Packages")
+	assert(t, !ok)
+	_, ok = release.FindIndex("main/binary-amd64/Pack")
+	assert(t, !ok)
+
+	/* Once there's a Decompressor for it, a smaller variant wins */
+	control.RegisterDecompressor(".findindex", control.DecompressorFunc(func(in io.Reader) (io.Reader, error) {
+		return in, nil
+	}))
+	hash, ok = release.FindIndex("main/binary-amd64/Packages")
+	assert(t, ok)
+	assert(t, hash.Filename == "main/binary-amd64/Packages.findindex")
+}
+
+func TestReleaseOffers(t *testing.T) {
+	// Test Release {{{
+	input := `Origin: Debian
+Suite: stable
+Codename: bookworm
+Architectures: all amd64 arm64 i386
+Components: main contrib non-free-firmware
+`
+	// }}}
+	release, err := control.ParseRelease(bufio.NewReader(strings.NewReader(input)))
+	isok(t, err)
+	assert(t, len(release.Components) == 3)
+	assert(t, release.Components[2] == "non-free-firmware")
+	assert(t, len(release.Architectures) == 4)
+
+	arch := func(name string) dependency.Arch {
+		ret, err := dependency.ParseArch(name)
+		isok(t, err)
+		return *ret
+	}
+
+	assert(t, release.Offers("main", arch("amd64")))
+	assert(t, release.Offers("contrib", arch("arm64")))
+	assert(t, release.Offers("main", arch("all")))
+	assert(t, !release.Offers("non-free", arch("amd64")))
+	assert(t, !release.Offers("main", arch("armhf")))
+	assert(t, !release.Offers("main", arch("any")))
+	assert(t, !release.Offers("main", arch("linux-any")))
+
+	/* And both lists make it back out the way they came in */
+	out := bytes.Buffer{}
+	isok(t, control.WriteRelease(&out, *release))
+	assert(t, out.String() == input)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,107 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A Section is the value of a Section field, such as "libs", or
+// "contrib/libs" for a package outside of main. The raw value is kept
+// as-is, Component and Name split it up.
+type Section string
+
+// Return the archive component this Section is in, which is the part
+// before the "/", or "main" if there's no component given.
+func (s Section) Component() string {
+	if i := strings.Index(string(s), "/"); i >= 0 {
+		return string(s)[:i]
+	}
+	return "main"
+}
+
+// Return the name of the Section, without any component.
+func (s Section) Name() string {
+	if i := strings.Index(string(s), "/"); i >= 0 {
+		return string(s)[i+1:]
+	}
+	return string(s)
+}
+
+// A PackageListEntry is a single line of the Package-List field of a
+// .dsc, which lists the binaries a source package builds, such as:
+//
+//	fbautostart deb contrib/x11 optional arch=any
+//
+// Options holds the trailing key=value pairs, such as arch, profile and
+// essential.
+type PackageListEntry struct {
+	Package  string
+	Type     string
+	Section  Section
+	Priority string
+	Options  map[string]string
+}
+
+func (p *PackageListEntry) UnmarshalControl(data string) error {
+	fields := strings.Fields(data)
+	if len(fields) < 4 {
+		return fmt.Errorf("Malformed Package-List entry: '%s'", data)
+	}
+
+	p.Package = fields[0]
+	p.Type = fields[1]
+	p.Section = Section(fields[2])
+	p.Priority = fields[3]
+	p.Options = map[string]string{}
+	for _, option := range fields[4:] {
+		els := strings.SplitN(option, "=", 2)
+		if len(els) != 2 {
+			return fmt.Errorf("Malformed Package-List option: '%s'", option)
+		}
+		p.Options[els[0]] = els[1]
+	}
+	return nil
+}
+
+func (p PackageListEntry) MarshalControl() (string, error) {
+	fields := []string{p.Package, p.Type, string(p.Section), p.Priority}
+
+	/* arch= first, the way dpkg-source writes it */
+	if arch, ok := p.Options["arch"]; ok {
+		fields = append(fields, "arch="+arch)
+	}
+	keys := []string{}
+	for key := range p.Options {
+		if key != "arch" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fields = append(fields, key+"="+p.Options[key])
+	}
+	return strings.Join(fields, " "), nil
+}
+
+// vim: foldmethod=marker
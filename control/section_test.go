@@ -0,0 +1,83 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"pault.ag/go/debian/control"
+)
+
+/*
+ *
+ */
+
+func TestSection(t *testing.T) {
+	section := control.Section("contrib/libs")
+	assert(t, section.Component() == "contrib")
+	assert(t, section.Name() == "libs")
+	assert(t, string(section) == "contrib/libs")
+
+	section = control.Section("libs")
+	assert(t, section.Component() == "main")
+	assert(t, section.Name() == "libs")
+}
+
+func TestDSCPackageListParse(t *testing.T) {
+	// Test DSC {{{
+	reader := bufio.NewReader(strings.NewReader(`Format: 3.0 (quilt)
+Source: fbautostart
+Binary: fbautostart, fbautostart-data
+Version: 2.718281828-1
+Package-List:
+ fbautostart deb contrib/x11 optional arch=any
+ fbautostart-data deb x11 optional arch=all profile=!nodoc
+`))
+	// }}}
+	c, err := control.ParseDsc(reader, "")
+	isok(t, err)
+	assert(t, len(c.PackageList) == 2)
+
+	entry := c.PackageList[0]
+	assert(t, entry.Package == "fbautostart")
+	assert(t, entry.Type == "deb")
+	assert(t, entry.Section == "contrib/x11")
+	assert(t, entry.Section.Component() == "contrib")
+	assert(t, entry.Section.Name() == "x11")
+	assert(t, entry.Priority == "optional")
+	assert(t, entry.Options["arch"] == "any")
+
+	entry = c.PackageList[1]
+	assert(t, entry.Section.Component() == "main")
+	assert(t, entry.Options["profile"] == "!nodoc")
+
+	line, err := entry.MarshalControl()
+	isok(t, err)
+	assert(t, line == "fbautostart-data deb x11 optional arch=all profile=!nodoc")
+
+	bad := control.PackageListEntry{}
+	notok(t, bad.UnmarshalControl("fbautostart deb x11"))
+	notok(t, bad.UnmarshalControl("fbautostart deb x11 optional any"))
+}
+
+// vim: foldmethod=marker
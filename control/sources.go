@@ -0,0 +1,129 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control
+
+import (
+	"io"
+	"strings"
+)
+
+// The order fields are written out in a Sources index. This is apt-pkg's
+// TFRewriteSourceOrder (as of apt 2.6), which is what apt-ftparchive(1)
+// writes stanzas out with. Fields not listed here come after these, in
+// the order they were in.
+var sourcesFieldOrder = []string{
+	"Package", "Source", "Format", "Binary", "Architecture", "Version",
+	"Priority", "Section", "Origin", "Maintainer", "Original-Maintainer",
+	"Uploaders", "Dm-Upload-Allowed", "Standards-Version",
+	"Build-Depends", "Build-Depends-Arch", "Build-Depends-Indep",
+	"Build-Conflicts", "Build-Conflicts-Arch", "Build-Conflicts-Indep",
+	"Testsuite", "Testsuite-Triggers", "Homepage", "Description",
+	"Vcs-Browser", "Vcs-Browse", "Vcs-Arch", "Vcs-Bzr", "Vcs-Cvs", "Vcs-Darcs",
+	"Vcs-Git", "Vcs-Hg", "Vcs-Mtn", "Vcs-Svn", "Directory", "Package-List", "Files",
+	"Checksums-Md5", "Checksums-Sha1", "Checksums-Sha256", "Checksums-Sha512",
+}
+
+// Fields that hold one entry per line, and always start on the line after
+// the key.
+var sourcesFoldedFields = map[string]bool{
+	"Package-List":     true,
+	"Files":            true,
+	"Checksums-Md5":    true,
+	"Checksums-Sha1":   true,
+	"Checksums-Sha256": true,
+	"Checksums-Sha512": true,
+}
+
+// A SourcesWriter writes SourceIndex entries out as a Sources index, one
+// stanza after another, with the fields in a fixed order and the file
+// lists folded one per line. The same input always gives the same bytes.
+type SourcesWriter struct {
+	encoder *Encoder
+}
+
+// Create a new SourcesWriter, which will write to the given io.Writer.
+func NewSourcesWriter(writer io.Writer) *SourcesWriter {
+	return &SourcesWriter{encoder: NewEncoder(writer)}
+}
+
+// Write out a single SourceIndex. Fields set on the struct win over the
+// raw values in its Paragraph; anything else in the Paragraph (such as the
+// Checksums-* fields, which SourceIndex doesn't model) is kept as-is.
+func (w *SourcesWriter) Write(source SourceIndex) error {
+	para, err := sourcesParagraph(source)
+	if err != nil {
+		return err
+	}
+	return w.encoder.Encode(*para)
+}
+
+// Write all the given SourceIndex entries to the io.Writer, as a Sources
+// index.
+func WriteSources(writer io.Writer, sources []SourceIndex) error {
+	w := NewSourcesWriter(writer)
+	for _, source := range sources {
+		if err := w.Write(source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sourcesParagraph(source SourceIndex) (*Paragraph, error) {
+	fields, err := ConvertToParagraph(source)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	order := []string{}
+	for _, key := range source.Order {
+		values[key] = source.Values[key]
+		order = append(order, key)
+	}
+	for _, key := range fields.Order {
+		if fields.Values[key] == "" {
+			continue
+		}
+		if _, ok := values[key]; !ok {
+			order = append(order, key)
+		}
+		values[key] = fields.Values[key]
+	}
+
+	ret := Paragraph{Values: map[string]string{}, Order: []string{}}
+	seen := map[string]bool{}
+	for _, key := range append(append([]string{}, sourcesFieldOrder...), order...) {
+		value, ok := values[key]
+		if !ok || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if sourcesFoldedFields[key] {
+			value = "\n" + strings.TrimLeft(value, "\n")
+		}
+		ret.Set(key, value)
+	}
+	return &ret, nil
+}
+
+// vim: foldmethod=marker
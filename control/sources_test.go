@@ -0,0 +1,219 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"pault.ag/go/debian/control"
+	"pault.ag/go/debian/version"
+)
+
+/*
+ *
+ */
+
+// Test Sources golden stanzas {{{
+
+const goldenSources = `Package: hello
+Format: 3.0 (quilt)
+Binary: hello
+Architecture: any
+Version: 2.10-3
+Priority: optional
+Section: devel
+Maintainer: Santiago Vila <sanvila@debian.org>
+Standards-Version: 4.6.2
+Build-Depends: debhelper-compat (= 13)
+Testsuite: autopkgtest
+Homepage: https://www.gnu.org/software/hello/
+Vcs-Browser: https://salsa.debian.org/sanvila/hello
+Vcs-Git: https://salsa.debian.org/sanvila/hello.git
+Directory: pool/main/h/hello
+Package-List:
+ hello deb devel optional arch=any
+Files:
+ b64b1d0c1cf2ac1ecf5f2a7898a1f1be 1692 hello_2.10-3.dsc
+ 6cd0ffea3884a4e79330338dcc2987d6 725946 hello_2.10.orig.tar.gz
+ 6e3ddbea89d3c2d3a1ec5b6d1ee3d6da 12688 hello_2.10-3.debian.tar.xz
+Checksums-Sha256:
+ 317ebd0460191c6b2baa7f2688695586948a1e6455569ecd669c48cb2889d151 1692 hello_2.10-3.dsc
+ 31e066137a962676e89f69d1b65382de95a7ef7d914b8cb956f41ea72e0f516b 725946 hello_2.10.orig.tar.gz
+ 0cc8ee2ea61c3732c5ff1069d1181cf691d78e8cf326cc4cd5a6d4e87a0f6237 12688 hello_2.10-3.debian.tar.xz
+
+Package: fbautostart
+Format: 3.0 (quilt)
+Binary: fbautostart
+Architecture: any
+Version: 2.718281828-1
+Maintainer: Paul Tagliamonte <paultag@ubuntu.com>
+Directory: pool/main/f/fbautostart
+Files:
+ 06495f9b23b1c9b1bf35c2346cb48f63 92748 fbautostart_2.718281828.orig.tar.gz
+`
+
+// }}}
+
+func TestWriteSourcesGolden(t *testing.T) {
+	sources := []control.SourceIndex{}
+	isok(t, control.Unmarshal(&sources, bufio.NewReader(strings.NewReader(goldenSources))))
+	assert(t, len(sources) == 2)
+
+	out := bytes.Buffer{}
+	isok(t, control.WriteSources(&out, sources))
+	if out.String() != goldenSources {
+		t.Fatalf("Sources output differs from golden:\n%s", out.String())
+	}
+}
+
+// Test apt-ftparchive Sources stanza {{{
+
+/* The fields of hello_2.10-3.dsc in the order they're in there, once
+ * apt-ftparchive has renamed Source to Package, added the .dsc itself to
+ * the file lists, and tacked on the Directory and the overrides. */
+const dscOrderSources = `Format: 3.0 (quilt)
+Package: hello
+Binary: hello
+Architecture: any
+Version: 2.10-3
+Maintainer: Santiago Vila <sanvila@debian.org>
+Homepage: https://www.gnu.org/software/hello/
+Standards-Version: 4.6.2
+Vcs-Browser: https://salsa.debian.org/sanvila/hello
+Vcs-Git: https://salsa.debian.org/sanvila/hello.git
+Testsuite: autopkgtest
+Build-Depends: debhelper-compat (= 13)
+Package-List:
+ hello deb devel optional arch=any
+Checksums-Sha256:
+ 317ebd0460191c6b2baa7f2688695586948a1e6455569ecd669c48cb2889d151 1692 hello_2.10-3.dsc
+ 31e066137a962676e89f69d1b65382de95a7ef7d914b8cb956f41ea72e0f516b 725946 hello_2.10.orig.tar.gz
+ 0cc8ee2ea61c3732c5ff1069d1181cf691d78e8cf326cc4cd5a6d4e87a0f6237 12688 hello_2.10-3.debian.tar.xz
+Files:
+ b64b1d0c1cf2ac1ecf5f2a7898a1f1be 1692 hello_2.10-3.dsc
+ 6cd0ffea3884a4e79330338dcc2987d6 725946 hello_2.10.orig.tar.gz
+ 6e3ddbea89d3c2d3a1ec5b6d1ee3d6da 12688 hello_2.10-3.debian.tar.xz
+Directory: pool/main/h/hello
+Priority: optional
+Section: devel
+`
+
+/* What apt-pkg 2.6 wrote out for the above, with TFRewriteSourceOrder */
+const aptFtparchiveSources = `Package: hello
+Format: 3.0 (quilt)
+Binary: hello
+Architecture: any
+Version: 2.10-3
+Priority: optional
+Section: devel
+Maintainer: Santiago Vila <sanvila@debian.org>
+Standards-Version: 4.6.2
+Build-Depends: debhelper-compat (= 13)
+Testsuite: autopkgtest
+Homepage: https://www.gnu.org/software/hello/
+Vcs-Browser: https://salsa.debian.org/sanvila/hello
+Vcs-Git: https://salsa.debian.org/sanvila/hello.git
+Directory: pool/main/h/hello
+Package-List:
+ hello deb devel optional arch=any
+Files:
+ b64b1d0c1cf2ac1ecf5f2a7898a1f1be 1692 hello_2.10-3.dsc
+ 6cd0ffea3884a4e79330338dcc2987d6 725946 hello_2.10.orig.tar.gz
+ 6e3ddbea89d3c2d3a1ec5b6d1ee3d6da 12688 hello_2.10-3.debian.tar.xz
+Checksums-Sha256:
+ 317ebd0460191c6b2baa7f2688695586948a1e6455569ecd669c48cb2889d151 1692 hello_2.10-3.dsc
+ 31e066137a962676e89f69d1b65382de95a7ef7d914b8cb956f41ea72e0f516b 725946 hello_2.10.orig.tar.gz
+ 0cc8ee2ea61c3732c5ff1069d1181cf691d78e8cf326cc4cd5a6d4e87a0f6237 12688 hello_2.10-3.debian.tar.xz
+`
+
+// }}}
+
+func TestWriteSourcesAptFtparchive(t *testing.T) {
+	sources := []control.SourceIndex{}
+	isok(t, control.Unmarshal(&sources, strings.NewReader(dscOrderSources)))
+	assert(t, len(sources) == 1)
+
+	out := bytes.Buffer{}
+	isok(t, control.WriteSources(&out, sources))
+	if out.String() != aptFtparchiveSources {
+		t.Fatalf("Sources output differs from apt-ftparchive:\n%s", out.String())
+	}
+}
+
+func TestWriteSourcesOrder(t *testing.T) {
+	sources := []control.SourceIndex{}
+	isok(t, control.Unmarshal(&sources, bufio.NewReader(strings.NewReader(`Package: hello
+Checksums-Sha256:
+ 317ebd0460191c6b2baa7f2688695586948a1e6455569ecd669c48cb2889d151 1692 hello_2.10-3.dsc
+X-Fnord: yes
+Files: b64b1d0c1cf2ac1ecf5f2a7898a1f1be 1692 hello_2.10-3.dsc
+Version: 2.10-3
+Format: 3.0 (quilt)
+`))))
+
+	/* Fields set on the struct land where they belong */
+	sources[0].Section = "devel"
+	sources[0].StandardsVersion = "4.6.2"
+
+	out := bytes.Buffer{}
+	isok(t, control.WriteSources(&out, sources))
+	assert(t, out.String() == `Package: hello
+Format: 3.0 (quilt)
+Version: 2.10-3
+Section: devel
+Standards-Version: 4.6.2
+Files:
+ b64b1d0c1cf2ac1ecf5f2a7898a1f1be 1692 hello_2.10-3.dsc
+Checksums-Sha256:
+ 317ebd0460191c6b2baa7f2688695586948a1e6455569ecd669c48cb2889d151 1692 hello_2.10-3.dsc
+X-Fnord: yes
+`)
+}
+
+func TestWriteSourcesFromStruct(t *testing.T) {
+	out := bytes.Buffer{}
+	writer := control.NewSourcesWriter(&out)
+	isok(t, writer.Write(control.SourceIndex{
+		Package:   "fbautostart",
+		Binaries:  []string{"fbautostart"},
+		Version:   version.Version{Version: "2.718281828", Revision: "1"},
+		Format:    "3.0 (quilt)",
+		Directory: "pool/main/f/fbautostart",
+		Files: []string{
+			"06495f9b23b1c9b1bf35c2346cb48f63 92748 fbautostart_2.718281828.orig.tar.gz",
+			"f58c0e0bf4d56461e776232484c07301 2356 fbautostart_2.718281828-1.debian.tar.xz",
+		},
+	}))
+	assert(t, out.String() == `Package: fbautostart
+Format: 3.0 (quilt)
+Binary: fbautostart
+Version: 2.718281828-1
+Directory: pool/main/f/fbautostart
+Files:
+ 06495f9b23b1c9b1bf35c2346cb48f63 92748 fbautostart_2.718281828.orig.tar.gz
+ f58c0e0bf4d56461e776232484c07301 2356 fbautostart_2.718281828-1.debian.tar.xz
+`)
+}
+
+// vim: foldmethod=marker
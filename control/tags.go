@@ -0,0 +1,114 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control
+
+import (
+	"strings"
+)
+
+// Tags is the list of debtags of a binary package, as found in the Tag
+// field of a Packages file. Each tag is of the form facet::value, such as
+// "implemented-in::c" or "role::program".
+//
+// When decoding, the brace shorthand used by debtags is expanded, so that
+// "implemented-in::{c,c++}" becomes the two tags "implemented-in::c" and
+// "implemented-in::c++". When encoding, tags that share a facet are
+// collapsed back into the brace form.
+type Tags []string
+
+// Split the Tag field on commas, ignoring any commas inside of braces.
+func splitTags(data string) []string {
+	ret := []string{}
+	depth := 0
+	current := ""
+
+	for _, chr := range data {
+		switch chr {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				ret = append(ret, current)
+				current = ""
+				continue
+			}
+		}
+		current += string(chr)
+	}
+	return append(ret, current)
+}
+
+func (tags *Tags) UnmarshalControl(data string) error {
+	*tags = Tags{}
+
+	for _, el := range splitTags(data) {
+		el = strings.Trim(el, " \n\r\t")
+		if el == "" {
+			continue
+		}
+
+		bits := strings.SplitN(el, "::", 2)
+		if len(bits) == 2 && strings.HasPrefix(bits[1], "{") && strings.HasSuffix(bits[1], "}") {
+			values := strings.Split(bits[1][1:len(bits[1])-1], ",")
+			for _, value := range values {
+				*tags = append(*tags, bits[0]+"::"+strings.TrimSpace(value))
+			}
+			continue
+		}
+		*tags = append(*tags, el)
+	}
+	return nil
+}
+
+func (tags Tags) MarshalControl() (string, error) {
+	facets := []string{}
+	values := map[string][]string{}
+
+	for _, tag := range tags {
+		bits := strings.SplitN(tag, "::", 2)
+		if len(bits) != 2 {
+			/* Not a facet::value tag; keep it as-is */
+			facets = append(facets, tag)
+			continue
+		}
+		if _, ok := values[bits[0]]; !ok {
+			facets = append(facets, bits[0])
+		}
+		values[bits[0]] = append(values[bits[0]], bits[1])
+	}
+
+	ret := []string{}
+	for _, facet := range facets {
+		switch len(values[facet]) {
+		case 0:
+			ret = append(ret, facet)
+		case 1:
+			ret = append(ret, facet+"::"+values[facet][0])
+		default:
+			ret = append(ret, facet+"::{"+strings.Join(values[facet], ",")+"}")
+		}
+	}
+	return strings.Join(ret, ", "), nil
+}
+
+// vim: foldmethod=marker
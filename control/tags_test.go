@@ -0,0 +1,86 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"pault.ag/go/debian/control"
+)
+
+/*
+ *
+ */
+
+func TestTagsParse(t *testing.T) {
+	// Test Binary Index {{{
+	reader := bufio.NewReader(strings.NewReader(`Package: fbautostart
+Version: 2.718281828-1
+Architecture: amd64
+Tag: implemented-in::{c,c++}, interface::x11, role::program,
+ uitoolkit::{gtk,qt}, x11::application
+`))
+	// }}}
+	packages, err := control.ParseBinaryIndex(reader)
+	isok(t, err)
+	assert(t, len(packages) == 1)
+
+	tags := packages[0].Tags
+	assert(t, len(tags) == 7)
+	assert(t, tags[0] == "implemented-in::c")
+	assert(t, tags[1] == "implemented-in::c++")
+	assert(t, tags[2] == "interface::x11")
+	assert(t, tags[4] == "uitoolkit::gtk")
+	assert(t, tags[5] == "uitoolkit::qt")
+	assert(t, tags[6] == "x11::application")
+}
+
+type TestTagsStruct struct {
+	Package string
+	Tags    control.Tags `control:"Tag"`
+}
+
+func TestTagsMarshal(t *testing.T) {
+	foo := TestTagsStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(`Package: fbautostart
+Tag: implemented-in::c, role::program, implemented-in::c++, special::invalid-tag
+`)))
+	assert(t, len(foo.Tags) == 4)
+
+	out := bytes.Buffer{}
+	isok(t, control.Marshal(&out, foo))
+	assert(t, out.String() == `Package: fbautostart
+Tag: implemented-in::{c,c++}, role::program, special::invalid-tag
+`)
+
+	/* And back again */
+	bar := TestTagsStruct{}
+	isok(t, control.Unmarshal(&bar, &out))
+	assert(t, len(bar.Tags) == 4)
+	assert(t, bar.Tags[0] == "implemented-in::c")
+	assert(t, bar.Tags[1] == "implemented-in::c++")
+	assert(t, bar.Tags[2] == "role::program")
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,104 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterType(reflect.TypeOf(time.Time{}), decodeTime, encodeTime)
+	RegisterType(reflect.TypeOf(time.Duration(0)), decodeDuration, encodeDuration)
+}
+
+// The layouts a time.Time member will be decoded from, in the order
+// they're tried. Release files (Date and Valid-Until) are written with
+// either UTC or a numeric zone, depending on the archive software. The
+// day is padded by `date -R` and dpkg-parsechangelog alike; the unpadded
+// layouts are only here to be lenient with hand-written files.
+var timeLayouts = []string{
+	"Mon, 02 Jan 2006 15:04:05 -0700",
+	"Mon, 02 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+}
+
+func decodeTime(data string) (interface{}, error) {
+	data = strings.TrimSpace(data)
+	if data == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range timeLayouts {
+		when, err := time.Parse(layout, data)
+		if err != nil {
+			continue
+		}
+		/* time.Parse makes up a zero offset for zone names it doesn't
+		 * know, which would quietly give the wrong time */
+		if name, offset := when.Zone(); strings.HasSuffix(layout, "MST") &&
+			offset == 0 && name != "UTC" && name != "GMT" {
+			return nil, fmt.Errorf("Unknown time zone '%s' in date: '%s'", name, data)
+		}
+		return when, nil
+	}
+	return nil, fmt.Errorf("Unknown date format: '%s'", data)
+}
+
+// Times in UTC are written out the way Release files usually have them,
+// with "UTC" as the zone; anything else gets a numeric zone.
+func encodeTime(incoming interface{}) (string, error) {
+	when := incoming.(time.Time)
+	if when.IsZero() {
+		return "", nil
+	}
+	if when.Location() == time.UTC {
+		return when.Format("Mon, 02 Jan 2006 15:04:05 UTC"), nil
+	}
+	return when.Format("Mon, 02 Jan 2006 15:04:05 -0700"), nil
+}
+
+// Durations don't show up in any field Debian defines, but do in the odd
+// extension field (such as a Cache-Max-Age), so they're read and written
+// in Go's own syntax, as in "24h" or "1h30m".
+func decodeDuration(data string) (interface{}, error) {
+	data = strings.TrimSpace(data)
+	if data == "" {
+		return time.Duration(0), nil
+	}
+	duration, err := time.ParseDuration(data)
+	if err != nil {
+		return nil, fmt.Errorf("Unknown duration: '%s'", data)
+	}
+	return duration, nil
+}
+
+func encodeDuration(incoming interface{}) (string, error) {
+	duration := incoming.(time.Duration)
+	if duration == 0 {
+		return "", nil
+	}
+	return duration.String(), nil
+}
+
+// vim: foldmethod=marker
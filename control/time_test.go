@@ -0,0 +1,139 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"pault.ag/go/debian/control"
+)
+
+/*
+ *
+ */
+
+type TestTimeStruct struct {
+	Date       time.Time
+	ValidUntil time.Time `control:"Valid-Until"`
+}
+
+func TestTimeUnmarshal(t *testing.T) {
+	expected := time.Date(2024, 1, 6, 8, 51, 47, 0, time.UTC)
+
+	for _, spelling := range []string{
+		"Sat, 06 Jan 2024 08:51:47 UTC",
+		"Sat, 06 Jan 2024 08:51:47 +0000",
+		"Sat, 06 Jan 2024 09:51:47 +0100",
+		"Sat, 6 Jan 2024 09:51:47 +0100",
+		"Sat, 6 Jan 2024 08:51:47 UTC",
+	} {
+		foo := TestTimeStruct{}
+		err := control.Unmarshal(&foo, strings.NewReader("Date: "+spelling+"\n"))
+		if err != nil {
+			t.Errorf("%q: %s", spelling, err)
+			continue
+		}
+		if !foo.Date.Equal(expected) {
+			t.Errorf("%q: got %s", spelling, foo.Date)
+		}
+		assert(t, foo.ValidUntil.IsZero())
+	}
+}
+
+func TestTimeUnmarshalError(t *testing.T) {
+	foo := TestTimeStruct{}
+	err := control.Unmarshal(&foo, strings.NewReader("Date: yesterday, at noon\n"))
+	notok(t, err)
+	assert(t, strings.Contains(err.Error(), "yesterday, at noon"))
+}
+
+func TestTimeMarshal(t *testing.T) {
+	foo := TestTimeStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader(`Date: Sat, 06 Jan 2024 08:51:47 UTC
+Valid-Until: Sat, 13 Jan 2024 09:51:47 +0100
+`)))
+
+	out := bytes.Buffer{}
+	isok(t, control.Marshal(&out, foo))
+	assert(t, out.String() == `Date: Sat, 06 Jan 2024 08:51:47 UTC
+Valid-Until: Sat, 13 Jan 2024 09:51:47 +0100
+`)
+}
+
+func TestTimeRoundTrip(t *testing.T) {
+	for _, spelling := range []string{
+		"Thu, 12 Oct 2023 14:33:02 +0000",
+		"Thu, 12 Oct 2023 14:33:02 UTC",
+		"Thu, 12 Oct 2023 16:33:02 +0200",
+		"Thu, 12 Oct 2023 09:33:02 -0500",
+	} {
+		foo := TestTimeStruct{}
+		isok(t, control.Unmarshal(&foo, strings.NewReader("Date: "+spelling+"\n")))
+		out := bytes.Buffer{}
+		isok(t, control.Marshal(&out, foo))
+		if !strings.HasPrefix(out.String(), "Date: "+spelling+"\n") {
+			t.Errorf("%q: got %q back", spelling, out.String())
+		}
+	}
+
+	/* Zone names that aren't known have no offset to go on */
+	foo := TestTimeStruct{}
+	err := control.Unmarshal(&foo, strings.NewReader("Date: Thu, 12 Oct 2023 14:33:02 XYZT\n"))
+	notok(t, err)
+	assert(t, strings.Contains(err.Error(), "Unknown time zone 'XYZT'"))
+
+	isok(t, control.Unmarshal(&foo, strings.NewReader("Date: Thu, 12 Oct 2023 14:33:02 GMT\n")))
+}
+
+type TestDurationStruct struct {
+	CacheMaxAge time.Duration   `control:"Cache-Max-Age"`
+	Delays      []time.Duration `control:"X-Delays"`
+}
+
+func TestDurationRoundTrip(t *testing.T) {
+	foo := TestDurationStruct{}
+	isok(t, control.Unmarshal(&foo, strings.NewReader("Cache-Max-Age: 24h\nX-Delays: 30m 1h30m 90s\n")))
+	assert(t, foo.CacheMaxAge == 24*time.Hour)
+	assert(t, len(foo.Delays) == 3)
+	assert(t, foo.Delays[1] == 90*time.Minute)
+	assert(t, foo.Delays[2] == 90*time.Second)
+
+	out := bytes.Buffer{}
+	isok(t, control.Marshal(&out, foo))
+	assert(t, out.String() == "Cache-Max-Age: 24h0m0s\nX-Delays: 30m0s 1h30m0s 1m30s\n")
+
+	again := TestDurationStruct{}
+	isok(t, control.Unmarshal(&again, &out))
+	assert(t, again.CacheMaxAge == foo.CacheMaxAge)
+	assert(t, len(again.Delays) == 3 && again.Delays[2] == foo.Delays[2])
+
+	/* Plain numbers have no unit, so they're no good either */
+	for _, bad := range []string{"a day", "86400"} {
+		err := control.Unmarshal(&foo, strings.NewReader("Cache-Max-Age: "+bad+"\n"))
+		notok(t, err)
+		assert(t, strings.Contains(err.Error(), "Unknown duration: '"+bad+"'"))
+	}
+}
+
+// vim: foldmethod=marker
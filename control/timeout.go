@@ -0,0 +1,110 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// Returned by reads through a reader from NewTimeoutReader when the
+// underlying io.Reader didn't come back with anything in time.
+var ErrReadTimeout = errors.New("pault.ag/go/debian/control: read timed out")
+
+type timeoutReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	timeout time.Duration
+
+	buf []byte
+	err error
+}
+
+type timeoutRead struct {
+	n   int
+	err error
+}
+
+// Wrap an io.Reader (such as a network connection) so that each Read
+// has to finish within timeout, and before ctx is done, rather than hang
+// forever when the other end stalls. A timeout of 0 means only ctx is
+// checked. Once a Read has timed out, it and every Read after it return
+// ErrReadTimeout (or the ctx error), since the stalled Read may still be
+// going on in the background, and there's no telling where the stream is.
+//
+// This is meant to go in front of NewDecoder, or UnmarshalTimeout.
+func NewTimeoutReader(ctx context.Context, reader io.Reader, timeout time.Duration) io.Reader {
+	return &timeoutReader{ctx: ctx, reader: reader, timeout: timeout}
+}
+
+func (r *timeoutReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if err := r.ctx.Err(); err != nil {
+		r.err = err
+		return 0, err
+	}
+
+	/* The Read has to go into a buffer of our own, since if it times out
+	 * it'll still be writing to it after we've returned */
+	if cap(r.buf) < len(p) {
+		r.buf = make([]byte, len(p))
+	}
+	buf := r.buf[:len(p)]
+
+	done := make(chan timeoutRead, 1)
+	go func() {
+		n, err := r.reader.Read(buf)
+		done <- timeoutRead{n, err}
+	}()
+
+	var expired <-chan time.Time
+	if r.timeout > 0 {
+		timer := time.NewTimer(r.timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case result := <-done:
+		copy(p, buf[:result.n])
+		return result.n, result.err
+	case <-expired:
+		r.err = ErrReadTimeout
+	case <-r.ctx.Done():
+		r.err = r.ctx.Err()
+	}
+	/* Don't let the next Read reuse buf, the stalled one still owns it */
+	r.buf = nil
+	return 0, r.err
+}
+
+// The same as Unmarshal, but reading through NewTimeoutReader, so that a
+// stalled reader turns into an ErrReadTimeout (or ctx error) rather than a
+// hang.
+func UnmarshalTimeout(ctx context.Context, incoming interface{}, data io.Reader, timeout time.Duration) error {
+	return Unmarshal(incoming, NewTimeoutReader(ctx, data, timeout))
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,96 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"pault.ag/go/debian/control"
+)
+
+/*
+ *
+ */
+
+// Hands out one line at a time, taking delay to do each.
+type slowReader struct {
+	lines []string
+	delay time.Duration
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if len(r.lines) == 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(r.delay)
+	n := copy(p, r.lines[0])
+	r.lines = r.lines[1:]
+	return n, nil
+}
+
+func newSlowReader(delay time.Duration) *slowReader {
+	return &slowReader{
+		lines: []string{"Package: foo\n", "Version: 1.0-1\n", "\n", "Package: bar\n"},
+		delay: delay,
+	}
+}
+
+func TestUnmarshalTimeout(t *testing.T) {
+	packages := []control.BinaryIndex{}
+	isok(t, control.UnmarshalTimeout(
+		context.Background(), &packages,
+		newSlowReader(time.Millisecond), time.Second,
+	))
+	assert(t, len(packages) == 2)
+	assert(t, packages[0].Package == "foo")
+	assert(t, packages[1].Package == "bar")
+}
+
+func TestUnmarshalTimeoutStalled(t *testing.T) {
+	packages := []control.BinaryIndex{}
+	start := time.Now()
+	err := control.UnmarshalTimeout(
+		context.Background(), &packages,
+		newSlowReader(time.Second), 10*time.Millisecond,
+	)
+	assert(t, err == control.ErrReadTimeout)
+	assert(t, time.Since(start) < 500*time.Millisecond)
+}
+
+func TestTimeoutReaderContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	decoder := control.NewDecoder(control.NewTimeoutReader(ctx, newSlowReader(time.Second), 0))
+	para := control.Paragraph{}
+	assert(t, decoder.Decode(&para) == context.Canceled)
+
+	/* An already cancelled ctx doesn't even get as far as reading */
+	reader := control.NewTimeoutReader(ctx, strings.NewReader("Package: foo\n"), 0)
+	_, err := reader.Read(make([]byte, 16))
+	assert(t, err == context.Canceled)
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,93 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control
+
+import (
+	"fmt"
+	"io"
+
+	"pault.ag/go/debian/dependency"
+)
+
+// Read each Paragraph off in, hand it to fn to change however it likes,
+// and write the result out, one Paragraph at a time, so that even huge
+// files (such as Packages) never have to be in memory all at once. The
+// blank lines after the last Paragraph, and whether the file ends in a
+// newline at all, are kept as they were.
+func Transform(in io.Reader, out io.Writer, fn func(*Paragraph) error) error {
+	decoder := NewDecoder(in)
+	encoder := NewEncoder(out)
+	/* Hold the last newline back until we know if the input had one */
+	encoder.SetFinalNewline(false)
+	for {
+		para := Paragraph{}
+		if err := decoder.Decode(&para); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if err := fn(&para); err != nil {
+			return err
+		}
+		if err := encoder.Encode(para); err != nil {
+			return err
+		}
+	}
+	encoder.SetTrailingBlankLines(decoder.TrailingBlankLines())
+	encoder.SetFinalNewline(decoder.FinalNewline())
+	return encoder.Close()
+}
+
+// Like Transform, but rather than being handed the whole Paragraph, fn
+// gets each relation field (such as Depends or Build-Depends) that's set,
+// already parsed, to change in place. Whatever fn leaves behind is written
+// back out in place of the old value.
+func TransformDependencies(
+	in io.Reader,
+	out io.Writer,
+	fn func(key string, dep *dependency.Dependency) error,
+) error {
+	return Transform(in, out, func(para *Paragraph) error {
+		for _, key := range para.Order {
+			if !canonicalizeListFields[key] {
+				continue
+			}
+			dep, err := dependency.Parse(para.Values[key])
+			if err != nil {
+				return fmt.Errorf(
+					"pault.ag/go/debian/control: failed to parse %s: %s",
+					key, err,
+				)
+			}
+			if err := fn(key, dep); err != nil {
+				return err
+			}
+			value, err := dep.MarshalControl()
+			if err != nil {
+				return err
+			}
+			para.Set(key, value)
+		}
+		return nil
+	})
+}
+
+// vim: foldmethod=marker
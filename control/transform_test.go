@@ -0,0 +1,110 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"pault.ag/go/debian/control"
+	"pault.ag/go/debian/dependency"
+)
+
+/*
+ *
+ */
+
+func TestTransform(t *testing.T) {
+	// Test Packages {{{
+	data := `Package: foo
+Version: 1.0-1
+
+Package: bar
+Version: 2.0-1
+
+`
+	// }}}
+	out := bytes.Buffer{}
+	isok(t, control.Transform(strings.NewReader(data), &out, func(para *control.Paragraph) error {
+		para.Set("Version", para.Values["Version"]+"+b1")
+		return nil
+	}))
+	assert(t, out.String() == `Package: foo
+Version: 1.0-1+b1
+
+Package: bar
+Version: 2.0-1+b1
+
+`)
+
+	/* Errors out of fn stop the whole thing */
+	notok(t, control.Transform(strings.NewReader(data), &out, func(para *control.Paragraph) error {
+		return fmt.Errorf("Nope")
+	}))
+}
+
+func TestTransformDependencies(t *testing.T) {
+	// Test Packages {{{
+	data := `Package: foo
+Depends: libc6 (>= 2.17), libfoo1 (>= 1.0) | libfoo2
+Description: foo
+
+Package: bar
+Pre-Depends: libfoo1 (>= 1.0)
+Recommends: foo
+
+Package: baz
+`
+	// }}}
+	out := bytes.Buffer{}
+	seen := []string{}
+	isok(t, control.TransformDependencies(strings.NewReader(data), &out, func(key string, dep *dependency.Dependency) error {
+		seen = append(seen, key)
+		for _, possi := range dep.GetAllPossibilities() {
+			if possi.Name == "libfoo1" && possi.Version != nil {
+				possi.Version.Number = "1.2"
+			}
+		}
+		return nil
+	}))
+	assert(t, strings.Join(seen, " ") == "Depends Pre-Depends Recommends")
+	assert(t, out.String() == `Package: foo
+Depends: libc6 (>= 2.17), libfoo1 (>= 1.2) | libfoo2
+Description: foo
+
+Package: bar
+Pre-Depends: libfoo1 (>= 1.2)
+Recommends: foo
+
+Package: baz
+`)
+
+	/* Relations that don't parse are an error */
+	notok(t, control.TransformDependencies(
+		strings.NewReader("Package: foo\nDepends: foo (>= \n"),
+		&out,
+		func(key string, dep *dependency.Dependency) error { return nil },
+	))
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,96 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var (
+	marshalableType   = reflect.TypeOf((*Marshalable)(nil)).Elem()
+	unmarshalableType = reflect.TypeOf((*Unmarshalable)(nil)).Elem()
+)
+
+// Check a struct definition for mistakes that Marshal and Unmarshal won't
+// complain about on their own. Currently, that's two members mapping to
+// the same control key (say, a member named Homepage, and another tagged
+// `control:"Homepage"`), where decoding sets both, and encoding writes
+// out whichever comes last. Nested structs are checked the same way they
+// get flattened by Marshal.
+//
+// This only looks at the type, so the zero value works just as well,
+// as in control.ValidateStruct(BinaryIndex{}).
+func ValidateStruct(incoming interface{}) error {
+	t := reflect.TypeOf(incoming)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return fmt.Errorf("Ouchie! I can only validate a struct")
+	}
+	return validateStruct(t, "", map[string]string{})
+}
+
+func validateStruct(incoming reflect.Type, prefix string, seen map[string]string) error {
+	paragraphType := reflect.TypeOf(Paragraph{})
+
+	for i := 0; i < incoming.NumField(); i++ {
+		fieldType := incoming.Field(i)
+		name := prefix + fieldType.Name
+
+		if fieldType.Anonymous && fieldType.Type == paragraphType {
+			continue
+		}
+		if fieldType.PkgPath != "" {
+			continue
+		}
+
+		key := controlKey(fieldType)
+		if key == "-" || isExtra(fieldType) {
+			continue
+		}
+
+		_, registered := lookupType(fieldType.Type)
+		if fieldType.Type.Kind() == reflect.Struct && !registered &&
+			!fieldType.Type.Implements(marshalableType) &&
+			!reflect.PtrTo(fieldType.Type).Implements(unmarshalableType) {
+			if err := validateStruct(fieldType.Type, name+".", seen); err != nil {
+				return err
+			}
+			continue
+		}
+
+		/* Keys are matched without regard to case on the way in, so
+		 * Homepage and homepage would fight over the same value. */
+		if other, ok := seen[strings.ToLower(key)]; ok {
+			return fmt.Errorf(
+				"pault.ag/go/debian/control: %s and %s both map to the control key %s",
+				other, name, key,
+			)
+		}
+		seen[strings.ToLower(key)] = name
+	}
+	return nil
+}
+
+// vim: foldmethod=marker
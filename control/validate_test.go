@@ -0,0 +1,91 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"strings"
+	"testing"
+
+	"pault.ag/go/debian/control"
+	"pault.ag/go/debian/version"
+)
+
+/*
+ *
+ */
+
+type TestConflictingStruct struct {
+	Package  string
+	Homepage string
+	Website  string `control:"Homepage"`
+}
+
+type TestCaseConflictStruct struct {
+	Homepage string
+	Website  string `control:"homepage"`
+}
+
+type TestNestedConflictStruct struct {
+	Package string
+	Nested  struct {
+		Package string
+	}
+}
+
+type TestNotConflictingStruct struct {
+	control.Paragraph
+
+	Package string
+	Version version.Version
+	Ignored string `control:"-"`
+	Other   string `control:"-"`
+	Nested  struct {
+		Section string
+	}
+}
+
+func TestValidateStruct(t *testing.T) {
+	err := control.ValidateStruct(TestConflictingStruct{})
+	notok(t, err)
+	assert(t, strings.Contains(err.Error(), "Homepage and Website"))
+
+	err = control.ValidateStruct(TestCaseConflictStruct{})
+	notok(t, err)
+	assert(t, strings.Contains(err.Error(), "Homepage and Website"))
+
+	err = control.ValidateStruct(&TestNestedConflictStruct{})
+	notok(t, err)
+	assert(t, strings.Contains(err.Error(), "Nested.Package"))
+
+	isok(t, control.ValidateStruct(TestNotConflictingStruct{}))
+	isok(t, control.ValidateStruct(&control.BinaryIndex{}))
+	isok(t, control.ValidateStruct(control.DSC{}))
+	isok(t, control.ValidateStruct(control.Changes{}))
+	isok(t, control.ValidateStruct(control.SourceIndex{}))
+	isok(t, control.ValidateStruct(control.BuildInfo{}))
+	isok(t, control.ValidateStruct(control.Release{}))
+	isok(t, control.ValidateStruct(control.SourceParagraph{}))
+	isok(t, control.ValidateStruct(control.BinaryParagraph{}))
+
+	notok(t, control.ValidateStruct("Package: foo"))
+}
+
+// vim: foldmethod=marker
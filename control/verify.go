@@ -0,0 +1,158 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/clearsign"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// The outcome of checking a single OpenPGP signature on a clearsigned
+// file, such as an InRelease. KeyID is the (long) ID of the key that made
+// the signature, and Valid is only set if that key is in the keyring and
+// the signature checks out. If not, Err says why.
+type SignatureResult struct {
+	KeyID uint64
+	Valid bool
+	Err   error
+}
+
+// Check every signature on a clearsigned file against the keyring. Files
+// may be signed by more than one key (such as when an archive is rotating
+// its signing key), so it's up to the caller to decide if it wants at
+// least one, or all of the signatures to be Valid. An error is only
+// returned if the file isn't clearsigned, or the signatures can't be read.
+func VerifyClearsigned(reader io.Reader, keyring openpgp.KeyRing) ([]SignatureResult, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := clearsign.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("No clearsigned message found")
+	}
+	return verifyBlock(block, keyring)
+}
+
+func verifyBlock(block *clearsign.Block, keyring openpgp.KeyRing) ([]SignatureResult, error) {
+	ret := []SignatureResult{}
+	packets := packet.NewReader(block.ArmoredSignature.Body)
+	for {
+		p, err := packets.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		sig, ok := p.(*packet.Signature)
+		if !ok {
+			return nil, fmt.Errorf("Unexpected packet in signature block: %T", p)
+		}
+		if sig.IssuerKeyId == nil {
+			ret = append(ret, SignatureResult{Err: fmt.Errorf("Signature has no issuer")})
+			continue
+		}
+		ret = append(ret, verifySignature(block, sig, keyring))
+	}
+
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("No signatures found")
+	}
+	return ret, nil
+}
+
+// Returned by DecodeClearsigned when the file couldn't be trusted, as
+// opposed to when it couldn't be parsed. Results holds the outcome of
+// checking each signature, if it got that far.
+type SignatureError struct {
+	Results []SignatureResult
+	Err     error
+}
+
+func (err SignatureError) Error() string {
+	return fmt.Sprintf("pault.ag/go/debian/control: bad signature: %s", err.Err)
+}
+
+// Check the signatures on a clearsigned file (such as an InRelease) against
+// the keyring, and, if at least one of them is Valid, Unmarshal the signed
+// data inside into into. If the file isn't clearsigned, or none of the
+// signatures check out, a SignatureError is returned, and nothing is
+// decoded; errors from decoding the data itself come back as-is.
+func DecodeClearsigned(reader io.Reader, keyring openpgp.KeyRing, into interface{}) error {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	block, _ := clearsign.Decode(data)
+	if block == nil {
+		return SignatureError{Err: fmt.Errorf("No clearsigned message found")}
+	}
+
+	results, err := verifyBlock(block, keyring)
+	if err != nil {
+		return SignatureError{Err: err}
+	}
+	valid := false
+	for _, result := range results {
+		valid = valid || result.Valid
+	}
+	if !valid {
+		return SignatureError{
+			Results: results,
+			Err:     fmt.Errorf("None of the %d signatures are valid", len(results)),
+		}
+	}
+
+	/* Plaintext has the armor and dash-escaping taken off already */
+	return Unmarshal(into, bytes.NewReader(block.Plaintext))
+}
+
+func verifySignature(block *clearsign.Block, sig *packet.Signature, keyring openpgp.KeyRing) SignatureResult {
+	result := SignatureResult{KeyID: *sig.IssuerKeyId}
+
+	keys := keyring.KeysByIdUsage(result.KeyID, packet.KeyFlagSign)
+	if len(keys) == 0 {
+		result.Err = fmt.Errorf("Key %X is not in the keyring", result.KeyID)
+		return result
+	}
+
+	for _, key := range keys {
+		hash := sig.Hash.New()
+		/* block.Bytes is already canonicalized, with \r\n line endings */
+		hash.Write(block.Bytes)
+		if result.Err = key.PublicKey.VerifySignature(hash, sig); result.Err == nil {
+			result.Valid = true
+			return result
+		}
+	}
+	return result
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,126 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package control_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/clearsign"
+	"golang.org/x/crypto/openpgp/packet"
+
+	"pault.ag/go/debian/control"
+)
+
+/*
+ *
+ */
+
+func newTestEntity(t *testing.T, name string) *openpgp.Entity {
+	entity, err := openpgp.NewEntity(name, "", name+"@example.com", &packet.Config{RSABits: 1024})
+	isok(t, err)
+	return entity
+}
+
+func TestVerifyClearsignedMultipleSignatures(t *testing.T) {
+	trusted := newTestEntity(t, "trusted")
+	untrusted := newTestEntity(t, "untrusted")
+
+	out := bytes.Buffer{}
+	plaintext, err := clearsign.EncodeMulti(&out, []*packet.PrivateKey{
+		untrusted.PrivateKey, trusted.PrivateKey,
+	}, nil)
+	isok(t, err)
+	io.WriteString(plaintext, "Origin: Debian\nSuite: unstable\n")
+	isok(t, plaintext.Close())
+	signed := out.Bytes()
+
+	results, err := control.VerifyClearsigned(bytes.NewReader(signed), openpgp.EntityList{trusted})
+	isok(t, err)
+	assert(t, len(results) == 2)
+	assert(t, results[0].KeyID == untrusted.PrimaryKey.KeyId)
+	assert(t, !results[0].Valid)
+	notok(t, results[0].Err)
+	assert(t, results[1].KeyID == trusted.PrimaryKey.KeyId)
+	assert(t, results[1].Valid)
+	isok(t, results[1].Err)
+
+	/* Both keys are trusted */
+	results, err = control.VerifyClearsigned(bytes.NewReader(signed), openpgp.EntityList{trusted, untrusted})
+	isok(t, err)
+	assert(t, results[0].Valid && results[1].Valid)
+
+	/* Tampering breaks every signature */
+	tampered := bytes.Replace(signed, []byte("unstable"), []byte("testing"), 1)
+	results, err = control.VerifyClearsigned(bytes.NewReader(tampered), openpgp.EntityList{trusted, untrusted})
+	isok(t, err)
+	assert(t, !results[0].Valid && !results[1].Valid)
+
+	_, err = control.VerifyClearsigned(bytes.NewReader([]byte("Origin: Debian\n")), openpgp.EntityList{trusted})
+	notok(t, err)
+}
+
+func TestDecodeClearsigned(t *testing.T) {
+	trusted := newTestEntity(t, "trusted")
+	untrusted := newTestEntity(t, "untrusted")
+
+	sign := func(key *packet.PrivateKey, body string) []byte {
+		out := bytes.Buffer{}
+		plaintext, err := clearsign.Encode(&out, key, nil)
+		isok(t, err)
+		io.WriteString(plaintext, body)
+		isok(t, plaintext.Close())
+		return out.Bytes()
+	}
+	keyring := openpgp.EntityList{trusted}
+
+	/* The - needs dash-escaping in the armor */
+	signed := sign(trusted.PrivateKey, "Origin: Debian\nSuite: unstable\nDescription: Debian x.y Unstable - Not Released\n-Not: a field\n")
+	assert(t, bytes.Contains(signed, []byte("\n- -Not: a field")))
+
+	release := control.Release{}
+	isok(t, control.DecodeClearsigned(bytes.NewReader(signed), keyring, &release))
+	assert(t, release.Origin == "Debian")
+	assert(t, release.Suite == "unstable")
+	assert(t, release.Values["-Not"] == "a field")
+
+	/* Signed by someone we don't know */
+	err := control.DecodeClearsigned(bytes.NewReader(sign(untrusted.PrivateKey, "Origin: Debian\n")), keyring, &release)
+	sigErr, ok := err.(control.SignatureError)
+	assert(t, ok)
+	assert(t, len(sigErr.Results) == 1)
+	assert(t, !sigErr.Results[0].Valid)
+
+	/* Not signed at all */
+	err = control.DecodeClearsigned(bytes.NewReader([]byte("Origin: Debian\n")), keyring, &release)
+	_, ok = err.(control.SignatureError)
+	assert(t, ok)
+
+	/* A good signature on a broken file is a different problem */
+	err = control.DecodeClearsigned(bytes.NewReader(sign(trusted.PrivateKey, "Origin Debian\n")), keyring, &release)
+	notok(t, err)
+	_, ok = err.(control.SignatureError)
+	assert(t, !ok)
+}
+
+// vim: foldmethod=marker
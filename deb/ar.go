@@ -0,0 +1,115 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// An ArEntry is a single member of an ar(1) archive, such as the
+// debian-binary, control.tar.gz or data.tar.xz members of a .deb.
+type ArEntry struct {
+	Name      string
+	Timestamp int64
+	OwnerID   int64
+	GroupID   int64
+	FileMode  string
+	Size      int64
+	Data      *io.SectionReader
+}
+
+// An Ar is an ar(1) archive, read member by member with Next.
+type Ar struct {
+	in     io.ReaderAt
+	offset int64
+}
+
+const (
+	arMagic      = "!<arch>\n"
+	arHeaderSize = 60
+)
+
+// Given an io.ReaderAt, check the ar(1) magic and return an Ar positioned
+// at the first member.
+func LoadAr(in io.ReaderAt) (*Ar, error) {
+	magic := make([]byte, len(arMagic))
+	if _, err := in.ReadAt(magic, 0); err != nil {
+		return nil, err
+	}
+	if string(magic) != arMagic {
+		return nil, fmt.Errorf("Not an ar archive: bad magic %q", magic)
+	}
+	return &Ar{in: in, offset: int64(len(arMagic))}, nil
+}
+
+func parseArField(header []byte, start, length, base int) (int64, error) {
+	value := strings.TrimSpace(string(header[start : start+length]))
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, base, 64)
+}
+
+// Return the next member of the archive, or io.EOF once there are none
+// left.
+func (a *Ar) Next() (*ArEntry, error) {
+	header := make([]byte, arHeaderSize)
+	n, err := a.in.ReadAt(header, a.offset)
+	if n == 0 && err == io.EOF {
+		return nil, io.EOF
+	} else if n != arHeaderSize {
+		return nil, fmt.Errorf("Truncated ar member header at offset %d", a.offset)
+	}
+
+	if string(header[58:60]) != "`\n" {
+		return nil, fmt.Errorf("Bad ar member header at offset %d", a.offset)
+	}
+
+	entry := ArEntry{
+		Name:     strings.TrimRight(strings.TrimSpace(string(header[0:16])), "/"),
+		FileMode: strings.TrimSpace(string(header[40:48])),
+	}
+
+	if entry.Timestamp, err = parseArField(header, 16, 12, 10); err != nil {
+		return nil, err
+	}
+	if entry.OwnerID, err = parseArField(header, 28, 6, 10); err != nil {
+		return nil, err
+	}
+	if entry.GroupID, err = parseArField(header, 34, 6, 10); err != nil {
+		return nil, err
+	}
+	if entry.Size, err = parseArField(header, 48, 10, 10); err != nil {
+		return nil, err
+	}
+
+	start := a.offset + arHeaderSize
+	entry.Data = io.NewSectionReader(a.in, start, entry.Size)
+
+	/* Members are aligned to an even offset */
+	a.offset = start + entry.Size + entry.Size%2
+	return &entry, nil
+}
+
+// vim: foldmethod=marker
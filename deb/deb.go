@@ -0,0 +1,263 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb
+
+import (
+	"archive/tar"
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"pault.ag/go/debian/control"
+)
+
+// A Deb is a Debian binary package, as built by dpkg-deb(1). The Control
+// member holds the parsed DEBIAN/control file of the package.
+type Deb struct {
+	Control control.BinaryIndex
+
+	in      io.ReaderAt
+	size    int64
+	members []*ArEntry
+
+	formatVersion string
+	controlLimit  int64
+}
+
+// The most the control.tar member of a .deb may decompress to when it's
+// opened by Load. Control members are small (the md5sums file of even the
+// biggest packages is a couple of MiB), so anything past this is most
+// likely a decompression bomb.
+const DefaultControlSizeLimit = 32 << 20
+
+// Given an io.ReaderAt and the size of the .deb file behind it, read the
+// ar(1) members and parse the control file. The control.tar member may
+// not decompress to more than DefaultControlSizeLimit bytes.
+func Load(in io.ReaderAt, size int64) (*Deb, error) {
+	return LoadWithControlLimit(in, size, DefaultControlSizeLimit)
+}
+
+// Just like Load, but with a limit on how many bytes the control.tar member
+// may decompress to, which is worth lowering when handling untrusted
+// uploads. Reading past the limit is an error. A limit of 0 (or less)
+// means there's no limit at all.
+func LoadWithControlLimit(in io.ReaderAt, size int64, limit int64) (*Deb, error) {
+	ar, err := LoadAr(in)
+	if err != nil {
+		return nil, err
+	}
+
+	deb := Deb{in: in, size: size, controlLimit: limit}
+	for {
+		member, err := ar.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		deb.members = append(deb.members, member)
+	}
+
+	if len(deb.members) == 0 || deb.members[0].Name != "debian-binary" {
+		return nil, fmt.Errorf("First member of a .deb must be debian-binary")
+	}
+
+	debianBinary := deb.members[0]
+	formatVersion, err := ioutil.ReadAll(io.NewSectionReader(debianBinary.Data, 0, debianBinary.Size))
+	if err != nil {
+		return nil, err
+	}
+	deb.formatVersion = strings.TrimSpace(string(formatVersion))
+	if !strings.HasPrefix(deb.formatVersion, "2.") {
+		return nil, fmt.Errorf("Unsupported .deb format version '%s'", deb.formatVersion)
+	}
+
+	controlFile, err := deb.openControlFile("control")
+	if err != nil {
+		return nil, err
+	}
+	if err := control.Unmarshal(&deb.Control, controlFile); err != nil {
+		return nil, err
+	}
+
+	return &deb, nil
+}
+
+// Given a path on the filesystem, open the .deb and Load it. The returned
+// io.Closer must be closed once the Deb is no longer in use.
+func LoadFile(path string) (*Deb, io.Closer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	deb, err := Load(f, stat.Size())
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return deb, f, nil
+}
+
+// Return the .deb format version, as read out of the debian-binary
+// member, such as "2.0".
+func (deb *Deb) FormatVersion() string {
+	return deb.formatVersion
+}
+
+// Return the size of the .deb file, in bytes.
+func (deb *Deb) Size() int64 {
+	return deb.size
+}
+
+// Return an io.Reader over the whole .deb file.
+func (deb *Deb) Reader() io.Reader {
+	return io.NewSectionReader(deb.in, 0, deb.size)
+}
+
+func (deb *Deb) member(prefix string) *ArEntry {
+	for _, member := range deb.members {
+		if member.Name == prefix || strings.HasPrefix(member.Name, prefix+".") {
+			return member
+		}
+	}
+	return nil
+}
+
+// A sizeLimitedReader is an io.LimitReader that returns an error rather
+// than io.EOF once more than limit bytes have been read.
+type sizeLimitedReader struct {
+	reader    io.Reader
+	name      string
+	limit     int64
+	remaining int64
+}
+
+func (l *sizeLimitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, fmt.Errorf("Member %s decompresses to more than %d bytes", l.name, l.limit)
+	}
+	/* Read one past the end, to tell if there's anything after it */
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.reader.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n + int(l.remaining), fmt.Errorf("Member %s decompresses to more than %d bytes", l.name, l.limit)
+	}
+	return n, err
+}
+
+func (deb *Deb) openTar(name string, limit int64) (*tar.Reader, error) {
+	member := deb.member(name)
+	if member == nil {
+		return nil, fmt.Errorf("No %s member in .deb", name)
+	}
+
+	reader, err := decompressMember(member)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 {
+		reader = &sizeLimitedReader{reader: reader, name: member.Name, limit: limit, remaining: limit}
+	}
+	return tar.NewReader(reader), nil
+}
+
+// Return a tar.Reader over the data.tar member of the .deb, which holds
+// the files that get installed onto the system.
+func (deb *Deb) Data() (*tar.Reader, error) {
+	return deb.openTar("data.tar", 0)
+}
+
+func (deb *Deb) openControlFile(name string) (io.Reader, error) {
+	reader, err := deb.findControlFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if reader == nil {
+		return nil, fmt.Errorf("No %s file in control.tar", name)
+	}
+	return reader, nil
+}
+
+/* Like openControlFile, but a missing file gives a nil io.Reader rather
+ * than an error */
+func (deb *Deb) findControlFile(name string) (io.Reader, error) {
+	reader, err := deb.openTar("control.tar", deb.controlLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if path.Clean(header.Name) == name {
+			return reader, nil
+		}
+	}
+}
+
+// Return the md5sums control file of the .deb, as a map of each installed
+// path (relative to /, as in "usr/bin/fbautostart") to its md5 hash. If
+// the .deb has no md5sums file, the map is empty.
+func (deb *Deb) MD5Sums() (map[string]string, error) {
+	ret := map[string]string{}
+	reader, err := deb.findControlFile("md5sums")
+	if err != nil || reader == nil {
+		return ret, err
+	}
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		/* The path may have spaces in it, so only split off the hash */
+		els := strings.SplitN(line, " ", 2)
+		if len(els) != 2 || strings.TrimSpace(els[1]) == "" {
+			return nil, fmt.Errorf("Malformed md5sums line: '%s'", line)
+		}
+		ret[strings.TrimLeft(els[1], " ")] = els[0]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// vim: foldmethod=marker
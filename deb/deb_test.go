@@ -0,0 +1,280 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+
+	"pault.ag/go/debian/deb"
+)
+
+/*
+ *
+ */
+
+func isok(t *testing.T, err error) {
+	if err != nil {
+		log.Printf("Error! Error is not nil! - %s\n", err)
+		t.FailNow()
+	}
+}
+
+func notok(t *testing.T, err error) {
+	if err == nil {
+		log.Printf("Error! Error is nil!\n")
+		t.FailNow()
+	}
+}
+
+func assert(t *testing.T, expr bool) {
+	if !expr {
+		log.Printf("Assertion failed!")
+		t.FailNow()
+	}
+}
+
+/*
+ *
+ */
+
+// Test fixtures {{{
+
+type testFile struct {
+	Name string
+	Body string
+}
+
+func buildAr(files ...testFile) []byte {
+	out := bytes.Buffer{}
+	out.WriteString("!<arch>\n")
+	for _, file := range files {
+		fmt.Fprintf(&out, "%-16s%-12d%-6d%-6d%-8s%-10d`\n",
+			file.Name, 1445000000, 0, 0, "100644", len(file.Body))
+		out.WriteString(file.Body)
+		if len(file.Body)%2 == 1 {
+			out.WriteString("\n")
+		}
+	}
+	return out.Bytes()
+}
+
+func buildTar(files ...testFile) string {
+	out := bytes.Buffer{}
+	writer := tar.NewWriter(&out)
+	for _, file := range files {
+		writer.WriteHeader(&tar.Header{
+			Name:     file.Name,
+			Mode:     0644,
+			Size:     int64(len(file.Body)),
+			Typeflag: tar.TypeReg,
+		})
+		writer.Write([]byte(file.Body))
+	}
+	writer.Close()
+	return out.String()
+}
+
+func buildTarGz(files ...testFile) string {
+	out := bytes.Buffer{}
+	gz := gzip.NewWriter(&out)
+	gz.Write([]byte(buildTar(files...)))
+	gz.Close()
+	return out.String()
+}
+
+const testControl = `Package: fbautostart
+Version: 2.718281828-1
+Architecture: amd64
+Maintainer: Paul Tagliamonte <paultag@ubuntu.com>
+Installed-Size: 51
+Depends: libc6 (>= 2.4)
+Section: misc
+Priority: optional
+Description: XDG compliant autostarting app for Fluxbox
+ The fbautostart app was designed to have little to no overhead, while
+ still maintaining the needed functionality of launching applications
+ according to the XDG spec.
+`
+
+func buildDeb(controlFile string, controlFiles ...testFile) []byte {
+	return buildAr(
+		testFile{"debian-binary", "2.0\n"},
+		testFile{"control.tar.gz", buildTarGz(
+			append([]testFile{{"./control", controlFile}}, controlFiles...)...,
+		)},
+		testFile{"data.tar.gz", buildTarGz(
+			testFile{"./usr/bin/fbautostart", string(make([]byte, 2000))},
+			testFile{"./usr/share/doc/fbautostart/copyright", "Public Domain\n"},
+		)},
+	)
+}
+
+// }}}
+
+func TestArParse(t *testing.T) {
+	ar, err := deb.LoadAr(bytes.NewReader(buildAr(
+		testFile{"debian-binary", "2.0\n"},
+		testFile{"odd", "abc"},
+		testFile{"even", "ab"},
+	)))
+	isok(t, err)
+
+	for _, expected := range []testFile{
+		{"debian-binary", "2.0\n"},
+		{"odd", "abc"},
+		{"even", "ab"},
+	} {
+		member, err := ar.Next()
+		isok(t, err)
+		assert(t, member.Name == expected.Name)
+		assert(t, member.Size == int64(len(expected.Body)))
+		assert(t, member.FileMode == "100644")
+		body, err := ioutil.ReadAll(member.Data)
+		isok(t, err)
+		assert(t, string(body) == expected.Body)
+	}
+
+	_, err = ar.Next()
+	assert(t, err.Error() == "EOF")
+
+	_, err = deb.LoadAr(bytes.NewReader([]byte("!<notar>\n")))
+	notok(t, err)
+}
+
+func TestDebLoad(t *testing.T) {
+	data := buildDeb(testControl)
+	debFile, err := deb.Load(bytes.NewReader(data), int64(len(data)))
+	isok(t, err)
+
+	assert(t, debFile.Size() == int64(len(data)))
+	assert(t, debFile.FormatVersion() == "2.0")
+	assert(t, debFile.Control.Package == "fbautostart")
+	assert(t, debFile.Control.Version.Revision == "1")
+	assert(t, debFile.Control.Architecture.CPU == "amd64")
+	depends := debFile.Control.GetDepends()
+	assert(t, depends.Relations[0].Possibilities[0].Name == "libc6")
+
+	files, err := debFile.Data()
+	isok(t, err)
+	header, err := files.Next()
+	isok(t, err)
+	assert(t, header.Name == "./usr/bin/fbautostart")
+}
+
+func TestDebLoadNotDeb(t *testing.T) {
+	data := buildAr(testFile{"control.tar.gz", buildTarGz()})
+	_, err := deb.Load(bytes.NewReader(data), int64(len(data)))
+	notok(t, err)
+}
+
+func TestDebLoadFormatVersion(t *testing.T) {
+	for _, tc := range []struct {
+		version string
+		ok      bool
+	}{
+		{"2.0\n", true},
+		{"2.1\n", true},
+		{"3.0\n", false},
+		{"0.939000\n", false},
+		{"", false},
+	} {
+		data := buildAr(
+			testFile{"debian-binary", tc.version},
+			testFile{"control.tar.gz", buildTarGz(testFile{"./control", testControl})},
+			testFile{"data.tar.gz", buildTarGz()},
+		)
+		debFile, err := deb.Load(bytes.NewReader(data), int64(len(data)))
+		if !tc.ok {
+			notok(t, err)
+			continue
+		}
+		isok(t, err)
+		assert(t, debFile.FormatVersion()+"\n" == tc.version)
+	}
+}
+
+func TestDebLoadControlLimit(t *testing.T) {
+	bomb := testFile{"./md5sums", string(make([]byte, 1<<20))}
+	data := buildAr(
+		testFile{"debian-binary", "2.0\n"},
+		testFile{"control.tar.gz", buildTarGz(bomb, testFile{"./control", testControl})},
+		testFile{"data.tar.gz", buildTarGz()},
+	)
+
+	/* The default limit is plenty for this */
+	debFile, err := deb.Load(bytes.NewReader(data), int64(len(data)))
+	isok(t, err)
+	assert(t, debFile.Control.Package == "fbautostart")
+
+	_, err = deb.LoadWithControlLimit(bytes.NewReader(data), int64(len(data)), 64<<10)
+	notok(t, err)
+	assert(t, strings.Contains(err.Error(), "more than 65536 bytes"))
+
+	_, err = deb.LoadWithControlLimit(bytes.NewReader(data), int64(len(data)), 0)
+	isok(t, err)
+
+	/* The limit is on the control member only */
+	data = buildDeb(testControl)
+	debFile, err = deb.LoadWithControlLimit(bytes.NewReader(data), int64(len(data)), 10<<10)
+	isok(t, err)
+	files, err := debFile.Data()
+	isok(t, err)
+	_, err = files.Next()
+	isok(t, err)
+}
+
+func TestDebMD5Sums(t *testing.T) {
+	data := buildDeb(testControl, testFile{"./md5sums", `5d41402abc4b2a76b9719d911017c592  usr/bin/fbautostart
+7d793037a0760186574b0282f2f435e7  usr/share/doc/fbautostart/a file with spaces
+`})
+	debFile, err := deb.Load(bytes.NewReader(data), int64(len(data)))
+	isok(t, err)
+
+	sums, err := debFile.MD5Sums()
+	isok(t, err)
+	assert(t, len(sums) == 2)
+	assert(t, sums["usr/bin/fbautostart"] == "5d41402abc4b2a76b9719d911017c592")
+	assert(t, sums["usr/share/doc/fbautostart/a file with spaces"] == "7d793037a0760186574b0282f2f435e7")
+
+	/* No md5sums at all is fine, too */
+	data = buildDeb(testControl)
+	debFile, err = deb.Load(bytes.NewReader(data), int64(len(data)))
+	isok(t, err)
+	sums, err = debFile.MD5Sums()
+	isok(t, err)
+	assert(t, sums != nil)
+	assert(t, len(sums) == 0)
+
+	data = buildDeb(testControl, testFile{"./md5sums", "5d41402abc4b2a76b9719d911017c592\n"})
+	debFile, err = deb.Load(bytes.NewReader(data), int64(len(data)))
+	isok(t, err)
+	_, err = debFile.MD5Sums()
+	notok(t, err)
+}
+
+// vim: foldmethod=marker
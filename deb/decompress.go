@@ -0,0 +1,65 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb
+
+import (
+	"fmt"
+	"io"
+	"path"
+
+	"pault.ag/go/debian/internal/decompress"
+)
+
+// A Decompressor turns the (compressed) contents of a .deb member, such
+// as data.tar.gz, into a reader over the uncompressed tarball. It's the
+// same type as control.Decompressor.
+type Decompressor = decompress.Decompressor
+
+// DecompressorFunc adapts a plain function into a Decompressor. It's the
+// same type as control.DecompressorFunc.
+type DecompressorFunc = decompress.Func
+
+// Register a Decompressor for members ending in the given suffix (such as
+// ".zst"), replacing any existing codec for it. gzip, bzip2 and xz are
+// supported out of the box. This is the same registry the control package
+// uses for compressed indexes, so a codec registered here (or with
+// control.RegisterDecompressor) works for both.
+func RegisterDecompressor(suffix string, decompressor Decompressor) {
+	decompress.Register(suffix, decompressor)
+}
+
+func decompressMember(member *ArEntry) (io.Reader, error) {
+	/* A reader of our own, so members can be read more than once */
+	data := io.NewSectionReader(member.Data, 0, member.Size)
+
+	suffix := path.Ext(member.Name)
+	if suffix == ".tar" {
+		return data, nil
+	}
+
+	decompressor, ok := decompress.Lookup(suffix)
+	if !ok {
+		return nil, fmt.Errorf("Unsupported compression on member %s", member.Name)
+	}
+	return decompressor.Decompress(data)
+}
+
+// vim: foldmethod=marker
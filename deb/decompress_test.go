@@ -0,0 +1,119 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+
+	"pault.ag/go/debian/control"
+	"pault.ag/go/debian/deb"
+)
+
+/*
+ *
+ */
+
+// Test data.tar.xz {{{
+const testDataTarXz = `/Td6WFoAAATm1rRGAgAhARwAAAAQz1jM4Cf/AHldABcLyyeJ8u0IBPB46o0CGSTz
+Hl20OY0Oq/IPm3uDcNnjM93k6jZ50WR7zzLW2A4Xf7YOAtWdFQgXlrv/rfZHJTDj
+dbWheNXihe0n26U28z3o5uCN2xAsZz2+BVi5OYotdkebXDSY01upezalpRj9hrht
+cdEXuM+hAAAAAAAA0KSxQXrYO4oAAZUBgFAAABbiezKxxGf7AgAAAAAEWVo=`
+
+// }}}
+
+func TestXzMember(t *testing.T) {
+	dataTarXz, err := base64.StdEncoding.DecodeString(strings.Replace(testDataTarXz, "\n", "", -1))
+	isok(t, err)
+	data := buildAr(
+		testFile{"debian-binary", "2.0\n"},
+		testFile{"control.tar.gz", buildTarGz(testFile{"./control", testControl})},
+		testFile{"data.tar.xz", string(dataTarXz)},
+	)
+	debFile, err := deb.Load(bytes.NewReader(data), int64(len(data)))
+	isok(t, err)
+
+	files, err := debFile.Data()
+	isok(t, err)
+	header, err := files.Next()
+	isok(t, err)
+	assert(t, header.Name == "./usr/bin/fbautostart")
+}
+
+func TestRegisterDecompressor(t *testing.T) {
+	data := buildAr(
+		testFile{"debian-binary", "2.0\n"},
+		testFile{"control.tar.gz", buildTarGz(testFile{"./control", testControl})},
+		testFile{"data.tar.fake", "FAKE" + buildTar(
+			testFile{"./usr/bin/fbautostart", "#!/bin/sh\n"},
+		)},
+	)
+	debFile, err := deb.Load(bytes.NewReader(data), int64(len(data)))
+	isok(t, err)
+
+	_, err = debFile.Data()
+	notok(t, err)
+
+	deb.RegisterDecompressor(".fake", deb.DecompressorFunc(func(in io.Reader) (io.Reader, error) {
+		magic := make([]byte, 4)
+		if _, err := io.ReadFull(in, magic); err != nil {
+			return nil, err
+		}
+		if string(magic) != "FAKE" {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return in, nil
+	}))
+
+	files, err := debFile.Data()
+	isok(t, err)
+	header, err := files.Next()
+	isok(t, err)
+	assert(t, header.Name == "./usr/bin/fbautostart")
+}
+
+func TestSharedDecompressor(t *testing.T) {
+	data := buildAr(
+		testFile{"debian-binary", "2.0\n"},
+		testFile{"control.tar.gz", buildTarGz(testFile{"./control", testControl})},
+		testFile{"data.tar.shared", buildTar(
+			testFile{"./usr/bin/fbautostart", "#!/bin/sh\n"},
+		)},
+	)
+	debFile, err := deb.Load(bytes.NewReader(data), int64(len(data)))
+	isok(t, err)
+
+	/* Registered for control, which is the same registry */
+	control.RegisterDecompressor(".shared", control.DecompressorFunc(func(in io.Reader) (io.Reader, error) {
+		return in, nil
+	}))
+
+	files, err := debFile.Data()
+	isok(t, err)
+	header, err := files.Next()
+	isok(t, err)
+	assert(t, header.Name == "./usr/bin/fbautostart")
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,6 @@
+/*
+
+Read .deb binary package files
+
+*/
+package deb
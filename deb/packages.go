@@ -0,0 +1,112 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb
+
+import (
+	"archive/tar"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strconv"
+
+	"pault.ag/go/debian/control"
+)
+
+// Compute the Installed-Size of the package from the data.tar member, in
+// KiB. Each regular file is rounded up to the next KiB, and everything
+// else (directories, symlinks, ...) counts as 1 KiB, the same way
+// dpkg-gencontrol(1) does it.
+func (deb *Deb) installedSize() (int64, error) {
+	data, err := deb.Data()
+	if err != nil {
+		return 0, err
+	}
+
+	var size int64
+	for {
+		header, err := data.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, err
+		}
+
+		if header.Typeflag == tar.TypeReg {
+			size += (header.Size + 1023) / 1024
+		} else {
+			size += 1
+		}
+	}
+	return size, nil
+}
+
+// Given a Deb, and the path the .deb will have relative to the root of the
+// archive (such as pool/main/f/fbautostart/fbautostart_2.718281828-1_amd64.deb),
+// return the BinaryIndex as it would be written out to the Packages file.
+//
+// The control stanza of the .deb is used as-is, with Filename, Size,
+// MD5sum, SHA1 and SHA256 computed from the .deb itself. If the control
+// file has no Installed-Size, it's computed from the data.tar member.
+func PackagesEntry(deb *Deb, poolPath string) (control.BinaryIndex, error) {
+	entry := deb.Control
+
+	/* Don't scribble all over the Deb's own Paragraph */
+	entry.Paragraph = control.Paragraph{
+		Values: map[string]string{},
+		Order:  []string{},
+	}
+	for _, key := range deb.Control.Order {
+		entry.Paragraph.Set(key, deb.Control.Values[key])
+	}
+
+	if entry.InstalledSize == "" {
+		size, err := deb.installedSize()
+		if err != nil {
+			return control.BinaryIndex{}, err
+		}
+		entry.InstalledSize = strconv.FormatInt(size, 10)
+		entry.Paragraph.Set("Installed-Size", entry.InstalledSize)
+	}
+
+	md5sum, sha1sum, sha256sum := md5.New(), sha1.New(), sha256.New()
+	size, err := io.Copy(io.MultiWriter(md5sum, sha1sum, sha256sum), deb.Reader())
+	if err != nil {
+		return control.BinaryIndex{}, err
+	}
+
+	entry.Filename = poolPath
+	entry.Size = strconv.FormatInt(size, 10)
+	entry.MD5sum = hex.EncodeToString(md5sum.Sum(nil))
+	entry.SHA1 = hex.EncodeToString(sha1sum.Sum(nil))
+	entry.SHA256 = hex.EncodeToString(sha256sum.Sum(nil))
+
+	entry.Paragraph.Set("Filename", entry.Filename)
+	entry.Paragraph.Set("Size", entry.Size)
+	entry.Paragraph.Set("MD5sum", entry.MD5sum)
+	entry.Paragraph.Set("SHA1", entry.SHA1)
+	entry.Paragraph.Set("SHA256", entry.SHA256)
+
+	return entry, nil
+}
+
+// vim: foldmethod=marker
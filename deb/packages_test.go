@@ -0,0 +1,80 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package deb_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"testing"
+
+	"pault.ag/go/debian/deb"
+)
+
+func TestPackagesEntry(t *testing.T) {
+	data := buildDeb(testControl)
+	debFile, err := deb.Load(bytes.NewReader(data), int64(len(data)))
+	isok(t, err)
+
+	poolPath := "pool/main/f/fbautostart/fbautostart_2.718281828-1_amd64.deb"
+	entry, err := deb.PackagesEntry(debFile, poolPath)
+	isok(t, err)
+
+	sum := sha256.Sum256(data)
+	assert(t, entry.Package == "fbautostart")
+	assert(t, entry.Filename == poolPath)
+	assert(t, entry.Size == strconv.Itoa(len(data)))
+	assert(t, entry.SHA256 == hex.EncodeToString(sum[:]))
+	assert(t, len(entry.MD5sum) == 32)
+	assert(t, len(entry.SHA1) == 40)
+	assert(t, entry.InstalledSize == "51")
+
+	/* The Paragraph is updated too, so it can be written out directly */
+	assert(t, entry.Values["Filename"] == poolPath)
+	assert(t, entry.Values["SHA256"] == entry.SHA256)
+	assert(t, entry.Values["Depends"] == "libc6 (>= 2.4)")
+	out := bytes.Buffer{}
+	_, err = entry.WriteTo(&out)
+	isok(t, err)
+	assert(t, strings.HasPrefix(out.String(), "Package: fbautostart\n"))
+	assert(t, strings.Contains(out.String(), "\nFilename: "+poolPath+"\n"))
+
+	/* ... but the Deb's own Paragraph is left alone */
+	_, ok := debFile.Control.Values["Filename"]
+	assert(t, !ok)
+}
+
+func TestPackagesEntryInstalledSize(t *testing.T) {
+	data := buildDeb(strings.Replace(testControl, "Installed-Size: 51\n", "", 1))
+	debFile, err := deb.Load(bytes.NewReader(data), int64(len(data)))
+	isok(t, err)
+
+	entry, err := deb.PackagesEntry(debFile, "pool/main/f/fbautostart/fbautostart.deb")
+	isok(t, err)
+
+	/* 2000 bytes rounds up to 2 KiB, the copyright to 1 KiB */
+	assert(t, entry.InstalledSize == "3")
+	assert(t, entry.Values["Installed-Size"] == "3")
+}
+
+// vim: foldmethod=marker
@@ -35,17 +35,19 @@ type Arch struct {
 
 func (a Arch) String() string {
 	/* ABI-OS-CPU -- gnu-linux-amd64 */
-	els := []string{}
-	if a.ABI != "any" && a.ABI != "all" && a.ABI != "gnu" {
-		els = append(els, a.ABI)
+	if a.ABI == a.OS && a.OS == a.CPU && (a.CPU == "any" || a.CPU == "all") {
+		return a.CPU
 	}
 
-	if a.OS != "any" && a.OS != "all" && a.OS != "linux" {
-		els = append(els, a.OS)
+	if a.ABI == "gnu" && a.OS == "linux" && a.CPU != "any" {
+		return a.CPU
 	}
 
-	els = append(els, a.CPU)
-	return strings.Join(els, "-")
+	if a.ABI == "gnu" || a.ABI == "any" {
+		return a.OS + "-" + a.CPU
+	}
+
+	return a.ABI + "-" + a.OS + "-" + a.CPU
 }
 
 func ParseArchitectures(arch string) ([]Arch, error) {
@@ -71,6 +73,10 @@ func (arch *Arch) UnmarshalControl(data string) error {
 	return parseArchInto(arch, data)
 }
 
+func (arch Arch) MarshalControl() (string, error) {
+	return arch.String(), nil
+}
+
 func ParseArch(arch string) (*Arch, error) {
 	ret := &Arch{
 		ABI: "any",
@@ -86,8 +92,12 @@ func parseArchInto(ret *Arch, arch string) error {
 	/* May be in the following form:
 	 * `any` (implicitly any-any-any)
 	 * kfreebsd-any (implicitly any-kfreebsd-any)
-	 * kfreebsd-amd64 (implicitly any-kfreebsd-any)
-	 * bsd-openbsd-i386 */
+	 * any-amd64 (implicitly any-any-amd64)
+	 * kfreebsd-amd64 (implicitly gnu-kfreebsd-amd64)
+	 * bsd-openbsd-i386, musl-linux-any, any-linux-amd64
+	 *
+	 * Any of the ABI, OS or CPU may be `any`, which matches whatever is in
+	 * that position of the arch it's compared against. */
 	flavors := strings.SplitN(arch, "-", 3)
 	switch len(flavors) {
 	case 1:
@@ -107,9 +117,13 @@ func parseArchInto(ret *Arch, arch string) error {
 		}
 	case 2:
 		/* Right, this is something like kfreebsd-amd64, which is implicitly
-		 * gnu-kfreebsd-amd64 */
+		 * gnu-kfreebsd-amd64, or a wildcard like linux-any or any-amd64,
+		 * where the ABI is left as any, just like dpkg does. */
 		ret.OS = flavors[0]
 		ret.CPU = flavors[1]
+		if ret.OS != "any" && ret.CPU != "any" {
+			ret.ABI = "gnu"
+		}
 	case 3:
 		/* This is something like bsd-openbsd-amd64 */
 		ret.ABI = flavors[0]
@@ -144,23 +158,45 @@ func (set *ArchSet) Matches(other *Arch) bool {
 	return not
 }
 
-/*
- */
-func (arch *Arch) IsWildcard() bool {
-	if arch.CPU == "all" {
+// Check to see if this Arch is a wildcard, such as `any`, `linux-any`,
+// `any-amd64` or `musl-linux-any`, which stands in for a set of concrete
+// architectures. Any of the ABI, OS or CPU may be `any`.
+//
+// Neither `all` nor `source` are wildcards (or concrete, see IsConcrete);
+// they're special values that don't name a machine at all, and only match
+// themselves.
+func (arch Arch) IsWildcard() bool {
+	if arch.isSpecial() {
 		return false
 	}
 
-	if arch.ABI == "any" || arch.OS == "any" || arch.CPU == "any" {
-		return true
-	}
-	return false
+	return arch.ABI == "any" || arch.OS == "any" || arch.CPU == "any"
 }
 
-/*
- */
+// Check to see if this Arch names a single real architecture, such as
+// `amd64` or `kfreebsd-i386`. This is false for wildcards, as well as for
+// `all` and `source`.
+func (arch Arch) IsConcrete() bool {
+	return !arch.isSpecial() && !arch.IsWildcard()
+}
+
+func (arch Arch) isSpecial() bool {
+	return arch.CPU == "all" || arch.CPU == "source"
+}
+
+// Check to see if this Arch and the other one are the same architecture, or
+// if one of them is a wildcard, that the other one is matched by it. Each
+// of the ABI, OS and CPU are matched on their own, so `any-amd64` is
+// matched by `amd64` as well as by `kfreebsd-amd64`, and `musl-linux-any`
+// by `musl-linux-arm64`, but not by `arm64`, which is gnu-linux-arm64.
 func (arch *Arch) Is(other *Arch) bool {
 
+	if arch.isSpecial() || other.isSpecial() {
+		/* all and source aren't matched by any wildcard, only by
+		 * themselves. */
+		return *arch == *other
+	}
+
 	if arch.IsWildcard() && other.IsWildcard() {
 		/* We can't compare wildcards to other wildcards. That's just
 		 * insanity. We always need a concrete arch. Not even going to try. */
@@ -115,4 +115,121 @@ func TestArchString(t *testing.T) {
 	}
 }
 
+func TestArchStringRoundTrip(t *testing.T) {
+	for _, el := range []string{
+		"all", "any", "amd64", "linux-any", "kfreebsd-any",
+		"kfreebsd-amd64", "any-amd64", "musl-linux-any", "bsd-windows-i386",
+	} {
+		arch, err := dependency.ParseArch(el)
+		isok(t, err)
+		if arch.String() != el {
+			t.Errorf("%q: got %q back", el, arch.String())
+		}
+	}
+}
+
+func TestArchClassification(t *testing.T) {
+	for _, tc := range []struct {
+		arch     string
+		wildcard bool
+		concrete bool
+	}{
+		{"amd64", false, true},
+		{"kfreebsd-i386", false, true},
+		{"bsd-openbsd-amd64", false, true},
+		{"any", true, false},
+		{"linux-any", true, false},
+		{"any-amd64", true, false},
+		{"musl-linux-any", true, false},
+		{"all", false, false},
+		{"source", false, false},
+	} {
+		arch, err := dependency.ParseArch(tc.arch)
+		isok(t, err)
+		if arch.IsWildcard() != tc.wildcard {
+			t.Errorf("%q: IsWildcard() = %t", tc.arch, arch.IsWildcard())
+		}
+		if arch.IsConcrete() != tc.concrete {
+			t.Errorf("%q: IsConcrete() = %t", tc.arch, arch.IsConcrete())
+		}
+	}
+}
+
+func TestArchWildcardPositions(t *testing.T) {
+	for _, tc := range []struct {
+		wildcard string
+		matches  []string
+		misses   []string
+	}{
+		{"any-amd64",
+			[]string{"amd64", "kfreebsd-amd64", "musl-linux-amd64", "bsd-openbsd-amd64"},
+			[]string{"i386", "kfreebsd-i386", "musl-linux-arm64", "all"}},
+		{"linux-any",
+			[]string{"amd64", "arm64", "musl-linux-amd64", "gnu-linux-i386"},
+			[]string{"kfreebsd-amd64", "hurd-i386", "all", "source"}},
+		{"musl-linux-any",
+			[]string{"musl-linux-amd64", "musl-linux-arm64"},
+			[]string{"amd64", "arm64", "gnu-linux-i386", "musl-kfreebsd-amd64"}},
+		{"any-linux-amd64",
+			[]string{"amd64", "musl-linux-amd64"},
+			[]string{"kfreebsd-amd64", "musl-linux-arm64"}},
+	} {
+		wildcard, err := dependency.ParseArch(tc.wildcard)
+		isok(t, err)
+		assert(t, wildcard.IsWildcard())
+
+		for _, el := range tc.matches {
+			arch, err := dependency.ParseArch(el)
+			isok(t, err)
+			if !wildcard.Is(arch) || !arch.Is(wildcard) {
+				t.Errorf("%q should match %q", tc.wildcard, el)
+			}
+		}
+		for _, el := range tc.misses {
+			arch, err := dependency.ParseArch(el)
+			isok(t, err)
+			if wildcard.Is(arch) || arch.Is(wildcard) {
+				t.Errorf("%q should not match %q", tc.wildcard, el)
+			}
+		}
+	}
+}
+
+func TestArchConcreteTwoPart(t *testing.T) {
+	arch, err := dependency.ParseArch("kfreebsd-i386")
+	isok(t, err)
+	assert(t, arch.ABI == "gnu")
+
+	other, err := dependency.ParseArch("gnu-kfreebsd-i386")
+	isok(t, err)
+	assert(t, arch.Is(other))
+
+	wildcard, err := dependency.ParseArch("kfreebsd-any")
+	isok(t, err)
+	assert(t, arch.Is(wildcard))
+	assert(t, wildcard.Is(arch))
+}
+
+func TestArchSpecialMatching(t *testing.T) {
+	parse := func(arch string) *dependency.Arch {
+		ret, err := dependency.ParseArch(arch)
+		isok(t, err)
+		return ret
+	}
+
+	assert(t, parse("linux-any").Is(parse("amd64")))
+	assert(t, !parse("kfreebsd-any").Is(parse("amd64")))
+
+	/* all and source only ever match themselves */
+	assert(t, parse("all").Is(parse("all")))
+	assert(t, parse("source").Is(parse("source")))
+	assert(t, !parse("all").Is(parse("source")))
+	for _, el := range []string{"any", "linux-any", "any-all", "amd64"} {
+		assert(t, !parse(el).Is(parse("all")))
+		assert(t, !parse("all").Is(parse(el)))
+		assert(t, !parse(el).Is(parse("source")))
+		assert(t, !parse("source").Is(parse(el)))
+	}
+}
+
 // vim: foldmethod=marker
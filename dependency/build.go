@@ -0,0 +1,95 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency
+
+// Helpers to put together a Dependency in code, without having to write it
+// out as a string and parse it back in. For example:
+//
+//   dep := dependency.And(
+//       dependency.New("libc6").WithVersion(">=", "2.19"),
+//       dependency.Or(dependency.New("default-mta"), dependency.New("mail-transport-agent")),
+//   )
+//
+// is the same as parsing "libc6 (>= 2.19), default-mta | mail-transport-agent".
+
+// A Term is anything that can be a Relation on its own in a Dependency,
+// which is either a whole Relation, or a single Possibility.
+type Term interface {
+	relation() *Relation
+}
+
+func (relation *Relation) relation() *Relation {
+	return relation
+}
+
+func (possi *Possibility) relation() *Relation {
+	return &Relation{Possibilities: []*Possibility{possi}}
+}
+
+// Create a new Possibility on the named package, with no restrictions.
+func New(name string) *Possibility {
+	return &Possibility{Name: name}
+}
+
+// Restrict this Possibility to versions that satisfy the given relation,
+// such as (">=", "1.0"), and return it again.
+func (possi *Possibility) WithVersion(operator, number string) *Possibility {
+	possi.Version = &VersionRelation{Operator: operator, Number: number}
+	return possi
+}
+
+// Only apply this Possibility on the given Architectures (as in
+// "foo [amd64 i386]"), and return it again.
+func (possi *Possibility) WithArch(arches ...Arch) *Possibility {
+	possi.Architectures = &ArchSet{Architectures: arches}
+	return possi
+}
+
+// Apply this Possibility on all but the given Architectures (as in
+// "foo [!amd64 !i386]"), and return it again.
+func (possi *Possibility) WithoutArch(arches ...Arch) *Possibility {
+	possi.Architectures = &ArchSet{Not: true, Architectures: arches}
+	return possi
+}
+
+// Qualify the package name with an Architecture (as in "foo:amd64" or
+// "foo:any"), and return it again.
+func (possi *Possibility) WithQualifier(arch Arch) *Possibility {
+	possi.Arch = &arch
+	return possi
+}
+
+// Create a Relation that can be satisfied by any one of the Possibilities,
+// as in "foo | bar".
+func Or(possis ...*Possibility) *Relation {
+	return &Relation{Possibilities: possis}
+}
+
+// Create a Dependency that needs every one of the Terms, as in "foo, bar".
+func And(terms ...Term) Dependency {
+	ret := Dependency{Relations: []*Relation{}}
+	for _, term := range terms {
+		ret.Relations = append(ret.Relations, term.relation())
+	}
+	return ret
+}
+
+// vim: foldmethod=marker
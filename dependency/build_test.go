@@ -0,0 +1,73 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency_test
+
+import (
+	"testing"
+
+	"pault.ag/go/debian/dependency"
+)
+
+/*
+ *
+ */
+
+func mustArch(t *testing.T, name string) dependency.Arch {
+	arch, err := dependency.ParseArch(name)
+	isok(t, err)
+	return *arch
+}
+
+func TestBuildDependency(t *testing.T) {
+	dep := dependency.And(
+		dependency.New("libc6").WithVersion(">=", "2.19"),
+		dependency.Or(
+			dependency.New("default-mta"),
+			dependency.New("mail-transport-agent"),
+		),
+		dependency.New("libfoo").
+			WithQualifier(mustArch(t, "any")).
+			WithVersion("<<", "2:1.0-1~").
+			WithArch(mustArch(t, "amd64"), mustArch(t, "arm64")),
+		dependency.New("libbar").WithoutArch(mustArch(t, "hurd-any")),
+	)
+
+	expected := "libc6 (>= 2.19), default-mta | mail-transport-agent, " +
+		"libfoo:any (<< 2:1.0-1~) [amd64 arm64], libbar [!hurd-any]"
+	assert(t, dep.String() == expected)
+
+	str, err := dep.MarshalControl()
+	isok(t, err)
+	assert(t, str == expected)
+
+	/* And it's the same thing the parser comes up with */
+	parsed, err := dependency.Parse(expected)
+	isok(t, err)
+	assert(t, parsed.String() == dep.String())
+	assert(t, len(parsed.Relations) == len(dep.Relations))
+	assert(t, len(dep.Relations[1].Possibilities) == 2)
+	assert(t, dep.Relations[2].Possibilities[0].Arch.CPU == "any")
+	assert(t, dep.Relations[3].Possibilities[0].Architectures.Not)
+
+	assert(t, dependency.And().String() == "")
+}
+
+// vim: foldmethod=marker
@@ -20,6 +20,10 @@
 
 package dependency
 
+import (
+	"sort"
+)
+
 //
 func (dep *Dependency) GetPossibilities(arch Arch) []Possibility {
 	possies := []Possibility{}
@@ -71,4 +75,95 @@ func (dep *Dependency) GetSubstvars() []Possibility {
 	return possies
 }
 
+// Call fn with every Possibility of this Dependency (substvars included),
+// in order, so that it can change them in place, such as to add an arch
+// qualifier to all of them. If fn returns an error, VisitPossibilities
+// stops there, and returns it; whatever was changed so far stays changed.
+func (dep *Dependency) VisitPossibilities(fn func(possibility *Possibility) error) error {
+	for _, relation := range dep.Relations {
+		for _, possibility := range relation.Possibilities {
+			if err := fn(possibility); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Check to see if any of the Possibilities of this Dependency come with a
+// build profile restriction, such as "foo <!nocheck>".
+func (dep Dependency) HasProfileRestrictions() bool {
+	for _, relation := range dep.Relations {
+		for _, possibility := range relation.Possibilities {
+			if len(possibility.Restrictions) != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Return the names of every build profile the restrictions of this
+// Dependency mention, negated or not, sorted and without duplicates. For
+// "foo <!nocheck>, bar <stage1 !nocheck>" that's nocheck and stage1.
+func (dep Dependency) ActiveProfiles() []string {
+	seen := map[string]bool{}
+	profiles := []string{}
+	for _, relation := range dep.Relations {
+		for _, possibility := range relation.Possibilities {
+			for _, stages := range possibility.Restrictions {
+				for _, stage := range stages.Stages {
+					if seen[stage.Name] {
+						continue
+					}
+					seen[stage.Name] = true
+					profiles = append(profiles, stage.Name)
+				}
+			}
+		}
+	}
+	sort.Strings(profiles)
+	return profiles
+}
+
+// Return a deep copy of the Possibility, which shares none of its Arch,
+// Architectures, Stages, Restrictions or Version with the original, so
+// that changing the one doesn't change the other.
+func clonePossibility(possibility *Possibility) *Possibility {
+	ret := *possibility
+	if possibility.Arch != nil {
+		arch := *possibility.Arch
+		ret.Arch = &arch
+	}
+	if possibility.Architectures != nil {
+		ret.Architectures = &ArchSet{
+			Not:           possibility.Architectures.Not,
+			Architectures: append([]Arch(nil), possibility.Architectures.Architectures...),
+		}
+	}
+	cloneStages := func(stages *StageSet) *StageSet {
+		if stages == nil {
+			return nil
+		}
+		return &StageSet{Stages: append([]Stage(nil), stages.Stages...)}
+	}
+	ret.Stages = cloneStages(possibility.Stages)
+	if possibility.Restrictions != nil {
+		ret.Restrictions = make([]*StageSet, len(possibility.Restrictions))
+		for i, stages := range possibility.Restrictions {
+			if stages == possibility.Stages {
+				/* Stages is the first of the Restrictions */
+				ret.Restrictions[i] = ret.Stages
+				continue
+			}
+			ret.Restrictions[i] = cloneStages(stages)
+		}
+	}
+	if possibility.Version != nil {
+		version := *possibility.Version
+		ret.Version = &version
+	}
+	return &ret
+}
+
 // vim: foldmethod=marker
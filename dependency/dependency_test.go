@@ -21,6 +21,7 @@
 package dependency_test
 
 import (
+	"fmt"
 	"testing"
 
 	"pault.ag/go/debian/dependency"
@@ -86,4 +87,48 @@ func TestSliceSubParse(t *testing.T) {
 	assert(t, els[1].Name == "bar:Depends")
 }
 
+func TestVisitPossibilities(t *testing.T) {
+	dep, err := dependency.Parse("libc6 (>= 2.19), libfoo1 (<< 2.0~) | libbar1, ${misc:Depends}")
+	isok(t, err)
+
+	/* Bump every version constraint to at least the given version */
+	isok(t, dep.VisitPossibilities(func(possibility *dependency.Possibility) error {
+		if possibility.Substvar {
+			return nil
+		}
+		possibility.Version = &dependency.VersionRelation{Operator: ">=", Number: "3.0"}
+		return nil
+	}))
+	assert(t, dep.String() == "libc6 (>= 3.0), libfoo1 (>= 3.0) | libbar1 (>= 3.0), ${misc:Depends}")
+
+	/* Errors stop the walk */
+	seen := 0
+	err = dep.VisitPossibilities(func(possibility *dependency.Possibility) error {
+		seen++
+		if possibility.Name == "libfoo1" {
+			return fmt.Errorf("No thanks")
+		}
+		return nil
+	})
+	notok(t, err)
+	assert(t, seen == 2)
+}
+
+func TestProfileRestrictions(t *testing.T) {
+	dep, err := dependency.Parse("debhelper-compat (= 13), python3-pytest <!nocheck>, dh-python, libfoo-dev <!stage1 !nocheck> | libbar-dev <cross>")
+	isok(t, err)
+	assert(t, dep.HasProfileRestrictions())
+
+	profiles := dep.ActiveProfiles()
+	assert(t, len(profiles) == 3)
+	assert(t, profiles[0] == "cross")
+	assert(t, profiles[1] == "nocheck")
+	assert(t, profiles[2] == "stage1")
+
+	dep, err = dependency.Parse("debhelper-compat (= 13), dh-python [amd64], ${misc:Depends}")
+	isok(t, err)
+	assert(t, !dep.HasProfileRestrictions())
+	assert(t, len(dep.ActiveProfiles()) == 0)
+}
+
 // vim: foldmethod=marker
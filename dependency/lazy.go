@@ -0,0 +1,71 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency
+
+// A LazyDependency holds a relation field (such as Depends) as the raw
+// string it was read in as, and only parses it the first time Parsed is
+// called. Using it in place of Dependency makes decoding huge indices like
+// Packages a lot cheaper when most relations are never looked at.
+//
+// The catch is that a malformed relation won't fail the decode; the error
+// only comes back from Parsed, when (and if) it's called. Parsed caches its
+// result, and is not safe to call from more than one goroutine at once.
+type LazyDependency struct {
+	raw string
+
+	parsed *Dependency
+	err    error
+}
+
+// Create a LazyDependency out of a relation string, which won't be parsed
+// until it's needed.
+func NewLazyDependency(raw string) LazyDependency {
+	return LazyDependency{raw: raw}
+}
+
+// Parse the relation (if that hasn't been done already), and return it.
+func (lazy *LazyDependency) Parsed() (*Dependency, error) {
+	if lazy.parsed == nil && lazy.err == nil {
+		dep, err := Parse(lazy.raw)
+		if err != nil {
+			lazy.err = err
+		} else {
+			lazy.parsed = dep
+		}
+	}
+	return lazy.parsed, lazy.err
+}
+
+// Return the relation as it was given, without parsing it.
+func (lazy LazyDependency) String() string {
+	return lazy.raw
+}
+
+func (lazy *LazyDependency) UnmarshalControl(data string) error {
+	*lazy = LazyDependency{raw: data}
+	return nil
+}
+
+func (lazy LazyDependency) MarshalControl() (string, error) {
+	return lazy.raw, nil
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,69 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency_test
+
+import (
+	"testing"
+
+	"pault.ag/go/debian/dependency"
+)
+
+/*
+ *
+ */
+
+func TestLazyDependency(t *testing.T) {
+	lazy := dependency.LazyDependency{}
+	isok(t, lazy.UnmarshalControl("foo (>= 1.0), bar | baz"))
+	assert(t, lazy.String() == "foo (>= 1.0), bar | baz")
+
+	dep, err := lazy.Parsed()
+	isok(t, err)
+	assert(t, len(dep.Relations) == 2)
+	assert(t, dep.Relations[1].Possibilities[1].Name == "baz")
+
+	/* Cached from here on out */
+	again, err := lazy.Parsed()
+	isok(t, err)
+	assert(t, again == dep)
+
+	str, err := lazy.MarshalControl()
+	isok(t, err)
+	assert(t, str == "foo (>= 1.0), bar | baz")
+}
+
+func TestLazyDependencyError(t *testing.T) {
+	/* Nothing goes wrong until it's parsed */
+	lazy := dependency.LazyDependency{}
+	isok(t, lazy.UnmarshalControl("foo (>> "))
+
+	_, err := lazy.Parsed()
+	notok(t, err)
+	_, err = lazy.Parsed()
+	notok(t, err)
+
+	lazy = dependency.NewLazyDependency("foo")
+	dep, err := lazy.Parsed()
+	isok(t, err)
+	assert(t, dep.Relations[0].Possibilities[0].Name == "foo")
+}
+
+// vim: foldmethod=marker
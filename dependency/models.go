@@ -43,11 +43,15 @@ type VersionRelation struct {
 	Operator string
 }
 
+// Stage models a single term of a build profile restriction list, such as
+// the "!nocheck" in "<!nocheck cross>".
 type Stage struct {
 	Not  bool
 	Name string
 }
 
+// StageSet models a build profile restriction list, such as
+// "<!nocheck cross>", which holds if all of its Stages do.
 type StageSet struct {
 	Stages []Stage
 }
@@ -61,11 +65,17 @@ type StageSet struct {
 // further restrictions, such as restrictions on Version, Architecture, or
 // Build Stage.
 //
+// A Possibility may have any number of build profile restriction lists,
+// such as the two in "foo <!nocheck> <stage1 cross>", which end up in
+// Restrictions in order; the Possibility applies if any one of them holds.
+// Stages is the first of them (or an empty StageSet if there are none),
+// from back when only one was allowed.
 type Possibility struct {
 	Name          string
 	Arch          *Arch
 	Architectures *ArchSet
 	Stages        *StageSet
+	Restrictions  []*StageSet
 	Version       *VersionRelation
 	Substvar      bool
 }
@@ -86,6 +96,10 @@ type Relation struct {
 // A Dependency is the top level type that models a full Dependency relation.
 type Dependency struct {
 	Relations []*Relation
+
+	// If set, the separators to use when writing this Dependency back
+	// out with String or MarshalControl.
+	Style *Style
 }
 
 func (dep *Dependency) UnmarshalControl(data string) error {
@@ -166,7 +166,9 @@ func parsePossibility(input *Input, relation *Relation) error {
 				return err
 			}
 			continue
-		case ' ':
+		case ' ', '\t', '\n', '\r', '(', '[', '<':
+			/* The version relation and restrictions may follow the
+			 * name (or :arch qualifier) without any space in between */
 			err := parsePossibilityControllers(input, ret)
 			if err != nil {
 				return err
@@ -260,6 +262,12 @@ func parsePossibilityControllers(input *Input, possi *Possibility) error {
 				return err
 			}
 			continue
+		case '<':
+			err := parsePossibilityStages(input, possi)
+			if err != nil {
+				return err
+			}
+			continue
 		}
 		return fmt.Errorf("Trailing garbage in a Possibility: %c", peek)
 	}
@@ -379,7 +387,12 @@ func parsePossibilityArch(input *Input, possi *Possibility) error {
 		case 0:
 			return errors.New("Oh no. Reached EOF before Arch list finished")
 		case '!':
-			return errors.New("You can only negate whole blocks :(")
+			if arch != "" || !possi.Architectures.Not {
+				return errors.New("You can only negate whole blocks :(")
+			}
+			/* [!amd64 !sparc] is the same as [!amd64 sparc] */
+			input.Next()
+			continue
 		case ']', ' ': /* Let our parent deal with both of these */
 			archObj, err := ParseArch(arch)
 			if err != nil {
@@ -395,6 +408,63 @@ func parsePossibilityArch(input *Input, possi *Possibility) error {
 	}
 }
 
+/* */
+func parsePossibilityStages(input *Input, possi *Possibility) error {
+	eatWhitespace(input)
+	input.Next() /* Assert ch == '<' */
+
+	stages := &StageSet{Stages: []Stage{}}
+	for {
+		eatWhitespace(input)
+		peek := input.Peek()
+		switch peek {
+		case 0:
+			return errors.New("Oh no. Reached EOF before Profile list finished")
+		case '>':
+			input.Next()
+			if len(stages.Stages) == 0 {
+				return errors.New("Empty Profile list in Possibility")
+			}
+			if len(possi.Restrictions) == 0 {
+				possi.Stages = stages
+			}
+			possi.Restrictions = append(possi.Restrictions, stages)
+			return nil
+		}
+
+		err := parsePossibilityStage(input, stages)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+/* */
+func parsePossibilityStage(input *Input, stages *StageSet) error {
+	stage := Stage{}
+	if input.Peek() == '!' {
+		input.Next()
+		stage.Not = true
+	}
+
+	for {
+		peek := input.Peek()
+		switch peek {
+		case 0:
+			return errors.New("Oh no. Reached EOF before Profile list finished")
+		case '>', ' ', '\t', '\n', '\r':
+			if stage.Name == "" {
+				return errors.New("No Profile name in Profile list")
+			}
+			stages.Stages = append(stages.Stages, stage)
+			return nil
+		case '!', '<':
+			return fmt.Errorf("Unexpected %c in Profile list", peek)
+		}
+		stage.Name += string(input.Next())
+	}
+}
+
 // }}}
 
 // vim: foldmethod=marker
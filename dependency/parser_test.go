@@ -82,6 +82,42 @@ func TestMultiarchParse(t *testing.T) {
 	assert(t, dep.Relations[0].Possibilities[0].Architectures.Architectures[1].CPU == "sparc")
 }
 
+func TestMultiarchVersionParse(t *testing.T) {
+	for _, el := range []string{
+		"libfoo:amd64 (>= 2.0)",
+		"libfoo:amd64 (>= 2.0) [amd64 arm64]",
+		"libfoo:any (<< 3:2.0-1~), bar:native (= 1.0) | baz",
+	} {
+		dep, err := dependency.Parse(el)
+		isok(t, err)
+		assert(t, dep.String() == el)
+
+		again, err := dependency.Parse(dep.String())
+		isok(t, err)
+		assert(t, again.String() == el)
+	}
+
+	/* Both the qualifier and the relation land on the one Possibility */
+	dep, err := dependency.Parse("libfoo:amd64 (>= 2.0)")
+	isok(t, err)
+	assert(t, len(dep.Relations) == 1)
+	assert(t, len(dep.Relations[0].Possibilities) == 1)
+	possi := dep.Relations[0].Possibilities[0]
+	assert(t, possi.Name == "libfoo")
+	assert(t, possi.Arch.CPU == "amd64")
+	assert(t, possi.Version.Operator == ">=")
+	assert(t, possi.Version.Number == "2.0")
+
+	/* Whitespace around the qualifier's relation doesn't matter */
+	dep, err = dependency.Parse("libfoo:amd64(>=2.0)")
+	isok(t, err)
+	assert(t, dep.String() == "libfoo:amd64 (>= 2.0)")
+
+	/* But the relation can't come before the qualifier */
+	_, err = dependency.Parse("libfoo (>= 2.0):amd64")
+	notok(t, err)
+}
+
 func TestTwoRelations(t *testing.T) {
 	dep, err := dependency.Parse("foo, bar")
 	isok(t, err)
@@ -137,6 +173,18 @@ func TestSingleNotArch(t *testing.T) {
 	assert(t, possi.Architectures.Not)
 }
 
+func TestDoubleNotArch(t *testing.T) {
+	dep, err := dependency.Parse("foo [!arch !arch2]")
+	isok(t, err)
+
+	possi := dep.Relations[0].Possibilities[0]
+	arches := possi.Architectures.Architectures
+
+	assert(t, len(arches) == 2)
+	assert(t, arches[1].CPU == "arch2")
+	assert(t, possi.Architectures.Not)
+}
+
 func TestDoubleInvalidNotArch(t *testing.T) {
 	_, err := dependency.Parse("foo [arch !foo]")
 	notok(t, err)
@@ -240,4 +288,36 @@ func TestSingleSubstvar(t *testing.T) {
 	assert(t, !dep.Relations[2].Possibilities[0].Substvar)
 }
 
+func TestProfileParse(t *testing.T) {
+	dep, err := dependency.Parse("foo (>= 1.0) [amd64] <!nocheck> <stage1 cross>, bar:any<!nodoc>, baz")
+	isok(t, err)
+	assert(t, len(dep.Relations) == 3)
+
+	foo := dep.Relations[0].Possibilities[0]
+	assert(t, foo.Version.Number == "1.0")
+	assert(t, len(foo.Restrictions) == 2)
+	assert(t, foo.Stages == foo.Restrictions[0])
+	assert(t, len(foo.Restrictions[0].Stages) == 1)
+	assert(t, foo.Restrictions[0].Stages[0] == dependency.Stage{Not: true, Name: "nocheck"})
+	assert(t, len(foo.Restrictions[1].Stages) == 2)
+	assert(t, foo.Restrictions[1].Stages[0] == dependency.Stage{Name: "stage1"})
+	assert(t, foo.Restrictions[1].Stages[1] == dependency.Stage{Name: "cross"})
+
+	bar := dep.Relations[1].Possibilities[0]
+	assert(t, bar.Arch.CPU == "any")
+	assert(t, len(bar.Restrictions) == 1)
+	assert(t, bar.Restrictions[0].Stages[0].Name == "nodoc")
+
+	assert(t, len(dep.Relations[2].Possibilities[0].Restrictions) == 0)
+
+	assert(t, dep.String() == "foo (>= 1.0) [amd64] <!nocheck> <stage1 cross>, bar:any <!nodoc>, baz")
+}
+
+func TestBadProfile(t *testing.T) {
+	for _, el := range []string{"foo <>", "foo <!nocheck", "foo <no!check>", "foo <! nocheck>"} {
+		_, err := dependency.Parse(el)
+		notok(t, err)
+	}
+}
+
 // vim: foldmethod=marker
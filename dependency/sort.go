@@ -0,0 +1,77 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency
+
+import (
+	"sort"
+)
+
+// Return a copy of the Dependency with its Relations sorted, the way
+// `wrap-and-sort -s` does, for output that diffs well. Relations are
+// sorted on the Name of their first Possibility, compared byte by byte,
+// with substvars (such as ${misc:Depends}) after everything else. Relations
+// that compare the same keep the order they were in. The Possibilities
+// within each Relation are left alone, since the first one is preferred.
+func (dep Dependency) Sorted() Dependency {
+	return dep.sorted(false)
+}
+
+// Like Sorted, but the Possibilities within each Relation (as in
+// "foo | bar") are sorted on their Name too, before the Relations are.
+// This changes which alternative is tried first, so only use it where that
+// doesn't matter.
+func (dep Dependency) SortedAlternatives() Dependency {
+	return dep.sorted(true)
+}
+
+func (dep Dependency) sorted(alternatives bool) Dependency {
+	ret := Dependency{Style: dep.Style}
+	for _, relation := range dep.Relations {
+		newRelation := &Relation{}
+		for _, possibility := range relation.Possibilities {
+			newRelation.Possibilities = append(newRelation.Possibilities, clonePossibility(possibility))
+		}
+		if alternatives {
+			sort.SliceStable(newRelation.Possibilities, func(i, j int) bool {
+				return lessPossibility(newRelation.Possibilities[i], newRelation.Possibilities[j])
+			})
+		}
+		ret.Relations = append(ret.Relations, newRelation)
+	}
+
+	sort.SliceStable(ret.Relations, func(i, j int) bool {
+		left, right := ret.Relations[i].Possibilities, ret.Relations[j].Possibilities
+		if len(left) == 0 || len(right) == 0 {
+			return len(left) != 0
+		}
+		return lessPossibility(left[0], right[0])
+	})
+	return ret
+}
+
+func lessPossibility(left, right *Possibility) bool {
+	if left.Substvar != right.Substvar {
+		return right.Substvar
+	}
+	return left.Name < right.Name
+}
+
+// vim: foldmethod=marker
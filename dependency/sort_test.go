@@ -0,0 +1,69 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency_test
+
+import (
+	"testing"
+
+	"pault.ag/go/debian/dependency"
+)
+
+/*
+ *
+ */
+
+func TestDependencySorted(t *testing.T) {
+	dep, err := dependency.Parse("${misc:Depends}, python3, libfoo-dev (>= 1.0) | libbar-dev, debhelper (>= 9), ${shlibs:Depends}, dh-python, libfoo-dev [amd64]")
+	isok(t, err)
+	original := dep.String()
+
+	sorted := dep.Sorted()
+	assert(t, sorted.String() == "debhelper (>= 9), dh-python, libfoo-dev (>= 1.0) | libbar-dev, libfoo-dev [amd64], python3, ${misc:Depends}, ${shlibs:Depends}")
+
+	/* Sorting again doesn't change a thing, and the original is left alone */
+	assert(t, sorted.Sorted().String() == sorted.String())
+	assert(t, dep.String() == original)
+
+	alternatives := dep.SortedAlternatives()
+	assert(t, alternatives.String() == "debhelper (>= 9), dh-python, libbar-dev | libfoo-dev (>= 1.0), libfoo-dev [amd64], python3, ${misc:Depends}, ${shlibs:Depends}")
+	assert(t, dep.String() == original)
+
+	/* Changing the sorted copy in place leaves the original alone, too */
+	isok(t, sorted.VisitPossibilities(func(possibility *dependency.Possibility) error {
+		if possibility.Version != nil {
+			possibility.Version.Number = "0"
+		}
+		if possibility.Architectures != nil && len(possibility.Architectures.Architectures) != 0 {
+			possibility.Architectures.Architectures[0].CPU = "i386"
+		}
+		return nil
+	}))
+	assert(t, dep.String() == original)
+
+	withStages, err := dependency.Parse("foo <!nocheck> <stage1>")
+	isok(t, err)
+	copied := withStages.Sorted()
+	copied.Relations[0].Possibilities[0].Restrictions[0].Stages[0].Name = "cross"
+	assert(t, withStages.String() == "foo <!nocheck> <stage1>")
+	assert(t, copied.Relations[0].Possibilities[0].Stages.Stages[0].Name == "cross")
+}
+
+// vim: foldmethod=marker
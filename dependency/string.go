@@ -0,0 +1,174 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// String returns the restriction as it'd be written in a control file,
+// such as "[amd64 sparc]" or "[!amd64 !sparc]".
+func (set ArchSet) String() string {
+	if len(set.Architectures) == 0 {
+		return ""
+	}
+
+	not := ""
+	if set.Not {
+		not = "!"
+	}
+
+	arches := []string{}
+	for _, arch := range set.Architectures {
+		arches = append(arches, not+arch.String())
+	}
+	return "[" + strings.Join(arches, " ") + "]"
+}
+
+// String returns the restriction list as it'd be written in a control
+// file, such as "<!nocheck cross>".
+func (set StageSet) String() string {
+	stages := []string{}
+	for _, stage := range set.Stages {
+		if stage.Not {
+			stages = append(stages, "!"+stage.Name)
+		} else {
+			stages = append(stages, stage.Name)
+		}
+	}
+	return "<" + strings.Join(stages, " ") + ">"
+}
+
+// String returns the restriction as it'd be written in a control file,
+// such as "(>= 1.0)".
+func (version VersionRelation) String() string {
+	return "(" + version.Operator + " " + version.Number + ")"
+}
+
+// String returns the Possibility as it'd be written in a control file,
+// such as "foo:any (>= 1.0) [amd64] <!nocheck>" or "${misc:Depends}".
+func (possi Possibility) String() string {
+	if possi.Substvar {
+		return "${" + possi.Name + "}"
+	}
+
+	str := possi.Name
+	if possi.Arch != nil {
+		str += ":" + possi.Arch.String()
+	}
+	if possi.Version != nil {
+		str += " " + possi.Version.String()
+	}
+	if possi.Architectures != nil && len(possi.Architectures.Architectures) != 0 {
+		str += " " + possi.Architectures.String()
+	}
+	for _, stages := range possi.Restrictions {
+		str += " " + stages.String()
+	}
+	return str
+}
+
+func (possi Possibility) MarshalControl() (string, error) {
+	return possi.String(), nil
+}
+
+// A Style controls the separators used when writing out a Dependency, for
+// matching the exact output of a given tool.
+type Style struct {
+	// Placed between the alternative Possibilities of a Relation.
+	Pipe string
+	// Placed between the Relations of a Dependency.
+	Comma string
+}
+
+var (
+	// The separators dpkg uses, such as "foo | bar, baz".
+	DefaultStyle = Style{Pipe: " | ", Comma: ", "}
+	// No spaces at all, such as "foo|bar,baz".
+	CompactStyle = Style{Pipe: "|", Comma: ","}
+)
+
+// String returns the Relation as it'd be written in a control file, with
+// the Possibilities joined by " | ".
+func (relation Relation) String() string {
+	return relation.StringWithStyle(DefaultStyle)
+}
+
+// StringWithStyle returns the Relation with the Possibilities joined by
+// the Pipe of the given Style.
+func (relation Relation) StringWithStyle(style Style) string {
+	possis := []string{}
+	for _, possi := range relation.Possibilities {
+		possis = append(possis, possi.String())
+	}
+	return strings.Join(possis, style.Pipe)
+}
+
+func (relation Relation) MarshalControl() (string, error) {
+	return relation.String(), nil
+}
+
+// String returns the Dependency as it'd be written in a control file. This
+// uses the Dependency's Style if set, and DefaultStyle (", " between
+// Relations) otherwise.
+func (dep Dependency) String() string {
+	if dep.Style != nil {
+		return dep.StringWithStyle(*dep.Style)
+	}
+	return dep.StringWithStyle(DefaultStyle)
+}
+
+// StringWithStyle returns the Dependency written out using the separators
+// of the given Style.
+func (dep Dependency) StringWithStyle(style Style) string {
+	relations := []string{}
+	for _, relation := range dep.Relations {
+		relations = append(relations, relation.StringWithStyle(style))
+	}
+	return strings.Join(relations, style.Comma)
+}
+
+func (dep Dependency) MarshalControl() (string, error) {
+	return dep.String(), nil
+}
+
+// Check that this Dependency is valid as a Provides field. Packages may
+// only Provide exact versions of other packages (using "="), and there
+// are no alternatives in a Provides field.
+func (dep Dependency) ValidateProvides() error {
+	for _, relation := range dep.Relations {
+		if len(relation.Possibilities) != 1 {
+			return errors.New("Provides may not have alternatives")
+		}
+		possi := relation.Possibilities[0]
+		if possi.Version != nil && possi.Version.Operator != "=" {
+			return fmt.Errorf(
+				"Provides may only use the = relation: %s",
+				possi.String(),
+			)
+		}
+	}
+	return nil
+}
+
+// vim: foldmethod=marker
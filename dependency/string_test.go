@@ -0,0 +1,115 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency_test
+
+import (
+	"testing"
+
+	"pault.ag/go/debian/dependency"
+)
+
+/*
+ *
+ */
+
+func TestDependencyString(t *testing.T) {
+	for _, el := range []string{
+		"foo",
+		"foo, bar | baz",
+		"foo (= 1.0)",
+		"foo:any (>= 1.0) [amd64 sparc]",
+		"foo [!amd64 !kfreebsd-any]",
+		"foo [linux-any], bar:amd64",
+		"${misc:Depends}, foo (<< 2:1.0-1~)",
+	} {
+		dep, err := dependency.Parse(el)
+		isok(t, err)
+		if dep.String() != el {
+			t.Errorf("%q: got %q back", el, dep.String())
+		}
+
+		/* And it parses back into the same thing */
+		again, err := dependency.Parse(dep.String())
+		isok(t, err)
+		assert(t, again.String() == dep.String())
+	}
+}
+
+func TestDependencyMarshalControl(t *testing.T) {
+	dep, err := dependency.Parse("foo,bar|baz (>=1.0)")
+	isok(t, err)
+	str, err := dep.MarshalControl()
+	isok(t, err)
+	assert(t, str == "foo, bar | baz (>= 1.0)")
+
+	str, err = dep.Relations[1].MarshalControl()
+	isok(t, err)
+	assert(t, str == "bar | baz (>= 1.0)")
+
+	str, err = dep.Relations[1].Possibilities[1].MarshalControl()
+	isok(t, err)
+	assert(t, str == "baz (>= 1.0)")
+}
+
+func TestValidateProvides(t *testing.T) {
+	dep, err := dependency.Parse("foo, bar (= 1.0)")
+	isok(t, err)
+	isok(t, dep.ValidateProvides())
+
+	dep, err = dependency.Parse("foo, bar (>= 1.0)")
+	isok(t, err)
+	notok(t, dep.ValidateProvides())
+
+	dep, err = dependency.Parse("foo | bar")
+	isok(t, err)
+	notok(t, dep.ValidateProvides())
+}
+
+func TestDependencyStyle(t *testing.T) {
+	for _, tc := range []struct {
+		spaced  string
+		compact string
+	}{
+		{"foo", "foo"},
+		{"foo, bar | baz", "foo,bar|baz"},
+		{"foo (>= 1.0) | bar [amd64], baz:any", "foo (>= 1.0)|bar [amd64],baz:any"},
+	} {
+		for _, input := range []string{tc.spaced, tc.compact} {
+			dep, err := dependency.Parse(input)
+			isok(t, err)
+			assert(t, dep.StringWithStyle(dependency.DefaultStyle) == tc.spaced)
+			assert(t, dep.StringWithStyle(dependency.CompactStyle) == tc.compact)
+
+			/* Setting the Style changes what gets marshaled */
+			dep.Style = &dependency.CompactStyle
+			str, err := dep.MarshalControl()
+			isok(t, err)
+			assert(t, str == tc.compact)
+			assert(t, dep.String() == tc.compact)
+
+			again, err := dependency.Parse(str)
+			isok(t, err)
+			assert(t, again.String() == tc.spaced)
+		}
+	}
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,72 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency
+
+import (
+	"fmt"
+	"strings"
+
+	"pault.ag/go/debian/version"
+)
+
+// Return the upstream part of the version, keeping the epoch (if any),
+// which is what dpkg-gencontrol(1) uses for ${source:Upstream-Version}.
+func upstreamVersion(v version.Version) string {
+	if v.Epoch != 0 {
+		return fmt.Sprintf("%d:%s", v.Epoch, v.Version)
+	}
+	return v.Version
+}
+
+// Return a copy of the Dependency, with the standard dpkg substitution
+// variables in version restrictions filled in, such as turning
+// "foo (= ${binary:Version})" into "foo (= 1:2.0-1)". The variables
+// handled are:
+//
+//	${binary:Version}          the binary package's full version
+//	${source:Version}          the source package's full version
+//	${source:Upstream-Version} the source's upstream version, with epoch
+//
+// Substvars that stand in for whole relations (such as ${misc:Depends})
+// are left for the caller to deal with, see GetSubstvars.
+func SubstituteStandard(dep Dependency, binVer, srcVer version.Version) Dependency {
+	replacer := strings.NewReplacer(
+		"${binary:Version}", binVer.String(),
+		"${source:Version}", srcVer.String(),
+		"${source:Upstream-Version}", upstreamVersion(srcVer),
+	)
+
+	ret := Dependency{Style: dep.Style}
+	for _, relation := range dep.Relations {
+		newRelation := &Relation{}
+		for _, possibility := range relation.Possibilities {
+			newPossibility := clonePossibility(possibility)
+			if newPossibility.Version != nil {
+				newPossibility.Version.Number = replacer.Replace(newPossibility.Version.Number)
+			}
+			newRelation.Possibilities = append(newRelation.Possibilities, newPossibility)
+		}
+		ret.Relations = append(ret.Relations, newRelation)
+	}
+	return ret
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,88 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency_test
+
+import (
+	"testing"
+
+	"pault.ag/go/debian/dependency"
+	"pault.ag/go/debian/version"
+)
+
+/*
+ *
+ */
+
+func TestSubstituteStandard(t *testing.T) {
+	binVer, err := version.Parse("1:2.0-1+b1")
+	isok(t, err)
+	srcVer, err := version.Parse("1:2.0-1")
+	isok(t, err)
+
+	for input, output := range map[string]string{
+		"foo (= ${binary:Version})":                     "foo (= 1:2.0-1+b1)",
+		"foo-data (= ${source:Version})":                "foo-data (= 1:2.0-1)",
+		"foo-doc (>= ${source:Upstream-Version})":       "foo-doc (>= 1:2.0)",
+		"foo (<< ${source:Upstream-Version}.1~)":        "foo (<< 1:2.0.1~)",
+		"foo (>= 1.0), bar | baz (= ${binary:Version})": "foo (>= 1.0), bar | baz (= 1:2.0-1+b1)",
+		"${misc:Depends}, foo [amd64]":                  "${misc:Depends}, foo [amd64]",
+	} {
+		dep, err := dependency.Parse(input)
+		isok(t, err)
+		got := dependency.SubstituteStandard(*dep, binVer, srcVer).String()
+		if got != output {
+			t.Errorf("%q: got %q, expected %q", input, got, output)
+		}
+		/* The original is left alone */
+		assert(t, dep.String() == input)
+	}
+}
+
+func TestSubstituteStandardCopies(t *testing.T) {
+	srcVer, err := version.Parse("2.0-3")
+	isok(t, err)
+
+	input := "foo [amd64] <!nocheck>, bar:any"
+	dep, err := dependency.Parse(input)
+	isok(t, err)
+	got := dependency.SubstituteStandard(*dep, srcVer, srcVer)
+
+	/* Changing the copy in place doesn't reach back into the original */
+	foo := got.Relations[0].Possibilities[0]
+	foo.Architectures.Architectures[0].CPU = "i386"
+	foo.Restrictions[0].Stages[0].Not = false
+	got.Relations[1].Possibilities[0].Arch.OS = "linux"
+	assert(t, got.Relations[0].String() == "foo [i386] <nocheck>")
+	assert(t, dep.String() == input)
+	assert(t, dep.Relations[1].Possibilities[0].Arch.OS == "any")
+}
+
+func TestSubstituteStandardNoEpoch(t *testing.T) {
+	srcVer, err := version.Parse("2.0-3")
+	isok(t, err)
+
+	dep, err := dependency.Parse("foo (>= ${source:Upstream-Version}), foo (<< ${source:Version}.1~)")
+	isok(t, err)
+	got := dependency.SubstituteStandard(*dep, srcVer, srcVer).String()
+	assert(t, got == "foo (>= 2.0), foo (<< 2.0-3.1~)")
+}
+
+// vim: foldmethod=marker
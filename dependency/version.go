@@ -0,0 +1,108 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency
+
+import (
+	"pault.ag/go/debian/version"
+)
+
+// SatisfiedBy returns true if the given version falls within this
+// VersionRelation, such as 1.2-1 for (>= 1.0). A VersionRelation whose
+// Number isn't a valid version is never satisfied.
+func (vr VersionRelation) SatisfiedBy(v version.Version) bool {
+	other, err := version.Parse(vr.Number)
+	if err != nil {
+		return false
+	}
+
+	cmp := version.Compare(v, other)
+	switch vr.Operator {
+	case "<<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case "=":
+		return cmp == 0
+	case ">=":
+		return cmp >= 0
+	case ">>":
+		return cmp > 0
+	}
+	return false
+}
+
+// Triggers returns true if a Breaks or Conflicts relation with this
+// VersionRelation applies to the given version of the other package.
+//
+// This is the very same range check as SatisfiedBy, but the meaning is
+// inverted: a package that Depends on foo (>= 1.0) is happy when foo is
+// 1.2, while a package that Breaks foo (<< 1.0) is only a problem when foo
+// is older than 1.0. Resolvers should use SatisfiedBy for Depends and
+// friends, and Triggers for Breaks and Conflicts.
+func (vr VersionRelation) Triggers(v version.Version) bool {
+	return vr.SatisfiedBy(v)
+}
+
+// SatisfiedBy returns true if a package by the given name and version
+// satisfies this Possibility. Architecture restrictions and qualifiers are
+// not taken into account.
+func (p Possibility) SatisfiedBy(name string, v version.Version) bool {
+	if p.Substvar || p.Name != name {
+		return false
+	}
+	if p.Version == nil {
+		return true
+	}
+	return p.Version.SatisfiedBy(v)
+}
+
+// Satisfied checks every Relation of this Dependency against a set of
+// installed packages, mapping package name to installed version. A
+// Relation holds if any one of its Possibilities is SatisfiedBy an
+// installed package. This returns true if all of them hold, and otherwise
+// the Possibilities of each Relation that didn't, so that it's possible to
+// say exactly what's missing.
+//
+// Substvars can't be checked, and are skipped. As with SatisfiedBy, Provides,
+// architecture restrictions and qualifiers aren't taken into account.
+func (dep Dependency) Satisfied(installed map[string]version.Version) (bool, []Possibility) {
+	unsatisfied := []Possibility{}
+	for _, relation := range dep.Relations {
+		possibilities := []Possibility{}
+		satisfied := false
+		for _, possibility := range relation.Possibilities {
+			if possibility.Substvar {
+				continue
+			}
+			possibilities = append(possibilities, *possibility)
+			if v, ok := installed[possibility.Name]; ok && possibility.SatisfiedBy(possibility.Name, v) {
+				satisfied = true
+				break
+			}
+		}
+		if !satisfied {
+			unsatisfied = append(unsatisfied, possibilities...)
+		}
+	}
+	return len(unsatisfied) == 0, unsatisfied
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,109 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+package dependency_test
+
+import (
+	"testing"
+
+	"pault.ag/go/debian/dependency"
+	"pault.ag/go/debian/version"
+)
+
+/*
+ *
+ */
+
+func TestVersionRelationSatisfiedBy(t *testing.T) {
+	for _, tc := range []struct {
+		Relation  string
+		Version   string
+		Satisfied bool
+	}{
+		{"foo (>= 1.0)", "1.0", true},
+		{"foo (>= 1.0)", "1.0~rc1", false},
+		{"foo (>= 1.0)", "1.0-1", true},
+		{"foo (>> 1.0)", "1.0", false},
+		{"foo (>> 1.0)", "1.0-0.1", true},
+		{"foo (<< 1.0)", "1.0", false},
+		{"foo (<< 1.0)", "1.0~rc1", true},
+		{"foo (<= 1.0)", "1.0", true},
+		{"foo (<= 1.0)", "1:0.1", false},
+		{"foo (= 1.0-1)", "1.0-1", true},
+		{"foo (= 1.0-1)", "0:1.0-1", true},
+		{"foo (= 1.0-1)", "1.0-1+b1", false},
+	} {
+		dep, err := dependency.Parse(tc.Relation)
+		isok(t, err)
+		v, err := version.Parse(tc.Version)
+		isok(t, err)
+
+		relation := dep.Relations[0].Possibilities[0].Version
+		if relation.SatisfiedBy(v) != tc.Satisfied {
+			t.Errorf("%s with %s: expected %v", tc.Relation, tc.Version, tc.Satisfied)
+		}
+		/* Same range check, inverted meaning */
+		assert(t, relation.Triggers(v) == relation.SatisfiedBy(v))
+	}
+}
+
+func TestPossibilitySatisfiedBy(t *testing.T) {
+	dep, err := dependency.Parse("foo (>= 1.0), bar, ${misc:Depends}")
+	isok(t, err)
+	v, err := version.Parse("1.2-1")
+	isok(t, err)
+
+	assert(t, dep.Relations[0].Possibilities[0].SatisfiedBy("foo", v))
+	assert(t, !dep.Relations[0].Possibilities[0].SatisfiedBy("bar", v))
+	assert(t, dep.Relations[1].Possibilities[0].SatisfiedBy("bar", v))
+	assert(t, !dep.Relations[2].Possibilities[0].SatisfiedBy("misc:Depends", v))
+}
+
+func TestDependencySatisfied(t *testing.T) {
+	installed := map[string]version.Version{}
+	for name, number := range map[string]string{
+		"libc6":  "2.36-9",
+		"exim4":  "4.96-15",
+		"python": "2.7.18-3",
+	} {
+		v, err := version.Parse(number)
+		isok(t, err)
+		installed[name] = v
+	}
+
+	dep, err := dependency.Parse("libc6 (>= 2.34), postfix | exim4, ${misc:Depends}")
+	isok(t, err)
+	ok, unsatisfied := dep.Satisfied(installed)
+	assert(t, ok)
+	assert(t, len(unsatisfied) == 0)
+
+	dep, err = dependency.Parse("libc6 (>= 2.38), postfix | exim4 (<< 4.90), python (= 2.7.18-3), libfoo1")
+	isok(t, err)
+	ok, unsatisfied = dep.Satisfied(installed)
+	assert(t, !ok)
+	assert(t, len(unsatisfied) == 4)
+	assert(t, unsatisfied[0].Name == "libc6")
+	assert(t, unsatisfied[0].Version.Number == "2.38")
+	assert(t, unsatisfied[1].Name == "postfix")
+	assert(t, unsatisfied[2].Name == "exim4")
+	assert(t, unsatisfied[3].Name == "libfoo1")
+}
+
+// vim: foldmethod=marker
@@ -0,0 +1,84 @@
+/* {{{ Copyright (c) Paul R. Tagliamonte <paultag@debian.org>, 2015
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+ * THE SOFTWARE. }}} */
+
+// Package decompress holds the one registry of decompressors, by file
+// suffix, that both the control and deb packages look things up in, so
+// that registering a codec with either one makes it work for both.
+package decompress
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"sync"
+
+	"pault.ag/go/debian/internal/xz"
+)
+
+// A Decompressor turns a compressed stream, such as the contents of a
+// Packages.xz index or a data.tar.gz member, into a reader over the
+// uncompressed data.
+type Decompressor interface {
+	Decompress(io.Reader) (io.Reader, error)
+}
+
+// Func adapts a plain function into a Decompressor.
+type Func func(io.Reader) (io.Reader, error)
+
+// Decompress calls the underlying function.
+func (fn Func) Decompress(in io.Reader) (io.Reader, error) {
+	return fn(in)
+}
+
+var (
+	lock          sync.RWMutex
+	decompressors = map[string]Decompressor{
+		".gz": Func(func(in io.Reader) (io.Reader, error) {
+			return gzip.NewReader(in)
+		}),
+		".bz2": Func(func(in io.Reader) (io.Reader, error) {
+			return bzip2.NewReader(in), nil
+		}),
+		".xz": Func(func(in io.Reader) (io.Reader, error) {
+			/* 0 caps the dictionary at xz.DefaultDictMax (64 MiB),
+			 * which is what xz -9 uses, and so is enough for
+			 * anything written with one of the xz(1) presets */
+			return xz.NewReader(in, 0)
+		}),
+	}
+)
+
+// Register a Decompressor for the given suffix (such as ".zst"), replacing
+// any existing one for it.
+func Register(suffix string, decompressor Decompressor) {
+	lock.Lock()
+	defer lock.Unlock()
+	decompressors[suffix] = decompressor
+}
+
+// Find the Decompressor for the given suffix, if there is one.
+func Lookup(suffix string) (Decompressor, bool) {
+	lock.RLock()
+	defer lock.RUnlock()
+	decompressor, ok := decompressors[suffix]
+	return decompressor, ok
+}
+
+// vim: foldmethod=marker
@@ -41,16 +41,63 @@ type Version struct {
 	Epoch    uint
 	Version  string
 	Revision string
+
+	/* Set by Parse if the epoch or revision was written out in the
+	 * input, even if it turned out to be 0 or empty. */
+	explicitEpoch    bool
+	explicitRevision bool
 }
 
 func (v *Version) IsNative() bool {
 	return len(v.Revision) == 0
 }
 
+// HasExplicitEpoch returns true if the version string this Version was
+// parsed from contained an epoch, even a redundant one such as "0:1.0".
+func (v Version) HasExplicitEpoch() bool {
+	return v.explicitEpoch || v.Epoch > 0
+}
+
+// HasExplicitRevision returns true if the version string this Version was
+// parsed from contained a revision, even an empty one such as "1.0-".
+func (v Version) HasExplicitRevision() bool {
+	return v.explicitRevision || len(v.Revision) > 0
+}
+
+// BinNMU returns N if this is the version of a binary-only rebuild, which
+// has a "+bN" suffix on the revision, as in 1.2-3+b1 (or on the version,
+// for native packages, as in 1.2+b1). Otherwise, it returns 0. Compare
+// already sorts binNMUs after the version they were rebuilt from.
+func (v Version) BinNMU() int {
+	suffix := v.Revision
+	if suffix == "" {
+		suffix = v.Version
+	}
+	index := strings.LastIndex(suffix, "+b")
+	if index < 0 {
+		return 0
+	}
+	n, err := strconv.Atoi(suffix[index+2:])
+	if err != nil || n <= 0 || !cisdigit(rune(suffix[index+2])) {
+		return 0
+	}
+	return n
+}
+
+// IsBinNMU returns true if this is the version of a binary-only rebuild,
+// as per BinNMU.
+func (v Version) IsBinNMU() bool {
+	return v.BinNMU() != 0
+}
+
 func (version *Version) UnmarshalControl(data string) error {
 	return parseInto(version, data)
 }
 
+func (version Version) MarshalControl() (string, error) {
+	return version.String(), nil
+}
+
 func (v Version) String() string {
 	var result string
 	if v.Epoch > 0 {
@@ -138,9 +185,11 @@ func verrevcmp(a string, b string) int {
 	return 0
 }
 
-// Compare compares the two provided Debian versions. It returns 0 if a and b
-// are equal, a value < 0 if a is smaller than b and a value > 0 if a is
-// greater than b.
+// Compare compares the two provided Debian versions, the same way dpkg(1)
+// does: by epoch, then upstream version, then revision, with `~` sorting
+// before anything, even the end of the string (so 1.0~rc1 is less than
+// 1.0). A missing revision is the same as an empty one. It returns 0 if a
+// and b are equal, -1 if a is smaller than b and 1 if a is greater than b.
 func Compare(a Version, b Version) int {
 	if a.Epoch > b.Epoch {
 		return 1
@@ -150,13 +199,43 @@ func Compare(a Version, b Version) int {
 	}
 
 	rc := verrevcmp(a.Version, b.Version)
-	if rc != 0 {
-		return rc
+	if rc == 0 {
+		rc = verrevcmp(a.Revision, b.Revision)
 	}
 
-	return verrevcmp(a.Revision, b.Revision)
+	switch {
+	case rc < 0:
+		return -1
+	case rc > 0:
+		return 1
+	}
+	return 0
 }
 
+// Less returns true if v sorts before other, as per Compare.
+func (v Version) Less(other Version) bool {
+	return Compare(v, other) < 0
+}
+
+// Equal returns true if v and other are the same version, that is, if
+// Compare would return 0. Identical versions are caught without walking
+// the strings with verrevcmp, which makes this a fair bit cheaper than
+// Compare when deduping large lists of mostly identical versions.
+func (v Version) Equal(other Version) bool {
+	if v.Epoch == other.Epoch && v.Version == other.Version && v.Revision == other.Revision {
+		return true
+	}
+	return Compare(v, other) == 0
+}
+
+// Slice attaches the methods of sort.Interface to []Version, sorting in
+// increasing order, as per Compare.
+type Slice []Version
+
+func (s Slice) Len() int           { return len(s) }
+func (s Slice) Less(i, j int) bool { return Compare(s[i], s[j]) < 0 }
+func (s Slice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
 // Parse returns a Version struct filled with the epoch, version and revision
 // specified in input. It verifies the version string as a whole, just like
 // dpkg(1), and even returns roughly the same error messages.
@@ -165,6 +244,23 @@ func Parse(input string) (Version, error) {
 	return result, parseInto(&result, input)
 }
 
+// ParseLenient is like Parse, but first fixes up mistakes common in
+// versions that don't come from Debian, such as upstream git tags. The
+// fixups are exactly:
+//
+//   - whitespace around the version is dropped (Parse does this too)
+//   - a single leading "v" or "V" is dropped, if a digit follows it, so
+//     "v1.2.3" parses as "1.2.3"
+//
+// Everything else has to be a valid dpkg version, just like with Parse.
+func ParseLenient(input string) (Version, error) {
+	trimmed := strings.TrimSpace(input)
+	if len(trimmed) > 1 && (trimmed[0] == 'v' || trimmed[0] == 'V') && cisdigit(rune(trimmed[1])) {
+		trimmed = trimmed[1:]
+	}
+	return Parse(trimmed)
+}
+
 func parseInto(result *Version, input string) error {
 	trimmed := strings.TrimSpace(input)
 	if trimmed == "" {
@@ -176,6 +272,7 @@ func parseInto(result *Version, input string) error {
 	}
 
 	colon := strings.Index(trimmed, ":")
+	result.explicitEpoch = colon != -1
 	if colon != -1 {
 		epoch, err := strconv.ParseInt(trimmed[:colon], 10, 64)
 		if err != nil {
@@ -191,7 +288,9 @@ func parseInto(result *Version, input string) error {
 	if len(result.Version) == 0 {
 		return fmt.Errorf("nothing after colon in version number")
 	}
-	if hyphen := strings.LastIndex(result.Version, "-"); hyphen != -1 {
+	hyphen := strings.LastIndex(result.Version, "-")
+	result.explicitRevision = hyphen != -1
+	if hyphen != -1 {
 		result.Revision = result.Version[hyphen+1:]
 		result.Version = result.Version[:hyphen]
 	}
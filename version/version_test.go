@@ -29,6 +29,8 @@
 package version
 
 import (
+	"sort"
+	"strings"
 	"testing"
 )
 
@@ -357,4 +359,242 @@ func TestParseInvalidCharactersInRevision(t *testing.T) {
 	}
 }
 
+func TestParseExplicitEpochAndRevision(t *testing.T) {
+	for _, tc := range []struct {
+		input    string
+		epoch    bool
+		revision bool
+	}{
+		{"1.0", false, false},
+		{"0:1.0", true, false},
+		{"1.0-0", false, true},
+		{"0:1.0-0", true, true},
+		{"1:1.0", true, false},
+		{"1.0-", false, true},
+		{"1.0-1", false, true},
+	} {
+		a, err := Parse(tc.input)
+		if err != nil {
+			t.Errorf("Parsing %q failed: %v", tc.input, err)
+			continue
+		}
+		if got := a.HasExplicitEpoch(); got != tc.epoch {
+			t.Errorf("%q: HasExplicitEpoch() = %v, want %v", tc.input, got, tc.epoch)
+		}
+		if got := a.HasExplicitRevision(); got != tc.revision {
+			t.Errorf("%q: HasExplicitRevision() = %v, want %v", tc.input, got, tc.revision)
+		}
+	}
+
+	/* Explicitness doesn't change ordering */
+	a, _ := Parse("1.0")
+	b, _ := Parse("0:1.0-")
+	if Compare(a, b) != 0 {
+		t.Errorf("Compare(%v, %v), got %d, want 0", a, b, Compare(a, b))
+	}
+
+	/* Versions built by hand are explicit only where they have to be */
+	if c := v(2, "1.0", ""); !c.HasExplicitEpoch() || c.HasExplicitRevision() {
+		t.Errorf("%v: unexpected explicitness", c)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	for _, tc := range []struct {
+		a     string
+		b     string
+		equal bool
+	}{
+		{"1.0", "1.0", true},
+		{"1.0", "0:1.0", true},
+		{"1.0-1", "0:1.0-1", true},
+		{"1.0", "1.00", true},
+		{"1.0", "1.0-0", true},
+		{"1.0", "1.0-1", false},
+		{"1:1.0", "1.0", false},
+		{"1.0~rc1", "1.0", false},
+	} {
+		a, err := Parse(tc.a)
+		if err != nil {
+			t.Fatalf("Parsing %q failed: %v", tc.a, err)
+		}
+		b, err := Parse(tc.b)
+		if err != nil {
+			t.Fatalf("Parsing %q failed: %v", tc.b, err)
+		}
+		if got := a.Equal(b); got != tc.equal {
+			t.Errorf("Equal(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.equal)
+		}
+		if got := b.Equal(a); got != tc.equal {
+			t.Errorf("Equal(%q, %q) = %v, want %v", tc.b, tc.a, got, tc.equal)
+		}
+		if a.Equal(b) != (Compare(a, b) == 0) {
+			t.Errorf("Equal(%q, %q) disagrees with Compare", tc.a, tc.b)
+		}
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	for input, want := range map[string]Version{
+		"v1.2.3":   v(0, "1.2.3", ""),
+		"V2.0-1":   v(0, "2.0", "1"),
+		" 1.0-1 ":  v(0, "1.0", "1"),
+		"\tv1.0\n": v(0, "1.0", ""),
+		"1:2.30-1": v(1, "2.30", "1"),
+		"1.0+v2-1": v(0, "1.0+v2", "1"),
+	} {
+		got, err := ParseLenient(input)
+		if err != nil {
+			t.Errorf("ParseLenient(%q) failed: %v", input, err)
+			continue
+		}
+		if Compare(got, want) != 0 {
+			t.Errorf("ParseLenient(%q) = %v, want %v", input, got, want)
+		}
+	}
+
+	for _, input := range []string{"v", "vv1.0", "version1.0", "v 1.0", ""} {
+		if _, err := ParseLenient(input); err == nil {
+			t.Errorf("ParseLenient(%q) should have failed", input)
+		}
+	}
+
+	/* Parse itself is as strict as ever */
+	if _, err := Parse("v1.2.3"); err == nil {
+		t.Errorf("Parse(%q) should have failed", "v1.2.3")
+	}
+}
+
+func TestCompareSign(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.0-0", 0},
+		{"1.0", "0:1.0", 0},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~~", "1.0~", -1},
+		{"1.0~", "1.0", -1},
+		{"1.0", "1.0+b1", -1},
+		{"1.0-1~bpo1", "1.0-1", -1},
+		{"1.0a", "1.0", 1},
+		{"1.10", "1.9", 1},
+		{"1:0.1", "2.0", 1},
+		{"1.0-10", "1.0-9", 1},
+		{"2.30-1ubuntu4", "2.30-1", 1},
+	} {
+		a, err := Parse(tc.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := Parse(tc.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := Compare(a, b); got != tc.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+		if got := Compare(b, a); got != -tc.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", tc.b, tc.a, got, -tc.want)
+		}
+		if a.Less(b) != (tc.want < 0) {
+			t.Errorf("%q.Less(%q) = %t", tc.a, tc.b, a.Less(b))
+		}
+	}
+}
+
+func TestBinNMU(t *testing.T) {
+	for _, tc := range []struct {
+		input  string
+		binNMU int
+	}{
+		{"1.2-3+b1", 1},
+		{"1.2-3+b12", 12},
+		{"1.2+b2", 2},
+		{"1:1.2-3+deb12u1+b1", 1},
+		{"1.2-3", 0},
+		{"1.2-3+deb12u1", 0},
+		{"1.2-3+b", 0},
+		{"1.2-3+b+1", 0},
+		{"1.2+b1-3", 0},
+	} {
+		v, err := Parse(tc.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := v.BinNMU(); got != tc.binNMU {
+			t.Errorf("%q.BinNMU() = %d, want %d", tc.input, got, tc.binNMU)
+		}
+		if v.IsBinNMU() != (tc.binNMU != 0) {
+			t.Errorf("%q.IsBinNMU() = %t", tc.input, v.IsBinNMU())
+		}
+	}
+
+	binNMU, err := Parse("1.2-3+b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rebuilt, err := Parse("1.2-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := Parse("1.2-4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rebuilt.Less(binNMU) || !binNMU.Less(next) {
+		t.Errorf("1.2-3 < 1.2-3+b1 < 1.2-4 doesn't hold")
+	}
+}
+
+func TestSortSlice(t *testing.T) {
+	versions := Slice{}
+	for _, input := range []string{"1.0", "1:0.1", "1.0~rc1", "0.9-2", "1.0-1", "0.9-10", "1.0~beta"} {
+		v, err := Parse(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, v)
+	}
+	sort.Sort(versions)
+
+	got := []string{}
+	for _, v := range versions {
+		got = append(got, v.String())
+	}
+	want := []string{"0.9-2", "0.9-10", "1.0~beta", "1.0~rc1", "1.0", "1.0-1", "1:0.1"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("sorted to %q, want %q", got, want)
+	}
+}
+
+func benchmarkVersions(b *testing.B) []Version {
+	versions := []Version{}
+	for _, input := range []string{"1:2.30-1ubuntu4", "2.30-1ubuntu4", "1:2.30-1ubuntu4~bpo1"} {
+		v, err := Parse(input)
+		if err != nil {
+			b.Fatal(err)
+		}
+		versions = append(versions, v)
+	}
+	return versions
+}
+
+func BenchmarkEqual(b *testing.B) {
+	versions := benchmarkVersions(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		versions[0].Equal(versions[i%len(versions)])
+	}
+}
+
+func BenchmarkCompareEqual(b *testing.B) {
+	versions := benchmarkVersions(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = Compare(versions[0], versions[i%len(versions)]) == 0
+	}
+}
+
 // vim:ts=4:sw=4:noexpandtab foldmethod=marker